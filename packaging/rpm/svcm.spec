@@ -8,6 +8,7 @@ URL:            https://github.com/your/svcm
 Source0:        %{name}-%{version}.tar.gz
 
 BuildRequires:  golang
+BuildRequires:  systemd-devel
 Requires:       systemd
 
 %description
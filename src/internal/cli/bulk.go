@@ -0,0 +1,79 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+
+	"svcm/src/internal/core"
+)
+
+// runBulk resolves units and patterns and runs action for all of them, at
+// most parallel at a time
+func runBulk(ctx context.Context, manager core.Manager, args []string, parallel int, use, past string, action func(core.Manager, context.Context, string) error) {
+	units, err := core.ResolveUnits(ctx, manager, args)
+	if err != nil {
+		fatal(err, "Failed to resolve units")
+	}
+
+	fmt.Fprintf(os.Stderr, "Queueing %s jobs for %d units...\n", use, len(units))
+	job := func(ctx context.Context, name string) error {
+		return action(manager, ctx, name)
+	}
+	results := core.RunBulk(ctx, units, parallel, job, bulkProgress(len(units), past))
+	finishBulk(manager, results)
+}
+
+// bulkProgress reports each finished job on stderr
+func bulkProgress(total int, past string) func(int, core.BulkResult) {
+	return func(finished int, r core.BulkResult) {
+		status := past
+		switch {
+		case r.Err != nil:
+			status = "failed: " + r.Error
+		case r.Result == "skipped":
+			status = "skipped, " + r.Error
+		}
+		fmt.Fprintf(os.Stderr, "[%d/%d] %s %s\n", finished, total, r.Unit, status)
+	}
+}
+
+// finishBulk prints the summary of a bulk operation and exits unless every
+// job succeeded
+func finishBulk(manager core.Manager, results []core.BulkResult) {
+	err := render(results, func(out io.Writer, wide bool) {
+		w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "UNIT\tRESULT\tTIME\tERROR")
+		for _, r := range results {
+			fmt.Fprintf(w, "%s\t%s\t%.1fs\t%s\n", r.Unit, r.Result, r.Seconds, r.Error)
+		}
+		w.Flush()
+	})
+	if err != nil {
+		fatal(err, "Failed to render output")
+	}
+
+	if code := bulkExitCode(results); code != 0 {
+		manager.Close()
+		os.Exit(code)
+	}
+}
+
+// bulkExitCode is the exit code shared by all failed jobs, or exitFailure
+// when they failed for different reasons
+func bulkExitCode(results []core.BulkResult) int {
+	code := 0
+	for _, r := range results {
+		if r.Err == nil {
+			continue
+		}
+		c := exitCode(r.Err)
+		if code != 0 && c != code {
+			return exitFailure
+		}
+		code = c
+	}
+	return code
+}
@@ -1,9 +1,10 @@
 package cli
 
 import (
+	"context"
 	"fmt"
-	"log"
-	"os"
+	"io"
+	"strings"
 	"text/tabwriter"
 
 	"svcm/src/internal/core"
@@ -11,90 +12,161 @@ import (
 	"github.com/spf13/cobra"
 )
 
+var listTypes []string
+
 func init() {
+	listCmd.Flags().StringSliceVarP(&listTypes, "type", "t", []string{"service"}, "Unit types to list ("+strings.Join(core.UnitTypes, ", ")+" or all)")
 	rootCmd.AddCommand(listCmd)
 	rootCmd.AddCommand(startCmd)
 	rootCmd.AddCommand(stopCmd)
 	rootCmd.AddCommand(restartCmd)
+	rootCmd.AddCommand(reloadCmd)
+	rootCmd.AddCommand(reloadOrRestartCmd)
+	rootCmd.AddCommand(tryRestartCmd)
+	rootCmd.AddCommand(resetFailedCmd)
 }
 
 var listCmd = &cobra.Command{
 	Use:   "list",
-	Short: "List all user services",
+	Short: "List units (services by default)",
 	Run: func(cmd *cobra.Command, args []string) {
-		manager, err := core.NewSystemdManager(Privileged)
-		if err != nil {
-			log.Fatalf("Failed to connect to systemd: %v", err)
-		}
+		manager := connect()
 		defer manager.Close()
+		ctx, cancel := commandContext()
+		defer cancel()
 
-		services, err := manager.ListServices()
+		types := listTypes
+		if len(types) == 1 && types[0] == "all" {
+			types = nil
+		}
+		services, err := manager.ListUnits(ctx, types...)
 		if err != nil {
-			log.Fatalf("Failed to list services: %v", err)
+			fatal(err, "Failed to list units")
 		}
 
-		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
-		fmt.Fprintln(w, "NAME\tSTATE\tACTIVE\tDESCRIPTION")
-		for _, s := range services {
-			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", s.Name, s.LoadState, s.ActiveState, s.Description)
+		err = render(services, func(out io.Writer, wide bool) {
+			w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+			if wide {
+				fmt.Fprintln(w, "NAME\tLOAD\tACTIVE\tSUB\tDESCRIPTION")
+				for _, s := range services {
+					fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", s.Name, s.LoadState, s.ActiveState, s.SubState, s.Description)
+				}
+			} else {
+				fmt.Fprintln(w, "NAME\tSTATE\tACTIVE\tDESCRIPTION")
+				for _, s := range services {
+					fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", s.Name, s.LoadState, s.ActiveState, s.Description)
+				}
+			}
+			w.Flush()
+		})
+		if err != nil {
+			fatal(err, "Failed to render output")
 		}
-		w.Flush()
 	},
 }
 
-var startCmd = &cobra.Command{
-	Use:   "start [service]",
-	Short: "Start a service",
-	Args:  cobra.ExactArgs(1),
-	Run: func(cmd *cobra.Command, args []string) {
-		manager, err := core.NewSystemdManager(Privileged)
-		if err != nil {
-			log.Fatalf("Failed to connect to systemd: %v", err)
-		}
-		defer manager.Close()
+// jobCommand builds a command that queues a job for each unit named or
+// matched by a pattern and waits for them, honoring --job-mode
+func jobCommand(use, short, verb, past string, action func(core.Manager, context.Context, string) error) *cobra.Command {
+	var parallel int
+	cmd := &cobra.Command{
+		Use:   use + " [unit|pattern]...",
+		Short: short,
+		Long: short + `.
 
-		name := args[0]
-		if err := manager.StartService(name); err != nil {
-			log.Fatalf("Failed to start service %s: %v", name, err)
-		}
-		fmt.Printf("Service %s started.\n", name)
-	},
+Several units and glob patterns such as "dev-*" may be given; patterns are
+matched against the loaded units. Their jobs run concurrently, at most
+--parallel at a time, followed by a summary.`,
+		Args: cobra.MinimumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			manager := jobManager()
+			defer manager.Close()
+			ctx, cancel := commandContext()
+			defer cancel()
+
+			if len(args) > 1 || core.IsUnitPattern(args[0]) {
+				runBulk(ctx, manager, args, parallel, use, past, action)
+				return
+			}
+			name := args[0]
+			if err := action(manager, ctx, name); err != nil {
+				fatal(err, "Failed to %s %s", verb, name)
+			}
+			printActionResult(actionResult{Unit: name, Action: use}, "Service %s "+past+".\n")
+		},
+	}
+	cmd.Flags().IntVarP(&parallel, "parallel", "j", 4, "Number of jobs to run at once when several units are given")
+	return cmd
+}
+
+// jobManager checks --job-mode, which commandContext applies to the jobs,
+// and connects to systemd
+func jobManager() core.Manager {
+	if err := core.CheckJobMode(JobMode); err != nil {
+		usageFatal("Invalid --job-mode: %v", err)
+	}
+	return connect()
 }
 
-var stopCmd = &cobra.Command{
-	Use:   "stop [service]",
-	Short: "Stop a service",
-	Args:  cobra.ExactArgs(1),
+var (
+	startCmd   = jobCommand("start", "Start a service", "start service", "started", core.Manager.StartService)
+	stopCmd    = jobCommand("stop", "Stop a service", "stop service", "stopped", core.Manager.StopService)
+	restartCmd = jobCommand("restart", "Restart a service", "restart service", "restarted", core.Manager.RestartService)
+	reloadCmd  = jobCommand("reload", "Reload the configuration of a running service (ExecReload=)",
+		"reload", "reloaded", core.Manager.ReloadService)
+	reloadOrRestartCmd = jobCommand("reload-or-restart", "Reload a service if it supports it, restart it otherwise",
+		"reload or restart", "reloaded or restarted", core.Manager.ReloadOrRestartService)
+	tryRestartCmd = jobCommand("try-restart", "Restart a service only if it is running",
+		"try-restart", "restarted if it was running", core.Manager.TryRestartService)
+)
+
+var resetFailedCmd = &cobra.Command{
+	Use:   "reset-failed [unit]",
+	Short: "Clear the failed state of a unit, or of all units",
+	Args:  cobra.MaximumNArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
-		manager, err := core.NewSystemdManager(Privileged)
-		if err != nil {
-			log.Fatalf("Failed to connect to systemd: %v", err)
-		}
+		manager := connect()
 		defer manager.Close()
+		ctx, cancel := commandContext()
+		defer cancel()
 
-		name := args[0]
-		if err := manager.StopService(name); err != nil {
-			log.Fatalf("Failed to stop service %s: %v", name, err)
+		if len(args) == 1 {
+			name := args[0]
+			if err := manager.ResetFailedService(ctx, name); err != nil {
+				fatal(err, "Failed to reset %s", name)
+			}
+			printActionResult(actionResult{Unit: name, Action: "reset-failed"}, "Reset failed state of %s.\n")
+			return
 		}
-		fmt.Printf("Service %s stopped.\n", name)
-	},
-}
 
-var restartCmd = &cobra.Command{
-	Use:   "restart [service]",
-	Short: "Restart a service",
-	Args:  cobra.ExactArgs(1),
-	Run: func(cmd *cobra.Command, args []string) {
-		manager, err := core.NewSystemdManager(Privileged)
+		names, err := manager.ResetAllFailed(ctx)
 		if err != nil {
-			log.Fatalf("Failed to connect to systemd: %v", err)
+			fatal(err, "Failed to reset failed units")
 		}
-		defer manager.Close()
-
-		name := args[0]
-		if err := manager.RestartService(name); err != nil {
-			log.Fatalf("Failed to restart service %s: %v", name, err)
+		err = render(names, func(w io.Writer, wide bool) {
+			for _, name := range names {
+				fmt.Fprintf(w, "Reset failed state of %s.\n", name)
+			}
+			if len(names) == 0 {
+				fmt.Fprintln(w, "No failed units.")
+			}
+		})
+		if err != nil {
+			fatal(err, "Failed to render output")
 		}
-		fmt.Printf("Service %s restarted.\n", name)
 	},
 }
+
+// printActionResult prints the outcome of a state-changing command, falling
+// back to the given message format when no structured output was requested.
+func printActionResult(result actionResult, message string) {
+	err := render(result, func(w io.Writer, wide bool) {
+		printUnitFileChanges(w, result.Changes)
+		if message != "" {
+			fmt.Fprintf(w, message, result.Unit)
+		}
+	})
+	if err != nil {
+		fatal(err, "Failed to render output")
+	}
+}
@@ -0,0 +1,124 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"svcm/src/internal/core"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	depsReverse bool
+	depsDot     bool
+	depsKinds   []string
+	depsDepth   int
+)
+
+func init() {
+	f := depsCmd.Flags()
+	f.BoolVarP(&depsReverse, "reverse", "r", false, "Show the units that depend on the unit instead")
+	f.BoolVar(&depsDot, "dot", false, "Print a Graphviz digraph instead of the tree")
+	f.StringSliceVar(&depsKinds, "kinds", core.DefaultDependencyKinds, "Dependencies to follow ("+strings.Join(core.DependencyKinds, ", ")+")")
+	f.IntVar(&depsDepth, "depth", 0, "Maximum depth to resolve (0 for unlimited)")
+	rootCmd.AddCommand(depsCmd)
+}
+
+var depsCmd = &cobra.Command{
+	Use:   "deps [unit]",
+	Short: "Show what a unit pulls in, or with --reverse what depends on it",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if depsDot && Output != "" {
+			usageFatal("--dot cannot be combined with --output")
+		}
+
+		manager := connect()
+		defer manager.Close()
+		ctx, cancel := commandContext()
+		defer cancel()
+
+		name := args[0]
+		tree, err := manager.Dependencies(ctx, name, core.DependencyQuery{
+			Reverse: depsReverse,
+			Kinds:   depsKinds,
+			Depth:   depsDepth,
+		})
+		if err != nil {
+			fatal(err, "Failed to resolve dependencies of %s", name)
+		}
+
+		if depsDot {
+			writeDependencyDot(os.Stdout, tree)
+			return
+		}
+		err = render(tree, func(w io.Writer, wide bool) {
+			printDependencyTree(w, tree, "", true, true)
+		})
+		if err != nil {
+			fatal(err, "Failed to render output")
+		}
+	},
+}
+
+// printDependencyTree draws the tree like systemctl list-dependencies
+func printDependencyTree(w io.Writer, node *core.DependencyNode, prefix string, root, last bool) {
+	line := fmt.Sprintf("%s (%s)", node.Name, node.ActiveState)
+	if len(node.Kinds) > 0 {
+		line += " [" + strings.Join(node.Kinds, ",") + "]"
+	}
+	if node.Repeated {
+		line += " ..."
+	}
+
+	childPrefix := prefix
+	if root {
+		fmt.Fprintln(w, line)
+	} else {
+		branch := "├─"
+		childPrefix += "│ "
+		if last {
+			branch = "└─"
+			childPrefix = prefix + "  "
+		}
+		fmt.Fprintf(w, "%s%s%s\n", prefix, branch, line)
+	}
+	for i, child := range node.Children {
+		printDependencyTree(w, child, childPrefix, false, i == len(node.Children)-1)
+	}
+}
+
+// writeDependencyDot writes the tree as a Graphviz digraph, one edge per
+// parent and child labeled with the properties linking them
+func writeDependencyDot(w io.Writer, tree *core.DependencyNode) {
+	fmt.Fprintln(w, "digraph dependencies {")
+	fmt.Fprintln(w, "\trankdir=LR;")
+	fmt.Fprintln(w, "\tnode [shape=box];")
+
+	nodes := map[string]bool{}
+	var walk func(n *core.DependencyNode)
+	walk = func(n *core.DependencyNode) {
+		if !nodes[n.Name] {
+			nodes[n.Name] = true
+			color := "black"
+			switch n.ActiveState {
+			case "active":
+				color = "darkgreen"
+			case "failed":
+				color = "red"
+			case "inactive":
+				color = "gray"
+			}
+			fmt.Fprintf(w, "\t%q [color=%s];\n", n.Name, color)
+		}
+		for _, c := range n.Children {
+			fmt.Fprintf(w, "\t%q -> %q [label=%q];\n", n.Name, c.Name, strings.Join(c.Kinds, ","))
+			walk(c)
+		}
+	}
+	walk(tree)
+	fmt.Fprintln(w, "}")
+}
@@ -0,0 +1,112 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+
+	"svcm/src/internal/core"
+
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	rootCmd.AddCommand(catCmd)
+	rootCmd.AddCommand(editCmd)
+}
+
+var catCmd = &cobra.Command{
+	Use:   "cat [unit]",
+	Short: "Show the unit file and drop-ins of a unit",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		manager := connect()
+		defer manager.Close()
+		ctx, cancel := commandContext()
+		defer cancel()
+
+		name := args[0]
+		file, err := manager.GetUnitFile(ctx, name)
+		if err != nil {
+			fatal(err, "Failed to read unit file of %s", name)
+		}
+
+		err = render(file, func(w io.Writer, wide bool) {
+			sources := append([]core.UnitFileSource{file.Fragment}, file.DropIns...)
+			for i, s := range sources {
+				if s.Path == "" {
+					continue
+				}
+				if i > 0 {
+					fmt.Fprintln(w)
+				}
+				fmt.Fprintf(w, "# %s\n%s", s.Path, s.Content)
+				if !strings.HasSuffix(s.Content, "\n") {
+					fmt.Fprintln(w)
+				}
+			}
+		})
+		if err != nil {
+			fatal(err, "Failed to render output")
+		}
+	},
+}
+
+var editCmd = &cobra.Command{
+	Use:   "edit [unit]",
+	Short: "Edit the override drop-in of a unit and reload systemd",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		manager := connect()
+		defer manager.Close()
+
+		// --timeout would count the time spent in the editor
+		name := args[0]
+		path, changed, err := manager.EditDropIn(context.Background(), name, runEditor)
+		if err != nil {
+			fatal(err, "Failed to edit %s", name)
+		}
+		// The drop-in is written, or removed when it was left empty
+		result := actionResult{Unit: core.UnitName(name), Action: "edit"}
+		if changed {
+			change := core.UnitFileChange{Type: "unlink", Filename: path}
+			if file, err := manager.GetUnitFile(context.Background(), name); err == nil {
+				for _, d := range file.DropIns {
+					if d.Path == path {
+						change.Type = "write"
+					}
+				}
+			}
+			result.Changes = []core.UnitFileChange{change}
+		}
+		err = render(result, func(w io.Writer, wide bool) {
+			switch {
+			case len(result.Changes) == 0:
+				fmt.Fprintln(w, "No changes made.")
+			case result.Changes[0].Type == "unlink":
+				fmt.Fprintf(w, "Removed %s and reloaded systemd.\n", path)
+			default:
+				fmt.Fprintf(w, "Wrote %s and reloaded systemd.\n", path)
+			}
+		})
+		if err != nil {
+			fatal(err, "Failed to render output")
+		}
+	},
+}
+
+// runEditor opens the user's editor on path and waits for it to exit
+func runEditor(path string) error {
+	editor := core.Editor()
+	cmd := exec.Command(editor[0], append(editor[1:], path)...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("editor %s failed: %w", editor[0], err)
+	}
+	return nil
+}
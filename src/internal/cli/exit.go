@@ -0,0 +1,76 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+
+	"svcm/src/internal/core"
+)
+
+// Exit codes follow the LSB init script actions, like systemctl, with the
+// classes LSB has no code for in the range it leaves to applications
+const (
+	exitFailure          = 1
+	exitUsage            = 2
+	exitPermissionDenied = 4
+	exitNotFound         = 5
+	exitCanceled         = 130
+	exitJobFailed        = 150
+	exitDependencyFailed = 151
+	exitTimeout          = 152
+)
+
+// LSB status codes of the status command
+const (
+	statusActive   = 0
+	statusFailed   = 1
+	statusInactive = 3
+	statusUnknown  = 4
+)
+
+// exitCode maps the class of err onto an exit code
+func exitCode(err error) int {
+	switch {
+	case errors.Is(err, core.ErrNotFound):
+		return exitNotFound
+	case errors.Is(err, core.ErrPermissionDenied):
+		return exitPermissionDenied
+	case errors.Is(err, core.ErrTimeout):
+		return exitTimeout
+	case errors.Is(err, core.ErrCanceled):
+		return exitCanceled
+	case errors.Is(err, core.ErrDependencyFailed):
+		return exitDependencyFailed
+	case errors.Is(err, core.ErrJobFailed):
+		return exitJobFailed
+	}
+	return exitFailure
+}
+
+// fatal logs "<message>: <err>" like log.Fatalf and exits with the code of
+// err's class
+func fatal(err error, format string, args ...interface{}) {
+	log.Printf("%s: %v", fmt.Sprintf(format, args...), err)
+	os.Exit(exitCode(err))
+}
+
+// usageFatal logs a problem with the arguments and exits with exitUsage
+func usageFatal(format string, args ...interface{}) {
+	log.Printf(format, args...)
+	os.Exit(exitUsage)
+}
+
+// statusCode is the LSB status of a unit
+func statusCode(u core.ServiceUnit) int {
+	switch {
+	case u.LoadState == "not-found":
+		return statusUnknown
+	case u.ActiveState == "failed":
+		return statusFailed
+	case u.ActiveState == "active" || u.ActiveState == "reloading" || u.ActiveState == "refreshing":
+		return statusActive
+	}
+	return statusInactive
+}
@@ -0,0 +1,91 @@
+package cli
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+const fakeBackend = "--backend=fake:../../../examples/fake-units.yaml"
+
+// TestMain runs svcm instead of the tests when runSvcm starts the test
+// binary again, so commands can exit with their own codes
+func TestMain(m *testing.M) {
+	if os.Getenv("SVCM_TEST_RUN_CLI") != "" {
+		os.Args = append([]string{"svcm"}, os.Args[1:]...)
+		Execute()
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// runSvcm runs svcm with args and returns its exit code and output
+func runSvcm(t *testing.T, args ...string) (int, string) {
+	t.Helper()
+	cmd := exec.Command(os.Args[0], args...)
+	cmd.Env = append(os.Environ(), "SVCM_TEST_RUN_CLI=1", "XDG_CONFIG_HOME="+t.TempDir())
+	out, err := cmd.CombinedOutput()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitCode(), string(out)
+	}
+	if err != nil {
+		t.Fatalf("failed to run svcm: %v", err)
+	}
+	return 0, string(out)
+}
+
+func TestExitCodes(t *testing.T) {
+	config := filepath.Join(t.TempDir(), "config.yaml")
+	groups := `groups:
+  stack: {units: [web, worker]}
+  reports: {units: [broken, reports]}
+  ghost: {units: [web, "ghost-*"]}
+`
+	if err := os.WriteFile(config, []byte(groups), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		args []string
+		want int
+	}{
+		{[]string{"start", "web"}, 0},
+		{[]string{"stop", "worker"}, 0},
+		{[]string{"start", "broken"}, exitJobFailed},
+		{[]string{"start", "reports"}, exitDependencyFailed},
+		{[]string{"restart", "reports"}, exitDependencyFailed},
+		{[]string{"start", "missing"}, exitNotFound},
+		{[]string{"enable", "--now", "reports"}, exitDependencyFailed},
+		{[]string{"start", "web", "worker"}, 0},
+		{[]string{"start", "broken", "reports"}, exitFailure},
+		{[]string{"start", "broken", "web"}, exitJobFailed},
+		{[]string{"start", "missing-*"}, exitNotFound},
+		{[]string{"status", "web"}, statusActive},
+		{[]string{"status", "broken"}, statusFailed},
+		{[]string{"status", "reports"}, statusInactive},
+		{[]string{"--config", config, "group", "start", "stack"}, 0},
+		{[]string{"--config", config, "group", "start", "reports"}, exitJobFailed},
+		{[]string{"--config", config, "group", "start", "ghost"}, exitNotFound},
+		{[]string{"--config", config, "group", "status", "reports"}, statusInactive},
+		{[]string{"--config", config, "group", "status", "ghost"}, statusUnknown},
+		{[]string{"--config", config, "group", "stop", "ghost"}, exitNotFound},
+		{[]string{"--config", config, "group", "start", "nope"}, exitNotFound},
+		{[]string{"kill", "web"}, 0},
+		{[]string{"kill", "missing"}, exitNotFound},
+		{[]string{"kill", "--who", "everyone", "web"}, exitUsage},
+		{[]string{"deps", "--dot", "worker"}, 0},
+		{[]string{"deps", "--dot", "-o", "json", "worker"}, exitUsage},
+		{[]string{"start"}, exitUsage},
+		{[]string{"--job-mode", "later", "start", "web"}, exitUsage},
+		{[]string{"no-such-command"}, exitUsage},
+	}
+	for _, tt := range tests {
+		args := append([]string{fakeBackend}, tt.args...)
+		if code, out := runSvcm(t, args...); code != tt.want {
+			t.Errorf("svcm %v exited with %d, want %d:\n%s", tt.args, code, tt.want, out)
+		}
+	}
+}
@@ -1,10 +1,14 @@
 package cli
 
 import (
+	"context"
 	"fmt"
+	"io"
 	"log"
 	"os"
-	"os/exec"
+	"os/signal"
+	"strings"
+	"syscall"
 	"time"
 
 	"svcm/src/internal/core"
@@ -15,72 +19,222 @@ import (
 func init() {
 	rootCmd.AddCommand(statusCmd)
 	rootCmd.AddCommand(logsCmd)
+
+	f := logsCmd.Flags()
+	f.BoolVarP(&logsFollow, "follow", "f", false, "Keep printing new entries as they are written")
+	f.IntVarP(&logsQuery.Lines, "lines", "n", 50, "Number of most recent entries to show (0 for all)")
+	f.StringVar(&logsSince, "since", "", "Show entries not older than this time (e.g. \"2024-01-02 10:00\", \"1h ago\", today)")
+	f.StringVar(&logsUntil, "until", "", "Show entries not newer than this time")
+	f.StringVarP(&logsQuery.Priority, "priority", "p", "", "Show entries up to this priority (emerg..debug or 0-7)")
+	f.StringVarP(&logsQuery.Grep, "grep", "g", "", "Only show entries whose message matches this regular expression")
+	f.StringVarP(&logsQuery.Boot, "boot", "b", "", "Only show entries from this boot ID (\"current\" if no ID is given)")
+	f.Lookup("boot").NoOptDefVal = "current"
+	f.StringVarP(&logsQuery.Directory, "directory", "D", "", "Read journal files from this directory")
+	f.StringSliceVar(&logsQuery.Files, "file", nil, "Read these journal files")
 }
 
 // statusCmd shows detailed status using DBus properties
 var statusCmd = &cobra.Command{
-	Use:   "status [service]",
-	Short: "Show detailed status of a service",
-	Args:  cobra.ExactArgs(1),
+	Use:   "status [unit]",
+	Short: "Show detailed status of a unit (services unless a suffix is given)",
+	Long: `Show detailed status of a unit (services unless a suffix is given).
+
+Exits with the LSB status of the unit: 0 if it is active, 1 if it failed,
+3 if it is not running and 4 if it does not exist or its status is unknown.`,
+	Args: cobra.ExactArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
-		manager, err := core.NewSystemdManager(Privileged)
-		if err != nil {
-			log.Fatalf("Failed to connect to systemd: %v", err)
-		}
+		manager := connect()
 		defer manager.Close()
+		ctx, cancel := commandContext()
+		defer cancel()
 
 		name := args[0]
-		details, err := manager.GetServiceDetails(name)
+		details, err := manager.GetServiceDetails(ctx, name)
 		if err != nil {
-			log.Fatalf("Failed to get status for %s: %v", name, err)
+			log.Printf("Failed to get status for %s: %v", name, err)
+			os.Exit(statusUnknown)
 		}
 
-		fmt.Printf("● %s - %s\n", details.Name, details.Description)
-		fmt.Printf("   Loaded: %s (%s)\n", details.LoadState, details.FragmentPath)
-		fmt.Printf("   Active: %s (%s)\n", details.ActiveState, details.SubState)
-		if details.MainPID != 0 {
-			fmt.Printf(" Main PID: %d\n", details.MainPID)
+		err = render(details, func(w io.Writer, wide bool) {
+			printStatus(w, details, wide)
+		})
+		if err != nil {
+			fatal(err, "Failed to render output")
 		}
 
-		// Format Timestamps (microsecond resolution)
-		if details.ActiveEnterTimestamp > 0 {
-			ts := time.UnixMicro(int64(details.ActiveEnterTimestamp))
-			fmt.Printf("   Active Since: %s\n", ts.Format(time.RFC1123))
+		// Exit with the LSB status, like systemctl status
+		if code := statusCode(details.ServiceUnit); code != statusActive {
+			manager.Close()
+			os.Exit(code)
 		}
 	},
 }
 
-// logsCmd wraps journalctl to show logs for a specific service
+var logsQuery core.LogQuery
+var logsSince, logsUntil string
+var logsFollow bool
+
+// logsCmd reads the journal entries of a specific service
 var logsCmd = &cobra.Command{
 	Use:   "logs [service]",
-	Short: "Show logs for a specific service (wrapper around journalctl)",
+	Short: "Show logs for a specific service",
 	Args:  cobra.ExactArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
-		name := args[0]
-		// Append .service if not present, though journalctl might be smart, better be explicit
-		// We can reuse the core logic but here we just want to run the command
-		// Actually, let's use the same name resolution logic if possible or just let journalctl handle it.
-		// But the user liked smart naming.
-
-		// Let's rely on simple string manipulation here since we are in CLI package
-		if len(name) > 0 && name[len(name)-8:] != ".service" {
-			name = name + ".service"
+		manager := connect()
+		defer manager.Close()
+		ctx, cancel := commandContext()
+		defer cancel()
+
+		var err error
+		q := logsQuery
+		q.Unit = args[0]
+		now := time.Now()
+		if logsSince != "" {
+			if q.Since, err = core.ParseLogTime(logsSince, now); err != nil {
+				usageFatal("Invalid --since: %v", err)
+			}
+		}
+		if logsUntil != "" {
+			if q.Until, err = core.ParseLogTime(logsUntil, now); err != nil {
+				usageFatal("Invalid --until: %v", err)
+			}
 		}
 
-		// Run journalctl -u <service> -n 50 --no-pager
-		// If Privileged, run system journalctl (sudo usually required for reading system logs depending on config, but command is same structure minus --user)
-		var cmdArgs []string
-		if Privileged {
-			cmdArgs = []string{"-u", name, "-n", "50", "--no-pager"}
-		} else {
-			cmdArgs = []string{"--user", "-u", name, "-n", "50", "--no-pager"}
+		if logsFollow {
+			followLogs(ctx, manager, q)
+			return
 		}
 
-		c := exec.Command("journalctl", cmdArgs...)
-		c.Stdout = os.Stdout
-		c.Stderr = os.Stderr
-		if err := c.Run(); err != nil {
-			log.Fatalf("Failed to retrieve logs: %v", err)
+		entries, err := manager.GetLogs(ctx, q)
+		if err != nil {
+			fatal(err, "Failed to retrieve logs")
+		}
+
+		err = render(entries, func(w io.Writer, wide bool) {
+			for _, e := range entries {
+				fmt.Fprintln(w, core.FormatLogEntry(e))
+			}
+		})
+		if err != nil {
+			fatal(err, "Failed to render output")
 		}
 	},
 }
+
+// followLogs streams entries until interrupted; --timeout only bounds
+// reading the backlog
+func followLogs(ctx context.Context, manager core.Manager, q core.LogQuery) {
+	stream, err := manager.FollowLogs(ctx, q)
+	if err != nil {
+		fatal(err, "Failed to follow logs")
+	}
+	defer stream.Close()
+
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(interrupt)
+
+	for {
+		select {
+		case <-interrupt:
+			return
+		case e, ok := <-stream.Entries:
+			if !ok {
+				if err := stream.Err(); err != nil {
+					fatal(err, "Failed to follow logs")
+				}
+				return
+			}
+			err := renderStreamItem(e, func(w io.Writer, wide bool) {
+				fmt.Fprintln(w, core.FormatLogEntry(e))
+			})
+			if err != nil {
+				fatal(err, "Failed to render output")
+			}
+		}
+	}
+}
+
+func printStatus(w io.Writer, details *core.ServiceDetails, wide bool) {
+	fmt.Fprintf(w, "● %s - %s\n", details.Name, details.Description)
+	if details.UnitFileState != "" {
+		fmt.Fprintf(w, "   Loaded: %s (%s; %s)\n", details.LoadState, details.FragmentPath, details.UnitFileState)
+	} else {
+		fmt.Fprintf(w, "   Loaded: %s (%s)\n", details.LoadState, details.FragmentPath)
+	}
+	fmt.Fprintf(w, "   Active: %s (%s)\n", details.ActiveState, details.SubState)
+	if details.MainPID != 0 {
+		fmt.Fprintf(w, " Main PID: %d\n", details.MainPID)
+	}
+	printUsage(w, details.ResourceUsage)
+
+	// Format Timestamps (microsecond resolution)
+	if details.ActiveEnterTimestamp > 0 {
+		ts := time.UnixMicro(int64(details.ActiveEnterTimestamp))
+		fmt.Fprintf(w, "   Active Since: %s\n", ts.Format(time.RFC1123))
+	}
+
+	switch {
+	case details.Socket != nil:
+		for _, l := range details.Socket.Listen {
+			fmt.Fprintf(w, "   Listen: %s (%s)\n", l.Value, l.Type)
+		}
+		fmt.Fprintf(w, " Accepted: %d; Connected: %d\n", details.Socket.Accepted, details.Socket.Connections)
+		fmt.Fprintf(w, " Triggers: %s\n", strings.Join(details.Socket.Triggers, " "))
+	case details.Path != nil:
+		for _, c := range details.Path.Conditions {
+			fmt.Fprintf(w, "  %s: %s\n", c.Type, c.Value)
+		}
+		fmt.Fprintf(w, " Triggers: %s\n", strings.Join(details.Path.Triggers, " "))
+	case details.Mount != nil:
+		fmt.Fprintf(w, "    Where: %s\n", details.Mount.Where)
+		fmt.Fprintf(w, "     What: %s (%s)\n", details.Mount.What, details.Mount.FSType)
+		fmt.Fprintf(w, "  Options: %s\n", details.Mount.Options)
+	case details.Target != nil:
+		fmt.Fprintf(w, "    Wants: %s\n", strings.Join(details.Target.Wants, " "))
+		fmt.Fprintf(w, " Requires: %s\n", strings.Join(details.Target.Requires, " "))
+		fmt.Fprintf(w, "Wanted By: %s\n", strings.Join(details.Target.WantedBy, " "))
+	}
+
+	if details.ControlGroup != "" {
+		fmt.Fprintf(w, "   CGroup: %s\n", details.ControlGroup)
+		printProcessTree(w, core.BuildProcessTree(details.Processes), "           ", wide)
+	}
+}
+
+// printProcessTree draws processes like systemctl status does; wide adds
+// the user, RSS and CPU time of each process
+func printProcessTree(w io.Writer, nodes []*core.ProcessNode, indent string, wide bool) {
+	for i, n := range nodes {
+		branch, next := "├─", "│ "
+		if i == len(nodes)-1 {
+			branch, next = "└─", "  "
+		}
+		if wide {
+			fmt.Fprintf(w, "%s%s%d %s %s %s %s\n", indent, branch, n.PID, n.User, core.FormatBytes(n.RSSBytes),
+				core.FormatDuration(time.Duration(n.CPUTimeNSec)), n.Command)
+		} else {
+			fmt.Fprintf(w, "%s%s%d %s\n", indent, branch, n.PID, n.Command)
+		}
+		printProcessTree(w, n.Children, indent+next, wide)
+	}
+}
+
+// printUsage shows the cgroup accounting systemd keeps for the unit
+func printUsage(w io.Writer, u core.ResourceUsage) {
+	if u.TasksCurrent > 0 {
+		fmt.Fprintf(w, "    Tasks: %d\n", u.TasksCurrent)
+	}
+	if u.MemoryCurrent > 0 {
+		fmt.Fprintf(w, "   Memory: %s\n", core.FormatBytes(u.MemoryCurrent))
+	}
+	if u.CPUUsageNSec > 0 {
+		fmt.Fprintf(w, "      CPU: %s\n", core.FormatDuration(time.Duration(u.CPUUsageNSec)))
+	}
+	if u.IOReadBytes > 0 || u.IOWriteBytes > 0 {
+		fmt.Fprintf(w, "       IO: %s read, %s written\n", core.FormatBytes(u.IOReadBytes), core.FormatBytes(u.IOWriteBytes))
+	}
+	if u.IPIngressBytes > 0 || u.IPEgressBytes > 0 {
+		fmt.Fprintf(w, "       IP: %s in, %s out\n", core.FormatBytes(u.IPIngressBytes), core.FormatBytes(u.IPEgressBytes))
+	}
+}
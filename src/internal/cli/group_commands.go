@@ -0,0 +1,131 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"svcm/src/internal/core"
+
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	groupCmd.AddCommand(groupListCmd)
+	groupCmd.AddCommand(groupStatusCmd)
+	groupCmd.AddCommand(groupJobCommand("start", "Start the units of a group in declared order", "started", core.StartGroup))
+	groupCmd.AddCommand(groupJobCommand("stop", "Stop the units of a group in reverse order", "stopped", core.StopGroup))
+	groupCmd.AddCommand(groupJobCommand("restart", "Restart the units of a group in declared order", "restarted", core.RestartGroup))
+	rootCmd.AddCommand(groupCmd)
+}
+
+var groupCmd = &cobra.Command{
+	Use:   "group",
+	Short: "Manage the unit groups declared in the config file",
+	Long: `Manage the unit groups declared in the config file
+($XDG_CONFIG_HOME/svcm/config.yaml unless --config is given):
+
+  groups:
+    projectx:
+      description: Project X dev stack
+      units: [postgres, redis, projectx-api, "projectx-worker@*"]
+
+Units start one at a time in the order they are listed, and a failure
+skips the rest. They stop in reverse order.`,
+}
+
+var groupListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the declared groups",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		config := loadConfig()
+		err := render(config.Groups, func(out io.Writer, wide bool) {
+			w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "NAME\tUNITS\tDESCRIPTION")
+			for _, name := range config.GroupNames() {
+				g := config.Groups[name]
+				fmt.Fprintf(w, "%s\t%s\t%s\n", name, strings.Join(g.Units, " "), g.Description)
+			}
+			w.Flush()
+		})
+		if err != nil {
+			fatal(err, "Failed to render output")
+		}
+	},
+}
+
+var groupStatusCmd = &cobra.Command{
+	Use:   "status [group]",
+	Short: "Show the state of the units of a group",
+	Long: `Show the state of the units of a group.
+
+Exits with the highest LSB status of its units (see svcm status), so 0 means
+every unit is active.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		g, err := loadConfig().Group(args[0])
+		if err != nil {
+			fatal(err, "Failed to find group")
+		}
+		manager := connect()
+		defer manager.Close()
+		ctx, cancel := commandContext()
+		defer cancel()
+
+		units, err := core.GroupStatus(ctx, manager, g)
+		if err != nil {
+			fatal(err, "Failed to get status of group %s", args[0])
+		}
+
+		err = render(units, func(out io.Writer, wide bool) {
+			w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "UNIT\tLOAD\tACTIVE\tSUB\tDESCRIPTION")
+			for _, u := range units {
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", u.Name, u.LoadState, u.ActiveState, u.SubState, u.Description)
+			}
+			w.Flush()
+		})
+		if err != nil {
+			fatal(err, "Failed to render output")
+		}
+
+		code := statusActive
+		for _, u := range units {
+			code = max(code, statusCode(u))
+		}
+		if code != statusActive {
+			manager.Close()
+			os.Exit(code)
+		}
+	},
+}
+
+// groupJobCommand builds a command that runs the jobs of a group, honoring
+// --job-mode
+func groupJobCommand(use, short, past string, run func(context.Context, core.Manager, []string, func(int, core.BulkResult)) []core.BulkResult) *cobra.Command {
+	return &cobra.Command{
+		Use:   use + " [group]",
+		Short: short,
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			g, err := loadConfig().Group(args[0])
+			if err != nil {
+				fatal(err, "Failed to find group")
+			}
+			manager := jobManager()
+			defer manager.Close()
+			ctx, cancel := commandContext()
+			defer cancel()
+
+			units, err := g.Resolve(ctx, manager)
+			if err != nil {
+				fatal(err, "Failed to resolve the units of group %s", args[0])
+			}
+			finishBulk(manager, run(ctx, manager, units, bulkProgress(len(units), past)))
+		},
+	}
+}
@@ -10,7 +10,7 @@ var guiCmd = &cobra.Command{
 	Use:   "gui",
 	Short: "Launch the graphical user interface",
 	Run: func(cmd *cobra.Command, args []string) {
-		gui.Run(Privileged)
+		gui.Run(Backend, Privileged, loadConfig())
 	},
 }
 
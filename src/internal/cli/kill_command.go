@@ -0,0 +1,61 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"svcm/src/internal/core"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	killSignal string
+	killWho    string
+)
+
+// killResult is the structured form of the kill command
+type killResult struct {
+	Unit   string `json:"unit"`
+	Signal string `json:"signal"`
+	Who    string `json:"who"`
+}
+
+func init() {
+	killCmd.Flags().StringVarP(&killSignal, "signal", "s", "TERM", "Signal to send, by name (HUP, SIGKILL, ...) or number")
+	killCmd.Flags().StringVar(&killWho, "who", "all", "Processes to signal ("+strings.Join(core.KillWho, ", ")+")")
+	rootCmd.AddCommand(killCmd)
+}
+
+var killCmd = &cobra.Command{
+	Use:   "kill [unit]",
+	Short: "Send a signal to the processes of a unit",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		sig, err := core.ParseSignal(killSignal)
+		if err != nil {
+			usageFatal("Invalid --signal: %v", err)
+		}
+		if err := core.CheckKillWho(killWho); err != nil {
+			usageFatal("Invalid --who: %v", err)
+		}
+
+		manager := connect()
+		defer manager.Close()
+		ctx, cancel := commandContext()
+		defer cancel()
+
+		name := args[0]
+		if err := manager.KillService(ctx, name, killWho, sig); err != nil {
+			fatal(err, "Failed to kill %s", name)
+		}
+		result := killResult{Unit: core.UnitName(name), Signal: core.SignalName(sig), Who: killWho}
+		err = render(result, func(w io.Writer, wide bool) {
+			fmt.Fprintf(w, "Sent %s to %s processes of %s\n", result.Signal, result.Who, result.Unit)
+		})
+		if err != nil {
+			fatal(err, "Failed to render output")
+		}
+	},
+}
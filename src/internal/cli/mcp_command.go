@@ -1,19 +1,55 @@
 package cli
 
 import (
+	"os"
+	"strings"
+
 	"svcm/src/internal/mcp"
 
 	"github.com/spf13/cobra"
 )
 
+var (
+	policyFile string
+	auditLog   string
+	listenAddr string
+	tokenFile  string
+)
+
 var mcpCmd = &cobra.Command{
 	Use:   "mcp",
-	Short: "Run the MCP server (stdio)",
+	Short: "Run the MCP server (stdio, or HTTP with --listen)",
 	Run: func(cmd *cobra.Command, args []string) {
-		mcp.Run()
+		policy, err := mcp.LoadPolicy(policyFile, auditLog)
+		if err != nil {
+			fatal(err, "Failed to load MCP policy")
+		}
+		defer policy.Close()
+
+		// Keep the token off the command line, where other users can see it
+		token := os.Getenv("SVCM_MCP_TOKEN")
+		if tokenFile != "" {
+			data, err := os.ReadFile(tokenFile)
+			if err != nil {
+				fatal(err, "Failed to read token")
+			}
+			token = strings.TrimSpace(string(data))
+		}
+
+		mcp.Run(mcp.Config{
+			SystemMode: Privileged,
+			Policy:     policy,
+			Listen:     listenAddr,
+			Token:      token,
+			Backend:    Backend,
+		})
 	},
 }
 
 func init() {
+	mcpCmd.Flags().StringVar(&policyFile, "policy", "", "Policy file for mutating tools (default "+mcp.DefaultPolicyPath()+")")
+	mcpCmd.Flags().StringVar(&auditLog, "audit-log", "", "File the policy decisions are appended to, or - for stderr")
+	mcpCmd.Flags().StringVar(&listenAddr, "listen", "", "Serve HTTP on host:port or unix:/path instead of stdio")
+	mcpCmd.Flags().StringVar(&tokenFile, "token-file", "", "File holding the bearer token HTTP clients must send (default $SVCM_MCP_TOKEN)")
 	rootCmd.AddCommand(mcpCmd)
 }
@@ -0,0 +1,176 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"svcm/src/internal/core"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	newTemplate   string
+	newSpec       core.UnitSpec
+	newEnable     bool
+	newStart      bool
+	newDryRun     bool
+	newForce      bool
+	listTemplates bool
+)
+
+// newResult is the structured form of the new command
+type newResult struct {
+	Unit    string                `json:"unit"`
+	Created []string              `json:"created"`
+	Changes []core.UnitFileChange `json:"changes,omitempty"`
+	Enabled string                `json:"enabled,omitempty"`
+	Started string                `json:"started,omitempty"`
+}
+
+func init() {
+	f := newCmd.Flags()
+	f.StringVarP(&newTemplate, "template", "T", "simple", "Template to use (built-in: simple, oneshot, timer, socket; user templates live in "+core.TemplateDir()+")")
+	f.StringVar(&newSpec.Exec, "exec", "", "Command the service runs")
+	f.StringVar(&newSpec.Description, "description", "", "Unit description (default the unit name)")
+	f.StringVar(&newSpec.Restart, "restart", "", "Restart policy, e.g. on-failure or always")
+	f.StringArrayVar(&newSpec.Environment, "env", nil, "Environment variable KEY=VALUE (repeatable)")
+	f.StringSliceVar(&newSpec.After, "after", nil, "Units to order the service after")
+	f.StringVar(&newSpec.WorkingDirectory, "workdir", "", "Working directory of the service")
+	f.StringVar(&newSpec.Schedule, "on-calendar", "", "OnCalendar= schedule of the timer template, e.g. daily")
+	f.StringVar(&newSpec.Listen, "listen", "", "ListenStream= address of the socket template, e.g. 8080")
+	f.BoolVar(&newEnable, "enable", false, "Enable the unit after installing it")
+	f.BoolVar(&newStart, "now", false, "Start the unit after installing it")
+	f.BoolVar(&newDryRun, "dry-run", false, "Print the generated unit files without installing them")
+	f.BoolVar(&newForce, "force", false, "Overwrite existing unit files")
+	f.BoolVar(&listTemplates, "list-templates", false, "List the available templates and exit")
+	rootCmd.AddCommand(newCmd)
+}
+
+var newCmd = &cobra.Command{
+	Use:   "new [name]",
+	Short: "Create and install a unit from a template",
+	Args: func(cmd *cobra.Command, args []string) error {
+		if listTemplates {
+			return cobra.NoArgs(cmd, args)
+		}
+		return cobra.ExactArgs(1)(cmd, args)
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		if listTemplates {
+			names := core.ListTemplates()
+			err := render(names, func(w io.Writer, wide bool) {
+				for _, name := range names {
+					fmt.Fprintln(w, name)
+				}
+			})
+			if err != nil {
+				fatal(err, "Failed to render output")
+			}
+			return
+		}
+		if newSpec.Exec == "" {
+			usageFatal("--exec is required")
+		}
+
+		manager := jobManager()
+		defer manager.Close()
+		ctx, cancel := commandContext()
+		defer cancel()
+
+		spec := newSpec
+		spec.Name = args[0]
+		spec.Exec = resolveExec(spec.Exec)
+		units, err := manager.RenderUnits(ctx, newTemplate, spec)
+		if err != nil {
+			fatal(err, "Failed to render template %s", newTemplate)
+		}
+
+		failed := false
+		for _, u := range units {
+			for _, problem := range core.LintUnit(u.Path, u.Content) {
+				fmt.Fprintf(os.Stderr, "%s: %s\n", filepath.Base(u.Path), problem)
+				failed = true
+			}
+		}
+		if newDryRun {
+			err := render(units, func(w io.Writer, wide bool) {
+				for i, u := range units {
+					if i > 0 {
+						fmt.Fprintln(w)
+					}
+					fmt.Fprintf(w, "# %s\n%s", u.Path, u.Content)
+				}
+			})
+			if err != nil {
+				fatal(err, "Failed to render output")
+			}
+			return
+		}
+		if failed {
+			usageFatal("Not installing %s: the generated unit files have problems", spec.Name)
+		}
+
+		if err := manager.InstallUnits(ctx, units, newForce); err != nil {
+			fatal(err, "Failed to install %s", spec.Name)
+		}
+		// The first unit is the timer or socket that activates the service
+		primary := filepath.Base(units[0].Path)
+		result := newResult{Unit: primary}
+		for _, u := range units {
+			result.Created = append(result.Created, u.Path)
+		}
+
+		if newEnable {
+			changes, err := manager.EnableService(ctx, primary, newStart)
+			if err != nil {
+				fatal(err, "Failed to enable %s", primary)
+			}
+			result.Changes = changes
+			result.Enabled = primary
+		} else if newStart {
+			if err := manager.StartService(ctx, primary); err != nil {
+				fatal(err, "Failed to start %s", primary)
+			}
+		}
+		if newStart {
+			result.Started = primary
+		}
+
+		err = render(result, func(w io.Writer, wide bool) {
+			for _, path := range result.Created {
+				fmt.Fprintf(w, "Created %s\n", path)
+			}
+			if result.Enabled != "" {
+				fmt.Fprintf(w, "Enabled %s\n", result.Enabled)
+			}
+			if result.Started != "" {
+				fmt.Fprintf(w, "Started %s\n", result.Started)
+			}
+		})
+		if err != nil {
+			fatal(err, "Failed to render output")
+		}
+	},
+}
+
+// resolveExec looks the command up in $PATH, since systemd wants an
+// absolute path in ExecStart=
+func resolveExec(command string) string {
+	fields := strings.Fields(command)
+	if len(fields) == 0 || filepath.IsAbs(fields[0]) {
+		return command
+	}
+	path, err := exec.LookPath(fields[0])
+	if err != nil {
+		return command
+	}
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	return path + strings.TrimPrefix(strings.TrimLeft(command, " \t"), fields[0])
+}
@@ -0,0 +1,142 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"svcm/src/internal/core"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Output holds the value of the global --output flag
+var Output string
+
+const templatePrefix = "go-template="
+
+// actionResult is the structured form of commands that change unit state
+type actionResult struct {
+	Unit    string                `json:"unit"`
+	Action  string                `json:"action"`
+	Changes []core.UnitFileChange `json:"changes,omitempty"`
+}
+
+func validateOutput() error {
+	switch {
+	case Output == "", Output == "json", Output == "yaml", Output == "wide":
+		return nil
+	case strings.HasPrefix(Output, templatePrefix):
+		_, err := template.New("output").Parse(strings.TrimPrefix(Output, templatePrefix))
+		if err != nil {
+			return fmt.Errorf("invalid go-template: %w", err)
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown output format %q (expected json, yaml, wide or go-template=...)", Output)
+}
+
+// render prints data in the requested structured format. Plain and wide
+// output is left to the text callback, which knows how to lay out its own
+// tables.
+func render(data interface{}, text func(w io.Writer, wide bool)) error {
+	switch {
+	case Output == "json":
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(data)
+	case Output == "yaml":
+		// Go through JSON so YAML keys match the json tags of the core types
+		generic, err := toGeneric(data)
+		if err != nil {
+			return err
+		}
+		out, err := yaml.Marshal(generic)
+		if err != nil {
+			return err
+		}
+		_, err = os.Stdout.Write(out)
+		return err
+	case strings.HasPrefix(Output, templatePrefix):
+		tmpl, err := template.New("output").Parse(strings.TrimPrefix(Output, templatePrefix))
+		if err != nil {
+			return fmt.Errorf("invalid go-template: %w", err)
+		}
+		generic, err := toGeneric(data)
+		if err != nil {
+			return err
+		}
+		// End with a newline like the other formats unless the template does
+		var out bytes.Buffer
+		if err := tmpl.Execute(&out, generic); err != nil {
+			return err
+		}
+		if out.Len() > 0 && !bytes.HasSuffix(out.Bytes(), []byte("\n")) {
+			out.WriteByte('\n')
+		}
+		_, err = out.WriteTo(os.Stdout)
+		return err
+	default:
+		text(os.Stdout, Output == "wide")
+		return nil
+	}
+}
+
+// renderStreamItem prints one element of an unbounded stream such as
+// followed logs: JSON as one object per line, YAML as separate documents.
+func renderStreamItem(data interface{}, text func(w io.Writer, wide bool)) error {
+	switch Output {
+	case "json":
+		return json.NewEncoder(os.Stdout).Encode(data)
+	case "yaml":
+		fmt.Fprintln(os.Stdout, "---")
+	}
+	return render(data, text)
+}
+
+// toGeneric turns data into maps and slices keyed by the json field names.
+// Integers become int64, or uint64 when too large for it, and other
+// numbers float64, so templates can compare them with literals.
+func toGeneric(data interface{}) (interface{}, error) {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+	var generic interface{}
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	if err := dec.Decode(&generic); err != nil {
+		return nil, err
+	}
+	return convertNumbers(generic), nil
+}
+
+// convertNumbers replaces the json.Number values in v
+func convertNumbers(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, e := range v {
+			v[k] = convertNumbers(e)
+		}
+	case []interface{}:
+		for i, e := range v {
+			v[i] = convertNumbers(e)
+		}
+	case json.Number:
+		if n, err := v.Int64(); err == nil {
+			return n
+		}
+		if n, err := strconv.ParseUint(string(v), 10, 64); err == nil {
+			return n
+		}
+		if f, err := v.Float64(); err == nil {
+			return f
+		}
+	}
+	return v
+}
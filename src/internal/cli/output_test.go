@@ -0,0 +1,139 @@
+package cli
+
+import (
+	"encoding/json"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+	"text/template"
+
+	"svcm/src/internal/core"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestToGenericNumbers(t *testing.T) {
+	data := struct {
+		PID     uint32            `json:"pid"`
+		Offset  int               `json:"offset"`
+		Big     uint64            `json:"big"`
+		CPU     float64           `json:"cpu"`
+		Nested  map[string]uint64 `json:"nested"`
+		List    []int             `json:"list"`
+		Name    string            `json:"name"`
+		Missing *int              `json:"missing"`
+	}{2101, -5, math.MaxUint64, 3.5, map[string]uint64{"memory": 48 << 20}, []int{1, 2}, "web", nil}
+
+	got, err := toGeneric(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]interface{}{
+		"pid":     int64(2101),
+		"offset":  int64(-5),
+		"big":     uint64(math.MaxUint64),
+		"cpu":     3.5,
+		"nested":  map[string]interface{}{"memory": int64(48 << 20)},
+		"list":    []interface{}{int64(1), int64(2)},
+		"name":    "web",
+		"missing": nil,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %#v, want %#v", got, want)
+	}
+
+	tmpl := template.Must(template.New("").Parse(`{{if gt .pid 0}}{{.pid}}{{end}} {{if lt .offset 0}}negative{{end}} {{if gt .big 0}}big{{end}} {{if gt .cpu 1.0}}busy{{end}}`))
+	var out strings.Builder
+	if err := tmpl.Execute(&out, got); err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != "2101 negative big busy" {
+		t.Errorf("template printed %q", out.String())
+	}
+}
+
+func TestGoTemplateOutput(t *testing.T) {
+	code, out := runSvcm(t, fakeBackend, "-o", "go-template={{if gt .main_pid 0}}running {{.main_pid}}{{end}}", "status", "web")
+	if code != 0 || !regexp.MustCompile(`^running [1-9][0-9]*\n$`).MatchString(out) {
+		t.Errorf("got %d %q, want the main PID and a newline", code, out)
+	}
+
+	code, out = runSvcm(t, fakeBackend, "-o", "go-template={{range .}}{{.name}}\n{{end}}", "list")
+	if code != 0 || !strings.HasSuffix(out, ".service\n") || strings.HasSuffix(out, "\n\n") {
+		t.Errorf("got %d %q, want one name per line", code, out)
+	}
+}
+
+func TestKillOutput(t *testing.T) {
+	code, out := runSvcm(t, fakeBackend, "-o", "json", "kill", "--signal", "HUP", "web")
+	var got killResult
+	if err := json.Unmarshal([]byte(out), &got); code != 0 || err != nil {
+		t.Fatalf("got %d %q, want JSON: %v", code, out, err)
+	}
+	if want := (killResult{Unit: "web.service", Signal: "SIGHUP", Who: "all"}); got != want {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestNewOutput(t *testing.T) {
+	code, out := runSvcm(t, fakeBackend, "-o", "json", "new", "demo", "--exec", "/bin/true", "--enable", "--now")
+	var got newResult
+	if err := json.Unmarshal([]byte(out), &got); code != 0 || err != nil {
+		t.Fatalf("got %d %q, want JSON: %v", code, out, err)
+	}
+	if got.Unit != "demo.service" || len(got.Created) != 1 || got.Enabled != "demo.service" || got.Started != "demo.service" {
+		t.Errorf("got %+v, want demo.service created, enabled and started", got)
+	}
+
+	code, out = runSvcm(t, fakeBackend, "-o", "json", "new", "demo", "--exec", "/bin/true", "-T", "timer", "--on-calendar", "daily", "--dry-run")
+	var units []map[string]string
+	if err := json.Unmarshal([]byte(out), &units); code != 0 || err != nil || len(units) != 2 {
+		t.Errorf("dry run: got %d %q, want the timer and its service as JSON", code, out)
+	}
+
+	// A unit with problems is not installed
+	if code, out := runSvcm(t, fakeBackend, "new", "demo", "--exec", "/bin/true", "-T", "timer"); code != exitUsage {
+		t.Errorf("timer without a schedule: got %d %q, want %d", code, out, exitUsage)
+	}
+}
+
+func TestEditOutput(t *testing.T) {
+	editor := filepath.Join(t.TempDir(), "editor")
+	if err := os.WriteFile(editor, []byte("#!/bin/sh\nprintf '[Service]\\nNice=5\\n' >\"$1\"\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("SYSTEMD_EDITOR", editor)
+	code, out := runSvcm(t, fakeBackend, "-o", "json", "edit", "web")
+	var got actionResult
+	if err := json.Unmarshal([]byte(out), &got); code != 0 || err != nil {
+		t.Fatalf("got %d %q, want JSON: %v", code, out, err)
+	}
+	if got.Unit != "web.service" || len(got.Changes) != 1 || got.Changes[0].Type != "write" {
+		t.Errorf("got %+v, want the override of web.service written", got)
+	}
+
+	// The fake backend forgets the override when svcm exits
+	t.Setenv("SYSTEMD_EDITOR", "true")
+	code, out = runSvcm(t, fakeBackend, "-o", "json", "edit", "web")
+	var unchanged actionResult
+	if err := json.Unmarshal([]byte(out), &unchanged); code != 0 || err != nil || len(unchanged.Changes) != 0 {
+		t.Errorf("unchanged: got %d %q, want no changes", code, out)
+	}
+}
+
+func TestDepsOutput(t *testing.T) {
+	code, out := runSvcm(t, fakeBackend, "-o", "yaml", "deps", "worker")
+	var tree core.DependencyNode
+	if err := yaml.Unmarshal([]byte(out), &tree); code != 0 || err != nil || tree.Name != "worker.service" || len(tree.Children) == 0 {
+		t.Errorf("got %d %q, want the tree of worker.service as YAML", code, out)
+	}
+
+	code, out = runSvcm(t, fakeBackend, "deps", "--dot", "worker")
+	if code != 0 || !strings.HasPrefix(out, "digraph dependencies {") || !strings.Contains(out, `"worker.service" -> "web.service"`) {
+		t.Errorf("got %d %q, want a digraph with worker.service requiring web.service", code, out)
+	}
+}
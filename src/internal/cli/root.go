@@ -1,8 +1,15 @@
 package cli
 
 import (
+	"context"
 	"fmt"
 	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"svcm/src/internal/core"
 
 	"github.com/spf13/cobra"
 )
@@ -11,17 +18,83 @@ var rootCmd = &cobra.Command{
 	Use:   "svcm",
 	Short: "svcm manages systemd services for the user",
 	Long:  `A lightweight systemd service manager for Wayland with CLI, GUI, and MCP interfaces.`,
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		if err := validateOutput(); err != nil {
+			return err
+		}
+		var err error
+		Backend, err = core.OpenBackend(BackendSpec)
+		return err
+	},
 }
 
 var Privileged bool
 
+// BackendSpec selects what svcm manages: systemd, or fake:<fixture.yaml>
+// to simulate the units of a fixture
+var BackendSpec string
+
+// Backend is opened from BackendSpec before any command runs
+var Backend core.Backend
+
+// JobMode is how start, stop and the other job commands treat conflicting
+// jobs already queued
+var JobMode string
+
+// ConfigFile is the svcm config file, core.DefaultConfigPath() when empty
+var ConfigFile string
+
+// Timeout bounds how long a command waits for the service manager; zero
+// waits until the job finishes
+var Timeout time.Duration
+
 func Execute() {
+	// Commands exit on their own failures, so errors here are about the
+	// command line
 	if err := rootCmd.Execute(); err != nil {
 		fmt.Fprintln(os.Stderr, err)
-		os.Exit(1)
+		os.Exit(exitUsage)
 	}
 }
 
 func init() {
 	rootCmd.PersistentFlags().BoolVarP(&Privileged, "privileged", "P", false, "Use system bus instead of user bus (requires sudo/policykit)")
+	rootCmd.PersistentFlags().StringVar(&BackendSpec, "backend", "systemd", "Service manager to use: systemd, or fake:FIXTURE to simulate the units of a YAML fixture")
+	rootCmd.PersistentFlags().StringVar(&JobMode, "job-mode", "replace", "How queued jobs treat conflicting ones ("+strings.Join(core.JobModes, ", ")+")")
+	rootCmd.PersistentFlags().DurationVar(&Timeout, "timeout", 0, "Give up waiting for the service manager after this long, e.g. 30s (0 waits forever)")
+	rootCmd.PersistentFlags().StringVar(&ConfigFile, "config", "", "Config file with unit groups (default $XDG_CONFIG_HOME/svcm/config.yaml)")
+	rootCmd.PersistentFlags().StringVarP(&Output, "output", "o", "", "Output format: json|yaml|wide|go-template=TEMPLATE")
+}
+
+// connect opens the manager chosen by --backend and --privileged
+func connect() core.Manager {
+	manager, err := Backend(Privileged)
+	if err != nil {
+		fatal(err, "Failed to connect to systemd")
+	}
+	return manager
+}
+
+// commandContext queues jobs in the --job-mode and is canceled by Ctrl-C
+// or SIGTERM and once --timeout passes. Jobs that were already queued keep
+// running in systemd.
+func commandContext() (context.Context, context.CancelFunc) {
+	ctx, stop := signal.NotifyContext(core.WithJobMode(context.Background(), JobMode), os.Interrupt, syscall.SIGTERM)
+	if Timeout <= 0 {
+		return ctx, stop
+	}
+	ctx, cancel := context.WithTimeout(ctx, Timeout)
+	return ctx, func() {
+		cancel()
+		stop()
+	}
+}
+
+// loadConfig reads --config or the default config file
+func loadConfig() *core.Config {
+	config, err := core.LoadConfig(ConfigFile)
+	if err != nil {
+		fatal(err, "Failed to load config")
+	}
+	return config
 }
@@ -0,0 +1,102 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"svcm/src/internal/core"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	runSpec      core.TransientSpec
+	runMemoryMax string
+	runCPUQuota  string
+)
+
+func init() {
+	f := runCmd.Flags()
+	// Flags after the command belong to the command
+	f.SetInterspersed(false)
+	f.StringVarP(&runSpec.Name, "unit", "u", "", "Unit name (default a random run-* name)")
+	f.StringVar(&runSpec.Description, "description", "", "Unit description (default the command line)")
+	f.StringArrayVarP(&runSpec.Environment, "setenv", "E", nil, "Environment variable KEY=VALUE (repeatable)")
+	f.StringVar(&runSpec.WorkingDirectory, "working-directory", "", "Working directory of the command")
+	f.StringVar(&runMemoryMax, "memory-max", "", "Memory limit, e.g. 512M or 2G")
+	f.StringVar(&runCPUQuota, "cpu-quota", "", "CPU limit in percent of one CPU, e.g. 50%")
+	f.StringVar(&runSpec.OnCalendar, "on-calendar", "", "Run from a transient timer on this OnCalendar= schedule")
+	f.BoolVarP(&runSpec.Collect, "collect", "G", false, "Unload the unit even if it failed")
+	f.BoolVarP(&runSpec.Wait, "wait", "W", false, "Wait for the command to finish and exit with its status")
+	rootCmd.AddCommand(runCmd)
+}
+
+var runCmd = &cobra.Command{
+	Use:   "run [flags] -- command [args...]",
+	Short: "Run a command as a transient service, like systemd-run",
+	Args:  cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		spec := runSpec
+		spec.Command = args
+		if runMemoryMax != "" {
+			size, err := core.ParseSize(runMemoryMax)
+			if err != nil {
+				usageFatal("Invalid --memory-max: %v", err)
+			}
+			spec.MemoryMax = size
+		}
+		if runCPUQuota != "" {
+			percent, err := parsePercent(runCPUQuota)
+			if err != nil {
+				usageFatal("Invalid --cpu-quota: %v", err)
+			}
+			spec.CPUQuota = percent
+		}
+
+		manager := connect()
+		defer manager.Close()
+		ctx, cancel := commandContext()
+		defer cancel()
+
+		name, err := manager.RunTransient(ctx, spec)
+		if err != nil {
+			fatal(err, "Failed to run %s", args[0])
+		}
+		fmt.Fprintf(os.Stderr, "Running as unit: %s\n", name)
+		if !spec.Wait {
+			return
+		}
+
+		result, err := manager.WaitTransient(ctx, name)
+		if err != nil {
+			fatal(err, "Failed to wait for %s", name)
+		}
+		err = render(result, func(w io.Writer, wide bool) {
+			fmt.Fprintf(w, "Finished with result: %s\n", result.Result)
+			if result.Code != "" {
+				fmt.Fprintf(w, "Main processes terminated with: code=%s/status=%d\n", result.Code, result.Status)
+			}
+			fmt.Fprintf(w, "Service runtime: %s\n", core.FormatDuration(time.Duration(result.RuntimeUSec)*time.Microsecond))
+		})
+		if err != nil {
+			fatal(err, "Failed to render output")
+		}
+
+		// os.Exit skips deferred calls
+		manager.Close()
+		os.Exit(result.ExitStatus())
+	},
+}
+
+// parsePercent parses "50%" or "50"
+func parsePercent(s string) (uint64, error) {
+	n, err := strconv.ParseUint(strings.TrimSuffix(s, "%"), 10, 64)
+	if err != nil || n == 0 {
+		return 0, fmt.Errorf("invalid percentage %q", s)
+	}
+	return n, nil
+}
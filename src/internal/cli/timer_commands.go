@@ -0,0 +1,133 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"svcm/src/internal/core"
+
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	timersCmd.AddCommand(timerStatusCmd)
+	timersCmd.AddCommand(timerTriggerCmd)
+	rootCmd.AddCommand(timersCmd)
+}
+
+var timersCmd = &cobra.Command{
+	Use:   "timers",
+	Short: "List timers with their next and last run",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		manager := connect()
+		defer manager.Close()
+		ctx, cancel := commandContext()
+		defer cancel()
+
+		timers, err := manager.ListTimers(ctx)
+		if err != nil {
+			fatal(err, "Failed to list timers")
+		}
+
+		now := time.Now()
+		err = render(timers, func(out io.Writer, wide bool) {
+			w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+			if wide {
+				fmt.Fprintln(w, "NEXT\tLEFT\tLAST\tPASSED\tUNIT\tACTIVATES\tPERSISTENT\tACCURACY")
+			} else {
+				fmt.Fprintln(w, "NEXT\tLEFT\tLAST\tPASSED\tUNIT\tACTIVATES")
+			}
+			for _, t := range timers {
+				next, left := timerTime(t.NextElapse(), now)
+				last, passed := timerTime(t.LastTrigger(), now)
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s", next, left, last, passed, t.Name, t.Unit)
+				if wide {
+					fmt.Fprintf(w, "\t%v\t%s", t.Persistent, core.FormatDuration(time.Duration(t.AccuracyUSec)*time.Microsecond))
+				}
+				fmt.Fprintln(w)
+			}
+			w.Flush()
+		})
+		if err != nil {
+			fatal(err, "Failed to render output")
+		}
+	},
+}
+
+var timerStatusCmd = &cobra.Command{
+	Use:   "status [timer]",
+	Short: "Show the schedule and state of a timer",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		manager := connect()
+		defer manager.Close()
+		ctx, cancel := commandContext()
+		defer cancel()
+
+		name := args[0]
+		timer, err := manager.GetTimer(ctx, name)
+		if err != nil {
+			fatal(err, "Failed to get timer %s", name)
+		}
+
+		err = render(timer, func(w io.Writer, wide bool) {
+			printTimer(w, timer, time.Now())
+		})
+		if err != nil {
+			fatal(err, "Failed to render output")
+		}
+	},
+}
+
+var timerTriggerCmd = &cobra.Command{
+	Use:   "trigger [timer]",
+	Short: "Run the unit of a timer now",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		manager := jobManager()
+		defer manager.Close()
+		ctx, cancel := commandContext()
+		defer cancel()
+
+		name := args[0]
+		unit, err := manager.TriggerTimer(ctx, name)
+		if err != nil {
+			fatal(err, "Failed to trigger timer %s", name)
+		}
+		printActionResult(actionResult{Unit: unit, Action: "trigger"}, "Started %s.\n")
+	},
+}
+
+func printTimer(w io.Writer, t *core.TimerUnit, now time.Time) {
+	fmt.Fprintf(w, "● %s - %s\n", t.Name, t.Description)
+	fmt.Fprintf(w, "    Loaded: %s\n", t.LoadState)
+	fmt.Fprintf(w, "    Active: %s (%s)\n", t.ActiveState, t.SubState)
+	fmt.Fprintf(w, " Activates: %s\n", t.Unit)
+	if len(t.Triggers) > 0 {
+		fmt.Fprintf(w, "  Schedule: %s\n", strings.Join(t.Triggers, ", "))
+	}
+	next, left := timerTime(t.NextElapse(), now)
+	fmt.Fprintf(w, "      Next: %s (%s)\n", next, left)
+	last, passed := timerTime(t.LastTrigger(), now)
+	fmt.Fprintf(w, "      Last: %s (%s)\n", last, passed)
+	fmt.Fprintf(w, "Persistent: %v\n", t.Persistent)
+	fmt.Fprintf(w, "  Accuracy: %s\n", core.FormatDuration(time.Duration(t.AccuracyUSec)*time.Microsecond))
+}
+
+// timerTime formats a timer timestamp and its distance from now, or "-"
+// for both when the time is unset
+func timerTime(t, now time.Time) (string, string) {
+	if t.IsZero() {
+		return "-", "-"
+	}
+	d := t.Sub(now)
+	rel := core.FormatDuration(d) + " left"
+	if d < 0 {
+		rel = core.FormatDuration(d) + " ago"
+	}
+	return t.Format("Mon 2006-01-02 15:04:05 MST"), rel
+}
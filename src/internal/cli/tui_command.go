@@ -1,7 +1,6 @@
 package cli
 
 import (
-	"log"
 	"svcm/src/internal/tui"
 
 	"github.com/spf13/cobra"
@@ -11,8 +10,8 @@ var tuiCmd = &cobra.Command{
 	Use:   "tui",
 	Short: "Launch the k9s-style TUI",
 	Run: func(cmd *cobra.Command, args []string) {
-		if err := tui.Run(Privileged); err != nil {
-			log.Fatalf("TUI Error: %v", err)
+		if err := tui.Run(Backend, Privileged, JobMode, loadConfig()); err != nil {
+			fatal(err, "TUI Error")
 		}
 	},
 }
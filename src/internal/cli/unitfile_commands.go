@@ -0,0 +1,123 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"svcm/src/internal/core"
+
+	"github.com/spf13/cobra"
+)
+
+var enableNow, disableNow, maskNow bool
+
+func init() {
+	enableCmd.Flags().BoolVar(&enableNow, "now", false, "Also start the service")
+	disableCmd.Flags().BoolVar(&disableNow, "now", false, "Also stop the service")
+	maskCmd.Flags().BoolVar(&maskNow, "now", false, "Also stop the service")
+
+	rootCmd.AddCommand(enableCmd)
+	rootCmd.AddCommand(disableCmd)
+	rootCmd.AddCommand(maskCmd)
+	rootCmd.AddCommand(unmaskCmd)
+}
+
+var enableCmd = &cobra.Command{
+	Use:   "enable [service]",
+	Short: "Enable a service to start automatically",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		manager := jobManager()
+		defer manager.Close()
+		ctx, cancel := commandContext()
+		defer cancel()
+
+		name := args[0]
+		changes, err := manager.EnableService(ctx, name, enableNow)
+		if err != nil {
+			printUnitFileChanges(os.Stderr, changes)
+			fatal(err, "Failed to enable service %s", name)
+		}
+
+		message := ""
+		if len(changes) == 0 {
+			message = "Service %s has no installation config or is already enabled.\n"
+		}
+		printActionResult(actionResult{Unit: name, Action: "enable", Changes: changes}, message)
+	},
+}
+
+var disableCmd = &cobra.Command{
+	Use:   "disable [service]",
+	Short: "Disable a service from starting automatically",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		manager := jobManager()
+		defer manager.Close()
+		ctx, cancel := commandContext()
+		defer cancel()
+
+		name := args[0]
+		changes, err := manager.DisableService(ctx, name, disableNow)
+		if err != nil {
+			printUnitFileChanges(os.Stderr, changes)
+			fatal(err, "Failed to disable service %s", name)
+		}
+		printActionResult(actionResult{Unit: name, Action: "disable", Changes: changes}, "")
+	},
+}
+
+var maskCmd = &cobra.Command{
+	Use:   "mask [service]",
+	Short: "Mask a service so it cannot be started",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		manager := jobManager()
+		defer manager.Close()
+		ctx, cancel := commandContext()
+		defer cancel()
+
+		name := args[0]
+		changes, err := manager.MaskService(ctx, name, maskNow)
+		if err != nil {
+			printUnitFileChanges(os.Stderr, changes)
+			fatal(err, "Failed to mask service %s", name)
+		}
+		printActionResult(actionResult{Unit: name, Action: "mask", Changes: changes}, "")
+	},
+}
+
+var unmaskCmd = &cobra.Command{
+	Use:   "unmask [service]",
+	Short: "Unmask a previously masked service",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		manager := connect()
+		defer manager.Close()
+		ctx, cancel := commandContext()
+		defer cancel()
+
+		name := args[0]
+		changes, err := manager.UnmaskService(ctx, name)
+		if err != nil {
+			printUnitFileChanges(os.Stderr, changes)
+			fatal(err, "Failed to unmask service %s", name)
+		}
+		printActionResult(actionResult{Unit: name, Action: "unmask", Changes: changes}, "")
+	},
+}
+
+// printUnitFileChanges reports symlink changes the same way systemctl does
+func printUnitFileChanges(w io.Writer, changes []core.UnitFileChange) {
+	for _, c := range changes {
+		switch c.Type {
+		case "symlink":
+			fmt.Fprintf(w, "Created symlink %s → %s.\n", c.Filename, c.Destination)
+		case "unlink":
+			fmt.Fprintf(w, "Removed \"%s\".\n", c.Filename)
+		default:
+			fmt.Fprintf(w, "%s %s %s\n", c.Type, c.Filename, c.Destination)
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package core
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+var (
+	_ Manager = (*SystemdManager)(nil)
+	_ Manager = (*FakeManager)(nil)
+)
+
+// Backend opens the Manager of the user (systemMode false) or system
+// instance. Frontends take a Backend so they can switch between the two.
+type Backend func(systemMode bool) (Manager, error)
+
+// SystemdBackend talks to systemd over D-Bus
+func SystemdBackend(systemMode bool) (Manager, error) {
+	m, err := NewSystemdManager(systemMode)
+	if err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// FakeBackend simulates the units of a fixture. Each instance is created
+// once, so its state survives switching between user and system mode.
+func FakeBackend(fixture *FakeFixture) Backend {
+	var mu sync.Mutex
+	managers := map[bool]*FakeManager{}
+	return func(systemMode bool) (Manager, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if m, ok := managers[systemMode]; ok {
+			return m, nil
+		}
+		m, err := NewFakeManager(fixture, systemMode)
+		if err != nil {
+			return nil, err
+		}
+		managers[systemMode] = m
+		return m, nil
+	}
+}
+
+// OpenBackend parses a --backend value: "systemd" (or empty) or
+// "fake:<fixture.yaml>"
+func OpenBackend(spec string) (Backend, error) {
+	switch {
+	case spec == "" || spec == "systemd":
+		return SystemdBackend, nil
+	case strings.HasPrefix(spec, "fake:"):
+		fixture, err := LoadFakeFixture(strings.TrimPrefix(spec, "fake:"))
+		if err != nil {
+			return nil, err
+		}
+		return FakeBackend(fixture), nil
+	}
+	return nil, fmt.Errorf("unknown backend %q (expected systemd or fake:<fixture>)", spec)
+}
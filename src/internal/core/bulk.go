@@ -0,0 +1,177 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// IsUnitPattern reports whether a unit argument is a glob pattern such as
+// "dev-*" rather than a unit name
+func IsUnitPattern(name string) bool {
+	return strings.ContainsAny(name, "*?[")
+}
+
+// ResolveUnits expands glob patterns against the loaded units of the
+// pattern's type, services unless it has a suffix, and completes plain
+// names like UnitName. Each unit is listed once, in the order of the
+// arguments that name it. A pattern that matches nothing is an
+// ErrNotFound.
+func ResolveUnits(ctx context.Context, m Manager, args []string) ([]string, error) {
+	return resolveUnits(ctx, m, args, false)
+}
+
+// resolveUnits is ResolveUnits, except that with keepUnmatched a pattern
+// that matches nothing is listed, completed like UnitName, instead of
+// failing
+func resolveUnits(ctx context.Context, m Manager, args []string, keepUnmatched bool) ([]string, error) {
+	var units []string
+	seen := map[string]bool{}
+	add := func(name string) {
+		if !seen[name] {
+			seen[name] = true
+			units = append(units, name)
+		}
+	}
+
+	loaded := map[string][]ServiceUnit{}
+	for _, arg := range args {
+		pattern := UnitName(arg)
+		if !IsUnitPattern(pattern) {
+			add(pattern)
+			continue
+		}
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid pattern %q: %w", arg, err)
+		}
+
+		unitType := UnitTypeOf(pattern)
+		list, ok := loaded[unitType]
+		if !ok {
+			var err error
+			list, err = m.ListUnits(ctx, unitType)
+			if err != nil {
+				return nil, err
+			}
+			sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+			loaded[unitType] = list
+		}
+
+		matched := false
+		for _, u := range list {
+			if ok, _ := path.Match(pattern, u.Name); ok {
+				add(u.Name)
+				matched = true
+			}
+		}
+		switch {
+		case !matched && keepUnmatched:
+			add(pattern)
+		case !matched:
+			return nil, notFound("no loaded units match %s", arg)
+		}
+	}
+	return units, nil
+}
+
+// BulkResult is the outcome of the job of one unit in a bulk operation
+type BulkResult struct {
+	Unit string `json:"unit"`
+	// Result is "done", "skipped", or the class of the error (see
+	// ErrorClass) and "failed" for errors of no particular class
+	Result  string  `json:"result"`
+	Error   string  `json:"error,omitempty"`
+	Seconds float64 `json:"seconds"`
+	Err     error   `json:"-"`
+}
+
+// RunBulk runs job for every unit with at most workers jobs at a time and
+// returns the results in the order of units. progress, when not nil, is
+// called as each job finishes with the number finished so far, one call at
+// a time. Once ctx is done, the jobs not started yet fail without running.
+func RunBulk(ctx context.Context, units []string, workers int, job func(context.Context, string) error, progress func(finished int, r BulkResult)) []BulkResult {
+	if workers < 1 {
+		workers = 1
+	}
+	results := make([]BulkResult, len(units))
+	queue := make(chan int)
+	var mu sync.Mutex
+	finished := 0
+
+	var wg sync.WaitGroup
+	for w := 0; w < workers && w < len(units); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range queue {
+				r := runBulkJob(ctx, units[i], job)
+				results[i] = r
+
+				mu.Lock()
+				finished++
+				if progress != nil {
+					progress(finished, r)
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	for i := range units {
+		queue <- i
+	}
+	close(queue)
+	wg.Wait()
+	return results
+}
+
+// RunOrdered runs job for one unit after the other. With stopOnFailure,
+// the units after one that failed are skipped: their Result is "skipped"
+// and Err is nil. A unit that does not exist fails with ErrNotFound but
+// skips no other unit, since nothing was started for it; that includes a
+// glob pattern among units, as Group.Resolve leaves those that match
+// nothing, for which job is not run at all.
+func RunOrdered(ctx context.Context, units []string, job func(context.Context, string) error, stopOnFailure bool, progress func(finished int, r BulkResult)) []BulkResult {
+	results := make([]BulkResult, 0, len(units))
+	failed := false
+	for _, unit := range units {
+		r := BulkResult{Unit: unit, Result: "skipped", Error: "an earlier unit failed"}
+		switch {
+		case IsUnitPattern(unit):
+			err := notFound("no loaded units match %s", unit)
+			r = BulkResult{Unit: unit, Result: ErrorClass(err), Error: err.Error(), Err: err}
+		case !failed:
+			r = runBulkJob(ctx, unit, job)
+			failed = stopOnFailure && r.Err != nil && !errors.Is(r.Err, ErrNotFound)
+		}
+		results = append(results, r)
+		if progress != nil {
+			progress(len(results), r)
+		}
+	}
+	return results
+}
+
+// runBulkJob runs the job of one unit unless ctx is already done
+func runBulkJob(ctx context.Context, unit string, job func(context.Context, string) error) BulkResult {
+	start := time.Now()
+	err := ctx.Err()
+	if err != nil {
+		err = fmt.Errorf("not started: %w", classify(err))
+	} else {
+		err = job(ctx, unit)
+	}
+	r := BulkResult{Unit: unit, Result: "done", Seconds: time.Since(start).Seconds(), Err: err}
+	if err != nil {
+		r.Error = err.Error()
+		r.Result = ErrorClass(err)
+		if r.Result == "" {
+			r.Result = "failed"
+		}
+	}
+	return r
+}
@@ -0,0 +1,130 @@
+package core
+
+import (
+	"context"
+	"fmt"
+)
+
+// DependencyKinds are the unit properties Dependencies can follow
+var DependencyKinds = []string{"Requires", "Wants", "BindsTo", "PartOf", "After", "Before"}
+
+// DefaultDependencyKinds are followed when a query names none. Ordering
+// (After/Before) is left out by default as it pulls in most of the system.
+var DefaultDependencyKinds = []string{"Requires", "Wants", "BindsTo", "PartOf"}
+
+// reverseKinds maps each dependency property to the one systemd keeps on
+// the other unit
+var reverseKinds = map[string]string{
+	"Requires": "RequiredBy",
+	"Wants":    "WantedBy",
+	"BindsTo":  "BoundBy",
+	"PartOf":   "ConsistsOf",
+	"After":    "Before",
+	"Before":   "After",
+}
+
+// DependencyQuery selects what Dependencies resolves
+type DependencyQuery struct {
+	// Reverse lists the units depending on the unit instead
+	Reverse bool
+	// Kinds are forward property names; DefaultDependencyKinds if empty
+	Kinds []string
+	// Depth limits recursion; 0 is unlimited and 1 returns direct
+	// dependencies only
+	Depth int
+}
+
+// DependencyNode is a unit in a dependency tree. Kinds are the properties
+// linking it to its parent. A unit is expanded only the first time it is
+// met; later occurrences are marked Repeated and have no children.
+type DependencyNode struct {
+	Name        string            `json:"name"`
+	ActiveState string            `json:"active_state"`
+	Kinds       []string          `json:"kinds,omitempty"`
+	Repeated    bool              `json:"repeated,omitempty"`
+	Children    []*DependencyNode `json:"children,omitempty"`
+}
+
+// Dependencies resolves the dependency tree of a unit
+func (m *SystemdManager) Dependencies(ctx context.Context, name string, q DependencyQuery) (*DependencyNode, error) {
+	return resolveDependencies(name, q, func(unit string) (map[string]interface{}, error) {
+		return m.conn.GetUnitPropertiesContext(ctx, unit)
+	})
+}
+
+// unitPropsFunc returns the org.freedesktop.systemd1.Unit properties of a unit
+type unitPropsFunc func(name string) (map[string]interface{}, error)
+
+func resolveDependencies(name string, q DependencyQuery, lookup unitPropsFunc) (*DependencyNode, error) {
+	kinds := q.Kinds
+	if len(kinds) == 0 {
+		kinds = DefaultDependencyKinds
+	}
+	props := make([]string, 0, len(kinds))
+	for _, k := range kinds {
+		rev, ok := reverseKinds[k]
+		if !ok {
+			return nil, fmt.Errorf("unknown dependency kind %q", k)
+		}
+		if q.Reverse {
+			props = append(props, rev)
+		} else {
+			props = append(props, k)
+		}
+	}
+
+	r := &depResolver{lookup: lookup, props: props, depth: q.Depth, states: map[string]string{}}
+	root := &DependencyNode{Name: UnitName(name)}
+	if err := r.expand(root, 0); err != nil {
+		return nil, err
+	}
+	return root, nil
+}
+
+type depResolver struct {
+	lookup unitPropsFunc
+	props  []string
+	depth  int
+	// states holds the active state of every unit expanded so far
+	states map[string]string
+}
+
+func (r *depResolver) expand(node *DependencyNode, level int) error {
+	unitProps, err := r.lookup(node.Name)
+	if err != nil {
+		return fmt.Errorf("failed to get properties for %s: %w", node.Name, classify(err))
+	}
+	node.ActiveState, _ = unitProps["ActiveState"].(string)
+	r.states[node.Name] = node.ActiveState
+	if r.depth > 0 && level >= r.depth {
+		return nil
+	}
+
+	// Collect children in property order, merging units reached through
+	// several properties into one node
+	byName := map[string]*DependencyNode{}
+	for _, prop := range r.props {
+		names, _ := unitProps[prop].([]string)
+		for _, n := range names {
+			child, ok := byName[n]
+			if !ok {
+				child = &DependencyNode{Name: n}
+				byName[n] = child
+				node.Children = append(node.Children, child)
+			}
+			child.Kinds = append(child.Kinds, prop)
+		}
+	}
+
+	for _, child := range node.Children {
+		if state, ok := r.states[child.Name]; ok {
+			child.ActiveState = state
+			child.Repeated = true
+			continue
+		}
+		if err := r.expand(child, level+1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
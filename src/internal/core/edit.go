@@ -0,0 +1,257 @@
+package core
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/coreos/go-systemd/v22/unit"
+)
+
+// Markers around the editable part of the file handed to the editor, the
+// same ones systemctl edit uses
+const (
+	editStartMarker = "### Anything between here and the comment below will become the contents of the drop-in file"
+	editEndMarker   = "### Edits below this comment will be discarded"
+)
+
+// unitDir is where svcm writes unit files and drop-ins
+func (m *SystemdManager) unitDir() (string, error) {
+	return unitDirFor(m.systemMode)
+}
+
+// unitDirFor returns /etc/systemd/system for the system manager and the
+// user's config directory otherwise.
+func unitDirFor(systemMode bool) (string, error) {
+	if systemMode {
+		return "/etc/systemd/system", nil
+	}
+	config, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to find the user config directory: %w", err)
+	}
+	return filepath.Join(config, "systemd", "user"), nil
+}
+
+// DropInPath is where svcm keeps the override drop-in of a unit
+func (m *SystemdManager) DropInPath(ctx context.Context, name string) (string, error) {
+	dir, err := m.unitDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, UnitName(name)+".d", "override.conf"), nil
+}
+
+// knownSections are the sections systemd reads from unit files; X- prefixed
+// ones are free for extensions
+var knownSections = map[string]bool{
+	"Unit": true, "Install": true, "Service": true, "Socket": true, "Timer": true, "Path": true,
+	"Mount": true, "Automount": true, "Swap": true, "Slice": true, "Scope": true,
+}
+
+// ValidateDropIn checks that text parses as unit file syntax, with every
+// option inside a section systemd knows.
+func ValidateDropIn(text string) error {
+	// The parser skips anything before the first section, systemd does not
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || line[0] == '#' || line[0] == ';' {
+			continue
+		}
+		if line[0] != '[' {
+			return fmt.Errorf("invalid unit file syntax: %q is outside of a section", line)
+		}
+		break
+	}
+
+	sections, err := unit.DeserializeSections(strings.NewReader(text))
+	if err != nil {
+		return fmt.Errorf("invalid unit file syntax: %w", err)
+	}
+	for _, s := range sections {
+		if !knownSections[s.Section] && !strings.HasPrefix(s.Section, "X-") {
+			return fmt.Errorf("unknown section [%s]", s.Section)
+		}
+	}
+	return nil
+}
+
+// EditDropIn lets the user change the override drop-in of a unit. edit is
+// called with a temporary file holding the current override, or a template
+// with the unit file commented out for a new one, and returns once the
+// user is done. The result is validated, written atomically and followed
+// by a daemon-reload; an empty result removes the override. It returns the
+// drop-in path and whether anything changed.
+func (m *SystemdManager) EditDropIn(ctx context.Context, name string, edit func(path string) error) (string, bool, error) {
+	name = UnitName(name)
+	path, err := m.DropInPath(ctx, name)
+	if err != nil {
+		return "", false, err
+	}
+
+	current := ""
+	data, err := os.ReadFile(path)
+	switch {
+	case err == nil:
+		current = string(data)
+	case !errors.Is(err, os.ErrNotExist):
+		return path, false, fmt.Errorf("failed to read %s: %w", path, classify(err))
+	}
+
+	// The unit file is only shown for reference, so a unit without one can
+	// still get an override
+	var sources []UnitFileSource
+	if f, err := m.GetUnitFile(ctx, name); err == nil {
+		sources = append([]UnitFileSource{f.Fragment}, f.DropIns...)
+	}
+
+	result, changed, err := editText(path, current, sources, edit)
+	if err != nil || !changed {
+		return path, false, err
+	}
+	if result == "" {
+		if err := os.Remove(path); err != nil {
+			return path, false, fmt.Errorf("failed to remove %s: %w", path, classify(err))
+		}
+		// Leave the .d directory if other drop-ins remain in it
+		os.Remove(filepath.Dir(path))
+	} else if err := writeFileAtomic(path, []byte(result)); err != nil {
+		return path, false, err
+	}
+
+	if err := m.conn.ReloadContext(ctx); err != nil {
+		return path, true, fmt.Errorf("failed to reload systemd after editing %s: %w", name, classify(err))
+	}
+	return path, true, nil
+}
+
+// editText lets the user edit a drop-in through a temporary file showing
+// the unit's other sources for reference. It returns the validated new
+// text, empty if the user cleared it.
+func editText(path, current string, sources []UnitFileSource, edit func(path string) error) (string, bool, error) {
+	tmp, err := os.CreateTemp("", "svcm-edit-*.conf")
+	if err != nil {
+		return "", false, fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	_, err = tmp.WriteString(editTemplate(path, current, sources))
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return "", false, fmt.Errorf("failed to write temporary file: %w", err)
+	}
+
+	if err := edit(tmp.Name()); err != nil {
+		return "", false, err
+	}
+	edited, err := os.ReadFile(tmp.Name())
+	if err != nil {
+		return "", false, fmt.Errorf("failed to read edited file: %w", err)
+	}
+
+	result := extractEdit(string(edited))
+	if strings.TrimSpace(result) == strings.TrimSpace(current) {
+		return current, false, nil
+	}
+	if strings.TrimSpace(result) == "" {
+		return "", true, nil
+	}
+	if err := ValidateDropIn(result); err != nil {
+		return "", false, err
+	}
+	return result, true, nil
+}
+
+func editTemplate(path, current string, sources []UnitFileSource) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "### Editing %s\n%s\n\n", path, editStartMarker)
+	if current != "" {
+		b.WriteString(strings.TrimRight(current, "\n"))
+		b.WriteString("\n")
+	}
+	fmt.Fprintf(&b, "\n%s\n", editEndMarker)
+	for _, s := range sources {
+		if s.Path == "" || s.Path == path {
+			continue
+		}
+		fmt.Fprintf(&b, "\n### %s\n", s.Path)
+		scanner := bufio.NewScanner(strings.NewReader(s.Content))
+		for scanner.Scan() {
+			if line := scanner.Text(); line != "" {
+				fmt.Fprintf(&b, "# %s\n", line)
+			} else {
+				b.WriteString("\n")
+			}
+		}
+	}
+	return b.String()
+}
+
+// extractEdit returns the part of the edited file between the markers. If
+// the user deleted them, everything but "###" lines is kept.
+func extractEdit(text string) string {
+	start := strings.Index(text, editStartMarker)
+	end := strings.Index(text, editEndMarker)
+	if start >= 0 && end > start {
+		return strings.TrimSpace(text[start+len(editStartMarker):end]) + "\n"
+	}
+
+	var b strings.Builder
+	for _, line := range strings.Split(text, "\n") {
+		if !strings.HasPrefix(line, "###") {
+			b.WriteString(line)
+			b.WriteString("\n")
+		}
+	}
+	return strings.TrimSpace(b.String()) + "\n"
+}
+
+// writeFileAtomic replaces path by renaming a fully written sibling file
+// over it, so systemd never reads a half written drop-in.
+func writeFileAtomic(path string, data []byte) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("failed to create %s: %w", dir, classify(err))
+	}
+	tmp, err := os.CreateTemp(dir, ".override-*.conf")
+	if err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, classify(err))
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	if err := tmp.Chmod(0o644); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return nil
+}
+
+// Editor returns the command used to edit files: $SYSTEMD_EDITOR, $EDITOR
+// or $VISUAL, falling back to vi. The value may carry arguments.
+func Editor() []string {
+	for _, env := range []string{"SYSTEMD_EDITOR", "EDITOR", "VISUAL"} {
+		if fields := strings.Fields(os.Getenv(env)); len(fields) > 0 {
+			return fields
+		}
+	}
+	return []string{"vi"}
+}
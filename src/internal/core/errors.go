@@ -0,0 +1,130 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
+	godbus "github.com/godbus/dbus/v5"
+)
+
+// Classes of errors returned by Manager methods. Errors keep their
+// message; check the class with errors.Is.
+var (
+	ErrNotFound         = errors.New("unit not found")
+	ErrPermissionDenied = errors.New("permission denied")
+	ErrTimeout          = errors.New("timed out")
+	ErrCanceled         = errors.New("canceled")
+	ErrJobFailed        = errors.New("job failed")
+	ErrDependencyFailed = errors.New("dependency failed")
+)
+
+// ErrorClass names the class of err for machine-readable output:
+// not_found, permission_denied, timeout, canceled, dependency_failed,
+// job_failed, or "" for errors of no particular class. A job that timed
+// out is a timeout rather than a job_failed.
+func ErrorClass(err error) string {
+	switch {
+	case err == nil:
+		return ""
+	case errors.Is(err, ErrNotFound):
+		return "not_found"
+	case errors.Is(err, ErrPermissionDenied):
+		return "permission_denied"
+	case errors.Is(err, ErrTimeout):
+		return "timeout"
+	case errors.Is(err, ErrCanceled):
+		return "canceled"
+	case errors.Is(err, ErrDependencyFailed):
+		return "dependency_failed"
+	case errors.Is(err, ErrJobFailed):
+		return "job_failed"
+	}
+	return ""
+}
+
+// JobError is a job that finished with a result other than "done"
+type JobError struct {
+	Verb   string `json:"verb"`
+	Unit   string `json:"unit"`
+	Result string `json:"result"`
+}
+
+func (e *JobError) Error() string {
+	return fmt.Sprintf("%s job for %s failed with result: %s", e.Verb, e.Unit, e.Result)
+}
+
+// Is makes every JobError an ErrJobFailed, and also an ErrTimeout,
+// ErrDependencyFailed or ErrCanceled for those job results
+func (e *JobError) Is(target error) bool {
+	switch target {
+	case ErrJobFailed:
+		return true
+	case ErrTimeout:
+		return e.Result == "timeout"
+	case ErrDependencyFailed:
+		return e.Result == "dependency"
+	case ErrCanceled:
+		return e.Result == "canceled"
+	}
+	return false
+}
+
+// classError tags an error with its class without changing its message
+type classError struct {
+	class error
+	err   error
+}
+
+func (e *classError) Error() string {
+	return e.err.Error()
+}
+
+func (e *classError) Unwrap() []error {
+	return []error{e.class, e.err}
+}
+
+// dbusErrorClasses maps the D-Bus error names systemd and the bus daemon
+// reply with onto error classes
+var dbusErrorClasses = map[string]error{
+	"org.freedesktop.systemd1.NoSuchUnit":                         ErrNotFound,
+	"org.freedesktop.systemd1.NoSuchJob":                          ErrNotFound,
+	"org.freedesktop.DBus.Error.FileNotFound":                     ErrNotFound,
+	"org.freedesktop.DBus.Error.AccessDenied":                     ErrPermissionDenied,
+	"org.freedesktop.DBus.Error.InteractiveAuthorizationRequired": ErrPermissionDenied,
+	"org.freedesktop.DBus.Error.AuthFailed":                       ErrPermissionDenied,
+	"org.freedesktop.DBus.Error.Timeout":                          ErrTimeout,
+	"org.freedesktop.DBus.Error.NoReply":                          ErrTimeout,
+}
+
+// classify tags bus, context and permission errors with their class
+func classify(err error) error {
+	if err == nil {
+		return nil
+	}
+	var class error
+	var dbusErr godbus.Error
+	var dbusErrPtr *godbus.Error
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		class = ErrTimeout
+	case errors.Is(err, context.Canceled):
+		class = ErrCanceled
+	case errors.Is(err, os.ErrPermission):
+		class = ErrPermissionDenied
+	case errors.As(err, &dbusErr):
+		class = dbusErrorClasses[dbusErr.Name]
+	case errors.As(err, &dbusErrPtr):
+		class = dbusErrorClasses[dbusErrPtr.Name]
+	}
+	if class == nil {
+		return err
+	}
+	return &classError{class: class, err: err}
+}
+
+// notFound returns an ErrNotFound with a message of its own
+func notFound(format string, args ...interface{}) error {
+	return &classError{class: ErrNotFound, err: fmt.Errorf(format, args...)}
+}
@@ -0,0 +1,1383 @@
+package core
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"os/user"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/coreos/go-systemd/v22/unit"
+)
+
+// fakeBootID is the boot every simulated journal entry belongs to
+const fakeBootID = "fakeboot"
+
+// maxFakeLogs bounds the simulated journal
+const maxFakeLogs = 10000
+
+// FakeManager simulates a service manager in memory, for demos and tests
+// of the frontends without a systemd bus. Jobs change unit states right
+// away, emit events to subscribers and write journal entries the way
+// systemd would; nothing on the host is touched.
+type FakeManager struct {
+	mu         sync.Mutex
+	systemMode bool
+	dir        string
+	user       string
+
+	units     map[string]*fakeUnit
+	dropIns   map[string]string
+	logs      []LogEntry
+	cursor    int
+	nextPID   uint32
+	nextJob   uint32
+	subs      map[*Subscription]chan UnitEvent
+	followers map[*fakeLogFilter]*LogStream
+}
+
+type fakeUnit struct {
+	FakeUnit
+	subState      string
+	mainPID       uint32
+	memory        uint64
+	activeSince   time.Time
+	inactiveSince time.Time
+	// cpuNSec is the CPU time used up to cpuAt
+	cpuNSec     uint64
+	cpuAt       time.Time
+	nextElapse  time.Time
+	lastTrigger time.Time
+	// generation counts starts so a scheduled exit of an earlier run is
+	// ignored
+	generation int
+	result     *TransientResult
+	// referenced keeps a finished transient unit around for WaitTransient,
+	// collect unloads it even if it failed
+	referenced bool
+	collect    bool
+	// starting guards against Requires= cycles
+	starting bool
+}
+
+type fakeLogFilter struct {
+	unit     string
+	priority int
+	matcher  *regexp.Regexp
+	ch       chan LogEntry
+}
+
+// NewFakeManager simulates the units of a fixture. Each manager has its
+// own copy of the state.
+func NewFakeManager(fixture *FakeFixture, systemMode bool) (*FakeManager, error) {
+	dir, err := unitDirFor(systemMode)
+	if err != nil {
+		return nil, err
+	}
+	m := &FakeManager{
+		systemMode: systemMode,
+		dir:        dir,
+		user:       "root",
+		units:      map[string]*fakeUnit{},
+		dropIns:    map[string]string{},
+		nextPID:    4000,
+		subs:       map[*Subscription]chan UnitEvent{},
+		followers:  map[*fakeLogFilter]*LogStream{},
+	}
+	if !systemMode {
+		if u, err := user.Current(); err == nil {
+			m.user = u.Username
+		}
+	}
+
+	units := fixture.Units
+	if systemMode && len(fixture.SystemUnits) > 0 {
+		units = fixture.SystemUnits
+	}
+	now := time.Now()
+	for _, spec := range units {
+		u := m.addUnit(spec, now)
+		if u.ActiveState == "active" {
+			u.activeSince = now
+			u.mainPID = m.pickPID(u)
+			if u.Type() == "timer" && u.NextIn > 0 {
+				u.nextElapse = now.Add(u.NextIn)
+			}
+		}
+		if u.LastAgo > 0 {
+			u.lastTrigger = now.Add(-u.LastAgo)
+		}
+		for _, l := range spec.Logs {
+			priority := 6
+			if l.Priority != "" {
+				priority, _ = ParsePriority(l.Priority)
+			}
+			m.logs = append(m.logs, m.entry(u, now.Add(-l.Ago), priority, l.Message, false))
+		}
+	}
+	sort.SliceStable(m.logs, func(i, j int) bool { return m.logs[i].Timestamp.Before(m.logs[j].Timestamp) })
+	for i := range m.logs {
+		m.logs[i].Cursor = m.nextCursor()
+	}
+	return m, nil
+}
+
+// addUnit fills in the defaults of a fixture unit and registers it
+func (m *FakeManager) addUnit(spec FakeUnit, now time.Time) *fakeUnit {
+	u := &fakeUnit{FakeUnit: spec, inactiveSince: now, cpuAt: now}
+	u.Name = UnitName(spec.Name)
+	if u.Description == "" {
+		u.Description = u.Name
+	}
+	if u.ActiveState == "" {
+		u.ActiveState = "inactive"
+	}
+	if u.UnitFileState == "" {
+		u.UnitFileState = "static"
+		if u.Transient {
+			u.UnitFileState = "transient"
+		} else if len(u.WantedBy) > 0 {
+			u.UnitFileState = "disabled"
+		}
+	}
+	if u.Unit == "" && (u.Type() == "timer" || u.Type() == "socket" || u.Type() == "path") {
+		u.Unit = strings.TrimSuffix(u.Name, "."+u.Type()) + ".service"
+	}
+	if u.Fragment == "" {
+		u.Fragment = filepath.Join(m.dir, u.Name)
+		if u.Transient {
+			u.Fragment = filepath.Join("/run/systemd/transient", u.Name)
+		}
+	}
+	u.memory, _ = ParseSize(u.Memory)
+	u.subState = fakeSubState(u.Type(), u.ActiveState)
+	m.units[u.Name] = u
+	return u
+}
+
+func (u *fakeUnit) Type() string {
+	return UnitTypeOf(u.Name)
+}
+
+func (u *fakeUnit) serviceUnit() ServiceUnit {
+	return ServiceUnit{
+		Name:        u.Name,
+		Type:        u.Type(),
+		Description: u.Description,
+		LoadState:   "loaded",
+		ActiveState: u.ActiveState,
+		SubState:    u.subState,
+		Transient:   u.Transient,
+	}
+}
+
+// cpuUsage returns the CPU time used so far, growing by CPU percent while
+// the unit is active
+func (u *fakeUnit) cpuUsage(now time.Time) uint64 {
+	if u.ActiveState != "active" || !now.After(u.cpuAt) {
+		return u.cpuNSec
+	}
+	return u.cpuNSec + uint64(float64(now.Sub(u.cpuAt).Nanoseconds())*u.CPU/100)
+}
+
+// fakeSubState is the sub state systemd reports for a unit type in a state
+func fakeSubState(unitType, active string) string {
+	switch active {
+	case "failed":
+		return "failed"
+	case "inactive":
+		return "dead"
+	}
+	switch unitType {
+	case "service":
+		return "running"
+	case "socket":
+		return "listening"
+	case "timer", "path":
+		return "waiting"
+	case "mount":
+		return "mounted"
+	}
+	return "active"
+}
+
+// Close ends all subscriptions and log streams. The simulated state is
+// kept, so the manager can still be used.
+func (m *FakeManager) Close() {
+	m.mu.Lock()
+	subs := make([]*Subscription, 0, len(m.subs))
+	for s := range m.subs {
+		subs = append(subs, s)
+	}
+	streams := make([]*LogStream, 0, len(m.followers))
+	for _, s := range m.followers {
+		streams = append(streams, s)
+	}
+	m.mu.Unlock()
+
+	for _, s := range subs {
+		s.Close()
+	}
+	for _, s := range streams {
+		s.Close()
+	}
+}
+
+// lookup returns a loaded unit; callers hold m.mu
+func (m *FakeManager) lookup(name string) (*fakeUnit, error) {
+	if u, ok := m.units[name]; ok {
+		return u, nil
+	}
+	return nil, notFound("Unit %s not found.", name)
+}
+
+func (m *FakeManager) pickPID(u *fakeUnit) uint32 {
+	switch {
+	case u.Type() != "service":
+		return 0
+	case u.MainPID != 0:
+		return u.MainPID
+	case len(u.Processes) > 0:
+		return u.Processes[0].PID
+	}
+	m.nextPID++
+	return m.nextPID
+}
+
+func (m *FakeManager) nextCursor() string {
+	m.cursor++
+	return "fake-" + strconv.Itoa(m.cursor)
+}
+
+// entry builds a journal entry of a unit, written by systemd itself or by
+// the unit's main process
+func (m *FakeManager) entry(u *fakeUnit, at time.Time, priority int, message string, fromSystemd bool) LogEntry {
+	e := LogEntry{
+		Timestamp: at,
+		BootID:    fakeBootID,
+		Priority:  priority,
+		Unit:      u.Name,
+		Hostname:  "fake",
+		Message:   message,
+	}
+	if fromSystemd {
+		e.Identifier, e.PID = "systemd", 1
+		return e
+	}
+	e.Identifier = strings.TrimSuffix(u.Name, "."+u.Type())
+	if fields := strings.Fields(u.Command); len(fields) > 0 {
+		e.Identifier = filepath.Base(fields[0])
+	}
+	e.PID = int(u.mainPID)
+	return e
+}
+
+// log appends a journal entry and hands it to the followers it matches
+func (m *FakeManager) log(u *fakeUnit, priority int, format string, args ...interface{}) {
+	e := m.entry(u, time.Now(), priority, fmt.Sprintf(format, args...), true)
+	e.Cursor = m.nextCursor()
+	m.logs = append(m.logs, e)
+	if len(m.logs) > maxFakeLogs {
+		m.logs = m.logs[len(m.logs)-maxFakeLogs:]
+	}
+	for f := range m.followers {
+		if f.matches(e) {
+			select {
+			case f.ch <- e:
+			default:
+				// A reader that fell this far behind loses entries
+			}
+		}
+	}
+}
+
+func (m *FakeManager) emit(ev UnitEvent) {
+	for _, ch := range m.subs {
+		select {
+		case ch <- ev:
+		default:
+		}
+	}
+}
+
+// setState moves a unit to a new active state and tells subscribers
+func (m *FakeManager) setState(u *fakeUnit, active string) {
+	now := time.Now()
+	if u.ActiveState == "active" && active != "active" {
+		u.cpuNSec = u.cpuUsage(now)
+		u.inactiveSince = now
+		u.mainPID = 0
+		u.nextElapse = time.Time{}
+	}
+	if u.ActiveState != "active" && active == "active" {
+		u.activeSince = now
+		u.cpuAt = now
+		u.mainPID = m.pickPID(u)
+		u.generation++
+	}
+	u.ActiveState = active
+	u.subState = fakeSubState(u.Type(), active)
+	m.emit(UnitEvent{Kind: UnitChanged, Unit: u.serviceUnit()})
+}
+
+// job runs a simulated job and reports it like runJob does for systemd.
+// Simulated jobs finish at once, so ctx is only checked before queueing.
+func (m *FakeManager) job(ctx context.Context, verb, name string, run func(u *fakeUnit) (string, error)) error {
+	name = UnitName(name)
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("failed to %s %s: %w", verb, name, classify(err))
+	}
+	// The simulation runs every job immediately, so there are never queued
+	// jobs for the mode to act on; it is only checked
+	if _, err := jobMode(ctx); err != nil {
+		return fmt.Errorf("failed to %s %s: %w", verb, name, err)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	u, err := m.lookup(name)
+	if err != nil {
+		return fmt.Errorf("failed to %s %s: %w", verb, name, err)
+	}
+	result, err := run(u)
+	if err != nil {
+		return fmt.Errorf("failed to %s %s: %w", verb, name, err)
+	}
+	m.nextJob++
+	m.emit(UnitEvent{Kind: JobCompleted, Unit: u.serviceUnit(), JobID: m.nextJob, JobResult: result})
+	if result != "done" {
+		return &JobError{Verb: verb, Unit: name, Result: result}
+	}
+	return nil
+}
+
+func (m *FakeManager) start(u *fakeUnit) (string, error) {
+	if u.UnitFileState == "masked" {
+		return "", fmt.Errorf("Unit %s is masked.", u.Name)
+	}
+	if u.ActiveState == "active" || u.starting {
+		return "done", nil
+	}
+	// Like systemd, a unit is not started when one it requires fails
+	u.starting = true
+	defer func() { u.starting = false }()
+	for _, name := range u.Requires {
+		if dep, ok := m.units[name]; ok {
+			if result, err := m.start(dep); err != nil || result != "done" {
+				m.log(u, 3, "Dependency failed for %s.", u.Description)
+				return "dependency", nil
+			}
+		}
+	}
+	m.log(u, 6, "Starting %s...", u.Description)
+	if u.FailOnStart {
+		m.log(u, 5, "%s: Main process exited, code=exited, status=1/FAILURE", u.Name)
+		m.log(u, 4, "%s: Failed with result 'exit-code'.", u.Name)
+		m.log(u, 3, "Failed to start %s.", u.Description)
+		u.result = &TransientResult{Unit: u.Name, Result: "exit-code", Code: "exited", Status: 1}
+		m.setState(u, "failed")
+		return "failed", nil
+	}
+	m.setState(u, "active")
+	m.log(u, 6, "Started %s.", u.Description)
+	if u.Type() == "timer" && u.NextIn > 0 {
+		u.nextElapse = time.Now().Add(u.NextIn)
+	}
+	if u.RunFor > 0 {
+		generation := u.generation
+		time.AfterFunc(u.RunFor, func() {
+			m.mu.Lock()
+			defer m.mu.Unlock()
+			if u.generation == generation && u.ActiveState == "active" {
+				m.exit(u)
+			}
+		})
+	}
+	return "done", nil
+}
+
+// exit ends a service whose main process finished on its own
+func (m *FakeManager) exit(u *fakeUnit) {
+	runtime := uint64(time.Since(u.activeSince).Microseconds())
+	u.result = &TransientResult{Unit: u.Name, Result: "success", Code: "exited", Status: u.ExitStatus, RuntimeUSec: runtime}
+	if u.ExitStatus == 0 {
+		m.log(u, 6, "%s: Deactivated successfully.", u.Name)
+		m.setState(u, "inactive")
+	} else {
+		u.result.Result = "exit-code"
+		m.log(u, 5, "%s: Main process exited, code=exited, status=%d/FAILURE", u.Name, u.ExitStatus)
+		m.log(u, 4, "%s: Failed with result 'exit-code'.", u.Name)
+		m.setState(u, "failed")
+	}
+	m.collect(u)
+}
+
+// collect unloads a finished transient unit unless someone waits for it
+// or it failed without Collect set
+func (m *FakeManager) collect(u *fakeUnit) {
+	if !u.Transient || u.referenced || u.ActiveState == "active" {
+		return
+	}
+	if u.ActiveState == "failed" && !u.collect {
+		return
+	}
+	delete(m.units, u.Name)
+	m.emit(UnitEvent{Kind: UnitRemoved, Unit: ServiceUnit{Name: u.Name, Type: u.Type()}})
+}
+
+func (m *FakeManager) stop(u *fakeUnit) (string, error) {
+	if u.ActiveState != "active" {
+		return "done", nil
+	}
+	m.log(u, 6, "Stopping %s...", u.Description)
+	m.setState(u, "inactive")
+	m.log(u, 6, "Stopped %s.", u.Description)
+	m.collect(u)
+	return "done", nil
+}
+
+func (m *FakeManager) reload(u *fakeUnit) (string, error) {
+	if u.ActiveState != "active" {
+		return "", fmt.Errorf("Unit %s cannot be reloaded because it is inactive.", u.Name)
+	}
+	if !u.CanReload {
+		return "", fmt.Errorf("Job type reload is not applicable for unit %s.", u.Name)
+	}
+	m.log(u, 6, "Reloading %s...", u.Description)
+	m.log(u, 6, "Reloaded %s.", u.Description)
+	return "done", nil
+}
+
+func (m *FakeManager) restart(u *fakeUnit) (string, error) {
+	if _, err := m.stop(u); err != nil {
+		return "", err
+	}
+	return m.start(u)
+}
+
+// ListServices lists the simulated service units
+func (m *FakeManager) ListServices(ctx context.Context) ([]ServiceUnit, error) {
+	return m.ListUnits(ctx, "service")
+}
+
+// ListUnits lists the simulated units of the given types, or of all types
+// in UnitTypes when none are given
+func (m *FakeManager) ListUnits(ctx context.Context, types ...string) ([]ServiceUnit, error) {
+	if len(types) == 0 {
+		types = UnitTypes
+	}
+	wanted := map[string]bool{}
+	for _, t := range types {
+		if !isKnownType(t) {
+			return nil, fmt.Errorf("unknown unit type %q", t)
+		}
+		wanted[t] = true
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.elapseTimers(time.Now())
+	list := make([]ServiceUnit, 0, len(m.units))
+	for _, u := range m.units {
+		if wanted[u.Type()] {
+			list = append(list, u.serviceUnit())
+		}
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list, nil
+}
+
+func (m *FakeManager) StartService(ctx context.Context, name string) error {
+	return m.job(ctx, "start", name, m.start)
+}
+
+func (m *FakeManager) StopService(ctx context.Context, name string) error {
+	return m.job(ctx, "stop", name, m.stop)
+}
+
+func (m *FakeManager) RestartService(ctx context.Context, name string) error {
+	return m.job(ctx, "restart", name, m.restart)
+}
+
+func (m *FakeManager) ReloadService(ctx context.Context, name string) error {
+	return m.job(ctx, "reload", name, m.reload)
+}
+
+func (m *FakeManager) ReloadOrRestartService(ctx context.Context, name string) error {
+	return m.job(ctx, "reload-or-restart", name, func(u *fakeUnit) (string, error) {
+		if u.CanReload && u.ActiveState == "active" {
+			return m.reload(u)
+		}
+		return m.restart(u)
+	})
+}
+
+func (m *FakeManager) TryRestartService(ctx context.Context, name string) error {
+	return m.job(ctx, "try-restart", name, func(u *fakeUnit) (string, error) {
+		if u.ActiveState != "active" {
+			return "done", nil
+		}
+		return m.restart(u)
+	})
+}
+
+func (m *FakeManager) ResetFailedService(ctx context.Context, name string) error {
+	name = UnitName(name)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	u, err := m.lookup(name)
+	if err != nil {
+		return fmt.Errorf("failed to reset %s: %w", name, err)
+	}
+	m.resetFailed(u)
+	return nil
+}
+
+func (m *FakeManager) resetFailed(u *fakeUnit) {
+	if u.ActiveState == "failed" {
+		m.setState(u, "inactive")
+		if u.Transient {
+			delete(m.units, u.Name)
+			m.emit(UnitEvent{Kind: UnitRemoved, Unit: ServiceUnit{Name: u.Name, Type: u.Type()}})
+		}
+	}
+}
+
+func (m *FakeManager) ResetAllFailed(ctx context.Context) ([]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var names []string
+	for _, u := range m.units {
+		if u.ActiveState == "failed" {
+			names = append(names, u.Name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		m.resetFailed(m.units[name])
+	}
+	return names, nil
+}
+
+// GetServiceDetails reports unknown units as not-found, like systemd does
+func (m *FakeManager) GetServiceDetails(ctx context.Context, name string) (*ServiceDetails, error) {
+	name = UnitName(name)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	u, ok := m.units[name]
+	if !ok {
+		return &ServiceDetails{ServiceUnit: ServiceUnit{
+			Name:        name,
+			Type:        UnitTypeOf(name),
+			LoadState:   "not-found",
+			ActiveState: "inactive",
+			SubState:    "dead",
+		}}, nil
+	}
+
+	now := time.Now()
+	d := &ServiceDetails{
+		ServiceUnit:            u.serviceUnit(),
+		MainPID:                u.mainPID,
+		FragmentPath:           u.Fragment,
+		UnitFileState:          u.UnitFileState,
+		InactiveEnterTimestamp: uint64(u.inactiveSince.UnixMicro()),
+	}
+	if u.ActiveState == "active" {
+		d.ActiveEnterTimestamp = uint64(u.activeSince.UnixMicro())
+		if _, ok := cgroupInterfaces[u.Type()]; ok {
+			d.ResourceUsage = m.usage(u, now)
+			d.ControlGroup = m.controlGroup(u)
+			d.Processes = m.processes(u, now)
+		}
+	}
+
+	switch u.Type() {
+	case "socket":
+		d.Socket = &SocketDetails{Listen: []TypedValue{}, Triggers: []string{u.Unit}}
+		for _, l := range u.Listen {
+			d.Socket.Listen = append(d.Socket.Listen, TypedValue{Type: "Stream", Value: l})
+		}
+	case "path":
+		d.Path = &PathDetails{Conditions: []TypedValue{}, Triggers: []string{u.Unit}}
+	case "mount":
+		where := "/" + strings.ReplaceAll(strings.TrimSuffix(u.Name, ".mount"), "-", "/")
+		if u.Name == "-.mount" {
+			where = "/"
+		}
+		d.Mount = &MountDetails{Where: where}
+	case "target":
+		props := m.properties(u.Name)
+		d.Target = &TargetDetails{}
+		d.Target.Wants, _ = props["Wants"].([]string)
+		d.Target.Requires, _ = props["Requires"].([]string)
+		d.Target.WantedBy, _ = props["WantedBy"].([]string)
+	}
+	return d, nil
+}
+
+func (m *FakeManager) controlGroup(u *fakeUnit) string {
+	if m.systemMode {
+		return "/system.slice/" + u.Name
+	}
+	return "/user.slice/app.slice/" + u.Name
+}
+
+func (m *FakeManager) usage(u *fakeUnit, now time.Time) ResourceUsage {
+	if u.ActiveState != "active" {
+		return ResourceUsage{CPUUsageNSec: u.cpuNSec}
+	}
+	tasks := u.Tasks
+	if tasks == 0 {
+		tasks = uint64(len(m.processes(u, now)))
+	}
+	return ResourceUsage{MemoryCurrent: u.memory, CPUUsageNSec: u.cpuUsage(now), TasksCurrent: tasks}
+}
+
+// processes lists the fixture's processes, or just the main process
+func (m *FakeManager) processes(u *fakeUnit, now time.Time) []Process {
+	if u.ActiveState != "active" {
+		return nil
+	}
+	var procs []Process
+	for _, p := range u.Processes {
+		rss, _ := ParseSize(p.RSS)
+		owner := p.User
+		if owner == "" {
+			owner = m.user
+		}
+		procs = append(procs, Process{PID: p.PID, PPID: p.PPID, Command: p.Command, User: owner, RSSBytes: rss})
+	}
+	if len(procs) == 0 && u.mainPID != 0 {
+		command := u.Command
+		if command == "" {
+			command = strings.TrimSuffix(u.Name, ".service")
+		}
+		procs = append(procs, Process{PID: u.mainPID, PPID: 1, Command: command, User: m.user, RSSBytes: u.memory})
+	}
+	// The main process gets all of the unit's CPU time
+	for i := range procs {
+		if procs[i].PID == u.mainPID {
+			procs[i].CPUTimeNSec = u.cpuUsage(now)
+		}
+	}
+	return procs
+}
+
+func (m *FakeManager) EnableService(ctx context.Context, name string, now bool) ([]UnitFileChange, error) {
+	name = UnitName(name)
+	m.mu.Lock()
+	u, err := m.lookup(name)
+	if err != nil {
+		m.mu.Unlock()
+		return nil, fmt.Errorf("failed to enable service %s: %w", name, err)
+	}
+	if u.UnitFileState == "masked" {
+		m.mu.Unlock()
+		return nil, fmt.Errorf("failed to enable service %s: Unit file %s is masked.", name, name)
+	}
+	changes := []UnitFileChange{}
+	if u.UnitFileState != "enabled" && len(u.WantedBy) > 0 {
+		for _, target := range u.WantedBy {
+			changes = append(changes, UnitFileChange{
+				Type:        "symlink",
+				Filename:    filepath.Join(m.dir, target+".wants", name),
+				Destination: u.Fragment,
+			})
+		}
+		u.UnitFileState = "enabled"
+	}
+	m.mu.Unlock()
+
+	if now {
+		return changes, m.StartService(ctx, name)
+	}
+	return changes, nil
+}
+
+func (m *FakeManager) DisableService(ctx context.Context, name string, now bool) ([]UnitFileChange, error) {
+	name = UnitName(name)
+	m.mu.Lock()
+	u, err := m.lookup(name)
+	if err != nil {
+		m.mu.Unlock()
+		return nil, fmt.Errorf("failed to disable service %s: %w", name, err)
+	}
+	changes := []UnitFileChange{}
+	if u.UnitFileState == "enabled" {
+		for _, target := range u.WantedBy {
+			changes = append(changes, UnitFileChange{Type: "unlink", Filename: filepath.Join(m.dir, target+".wants", name)})
+		}
+		u.UnitFileState = "disabled"
+	}
+	m.mu.Unlock()
+
+	if now {
+		return changes, m.StopService(ctx, name)
+	}
+	return changes, nil
+}
+
+func (m *FakeManager) MaskService(ctx context.Context, name string, now bool) ([]UnitFileChange, error) {
+	name = UnitName(name)
+	m.mu.Lock()
+	u, err := m.lookup(name)
+	if err != nil {
+		m.mu.Unlock()
+		return nil, fmt.Errorf("failed to mask service %s: %w", name, err)
+	}
+	changes := []UnitFileChange{}
+	if u.UnitFileState != "masked" {
+		changes = append(changes, UnitFileChange{Type: "symlink", Filename: filepath.Join(m.dir, name), Destination: "/dev/null"})
+		u.UnitFileState = "masked"
+	}
+	m.mu.Unlock()
+
+	if now {
+		return changes, m.StopService(ctx, name)
+	}
+	return changes, nil
+}
+
+func (m *FakeManager) UnmaskService(ctx context.Context, name string) ([]UnitFileChange, error) {
+	name = UnitName(name)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	u, err := m.lookup(name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmask service %s: %w", name, err)
+	}
+	changes := []UnitFileChange{}
+	if u.UnitFileState == "masked" {
+		changes = append(changes, UnitFileChange{Type: "unlink", Filename: filepath.Join(m.dir, name)})
+		u.UnitFileState = "static"
+		if len(u.WantedBy) > 0 {
+			u.UnitFileState = "disabled"
+		}
+	}
+	return changes, nil
+}
+
+// Subscribe streams the events of simulated jobs. Events are dropped for a
+// subscriber that does not keep up.
+func (m *FakeManager) Subscribe(ctx context.Context) (*Subscription, error) {
+	events := make(chan UnitEvent, 64)
+	sub := &Subscription{Events: events, done: make(chan struct{})}
+	sub.release = func() {
+		m.mu.Lock()
+		delete(m.subs, sub)
+		close(events)
+		m.mu.Unlock()
+	}
+	m.mu.Lock()
+	m.subs[sub] = events
+	m.mu.Unlock()
+	return sub, nil
+}
+
+// GetLogs filters the simulated journal. Everything belongs to the current
+// boot; journal files and directories cannot be read.
+func (m *FakeManager) GetLogs(ctx context.Context, q LogQuery) ([]LogEntry, error) {
+	f, err := newFakeFollower(q)
+	if err != nil {
+		return nil, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.matchingLogs(q, f), nil
+}
+
+func (m *FakeManager) matchingLogs(q LogQuery, f *fakeLogFilter) []LogEntry {
+	entries := []LogEntry{}
+	for _, e := range m.logs {
+		if !q.Since.IsZero() && e.Timestamp.Before(q.Since) {
+			continue
+		}
+		if !q.Until.IsZero() && e.Timestamp.After(q.Until) {
+			continue
+		}
+		if f.matches(e) {
+			entries = append(entries, e)
+		}
+	}
+	if q.Lines > 0 && len(entries) > q.Lines {
+		entries = entries[len(entries)-q.Lines:]
+	}
+	return entries
+}
+
+func newFakeFollower(q LogQuery) (*fakeLogFilter, error) {
+	if q.Directory != "" || len(q.Files) > 0 {
+		return nil, fmt.Errorf("the fake backend cannot read journal files")
+	}
+	f := &fakeLogFilter{priority: len(priorityNames) - 1}
+	if q.Unit != "" {
+		f.unit = UnitName(q.Unit)
+	}
+	if q.Priority != "" {
+		p, err := ParsePriority(q.Priority)
+		if err != nil {
+			return nil, err
+		}
+		f.priority = p
+	}
+	matcher, err := newLogMatcher(q)
+	if err != nil {
+		return nil, err
+	}
+	f.matcher = matcher
+	// Other boots have no entries
+	if q.Boot != "" && q.Boot != "current" && q.Boot != fakeBootID {
+		f.priority = -1
+	}
+	return f, nil
+}
+
+func (f *fakeLogFilter) matches(e LogEntry) bool {
+	return (f.unit == "" || e.Unit == f.unit) && e.Priority <= f.priority &&
+		(f.matcher == nil || f.matcher.MatchString(e.Message))
+}
+
+// FollowLogs sends the newest q.Lines entries and then the ones simulated
+// jobs write.
+func (m *FakeManager) FollowLogs(ctx context.Context, q LogQuery) (*LogStream, error) {
+	q.Until = time.Time{}
+	f, err := newFakeFollower(q)
+	if err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	backlog := m.matchingLogs(q, f)
+	f.ch = make(chan LogEntry, len(backlog)+256)
+	for _, e := range backlog {
+		f.ch <- e
+	}
+	stream := &LogStream{Entries: f.ch, done: make(chan struct{})}
+	m.followers[f] = stream
+
+	go func() {
+		<-stream.done
+		m.mu.Lock()
+		delete(m.followers, f)
+		close(f.ch)
+		m.mu.Unlock()
+	}()
+	return stream, nil
+}
+
+// GetUnitFile returns the fixture's unit file, or one generated from the
+// unit's settings, with the drop-ins edited so far
+func (m *FakeManager) GetUnitFile(ctx context.Context, name string) (*UnitFile, error) {
+	name = UnitName(name)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	u, ok := m.units[name]
+	if !ok {
+		return nil, notFound("unit %s has no unit file", name)
+	}
+	f := &UnitFile{
+		Name:     name,
+		Fragment: UnitFileSource{Path: u.Fragment, Content: u.File},
+		DropIns:  []UnitFileSource{},
+	}
+	if f.Fragment.Content == "" {
+		f.Fragment.Content = u.generateFile()
+	}
+	if text, ok := m.dropIns[name]; ok {
+		path := filepath.Join(m.dir, name+".d", "override.conf")
+		f.DropIns = append(f.DropIns, UnitFileSource{Path: path, Content: text})
+	}
+	return f, nil
+}
+
+func (u *fakeUnit) generateFile() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "[Unit]\nDescription=%s\n", u.Description)
+	for _, dep := range []struct {
+		key   string
+		units []string
+	}{{"Requires", u.Requires}, {"Wants", u.Wants}, {"After", u.After}} {
+		if len(dep.units) > 0 {
+			fmt.Fprintf(&b, "%s=%s\n", dep.key, strings.Join(dep.units, " "))
+		}
+	}
+
+	switch u.Type() {
+	case "service":
+		b.WriteString("\n[Service]\n")
+		if u.Command != "" {
+			fmt.Fprintf(&b, "ExecStart=%s\n", u.Command)
+		}
+		if u.CanReload {
+			b.WriteString("ExecReload=/bin/kill -HUP $MAINPID\n")
+		}
+	case "timer":
+		b.WriteString("\n[Timer]\n")
+		if u.NextIn > 0 {
+			fmt.Fprintf(&b, "OnUnitActiveSec=%s\n", u.NextIn)
+		}
+		fmt.Fprintf(&b, "Unit=%s\n", u.Unit)
+	case "socket":
+		b.WriteString("\n[Socket]\n")
+		for _, l := range u.Listen {
+			fmt.Fprintf(&b, "ListenStream=%s\n", l)
+		}
+	}
+
+	if len(u.WantedBy) > 0 {
+		fmt.Fprintf(&b, "\n[Install]\nWantedBy=%s\n", strings.Join(u.WantedBy, " "))
+	}
+	return b.String()
+}
+
+// elapseTimers fires the active timers whose time has come; callers hold
+// m.mu. Timers only advance when they are looked at.
+func (m *FakeManager) elapseTimers(now time.Time) {
+	for _, u := range m.units {
+		if u.Type() != "timer" || u.ActiveState != "active" || u.nextElapse.IsZero() || u.nextElapse.After(now) {
+			continue
+		}
+		u.lastTrigger = u.nextElapse
+		for !u.nextElapse.After(now) {
+			u.nextElapse = u.nextElapse.Add(u.NextIn)
+		}
+		if target, ok := m.units[u.Unit]; ok {
+			m.start(target)
+		}
+	}
+}
+
+func (m *FakeManager) timer(u *fakeUnit) TimerUnit {
+	t := TimerUnit{
+		ServiceUnit:  u.serviceUnit(),
+		Unit:         u.Unit,
+		AccuracyUSec: uint64(time.Minute.Microseconds()),
+		Triggers:     []string{},
+	}
+	if !u.nextElapse.IsZero() {
+		t.NextElapseUSec = uint64(u.nextElapse.UnixMicro())
+	}
+	if !u.lastTrigger.IsZero() {
+		t.LastTriggerUSec = uint64(u.lastTrigger.UnixMicro())
+	}
+	if u.NextIn > 0 {
+		t.Triggers = append(t.Triggers, fmt.Sprintf("OnUnitActiveSec=%s", u.NextIn))
+	}
+	return t
+}
+
+func (m *FakeManager) ListTimers(ctx context.Context) ([]TimerUnit, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.elapseTimers(time.Now())
+	timers := []TimerUnit{}
+	for _, u := range m.units {
+		if u.Type() == "timer" {
+			timers = append(timers, m.timer(u))
+		}
+	}
+	sort.Slice(timers, func(i, j int) bool { return timers[i].Name < timers[j].Name })
+	sortTimers(timers)
+	return timers, nil
+}
+
+func (m *FakeManager) GetTimer(ctx context.Context, name string) (*TimerUnit, error) {
+	name = ensureTimerSuffix(name)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.elapseTimers(time.Now())
+	u, ok := m.units[name]
+	if !ok {
+		return nil, notFound("timer %s not found", name)
+	}
+	t := m.timer(u)
+	return &t, nil
+}
+
+func (m *FakeManager) TriggerTimer(ctx context.Context, name string) (string, error) {
+	t, err := m.GetTimer(ctx, name)
+	if err != nil {
+		return "", err
+	}
+	if err := m.StartService(ctx, t.Unit); err != nil {
+		return "", fmt.Errorf("failed to start %s for timer %s: %w", t.Unit, t.Name, err)
+	}
+	m.mu.Lock()
+	if u, ok := m.units[t.Name]; ok {
+		u.lastTrigger = time.Now()
+	}
+	m.mu.Unlock()
+	return t.Unit, nil
+}
+
+func (m *FakeManager) Dependencies(ctx context.Context, name string, q DependencyQuery) (*DependencyNode, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return resolveDependencies(name, q, func(n string) (map[string]interface{}, error) {
+		return m.properties(n), nil
+	})
+}
+
+// properties builds the dependency properties systemd would report for a
+// unit; callers hold m.mu. Enabled units are wanted by their WantedBy
+// targets.
+func (m *FakeManager) properties(name string) map[string]interface{} {
+	props := map[string]interface{}{"ActiveState": "inactive"}
+	wants := func(u *fakeUnit) []string {
+		list := append([]string(nil), u.Wants...)
+		for _, other := range m.units {
+			if other.UnitFileState == "enabled" && contains(other.WantedBy, u.Name) {
+				list = append(list, other.Name)
+			}
+		}
+		sort.Strings(list)
+		return list
+	}
+
+	if u, ok := m.units[name]; ok {
+		props["ActiveState"] = u.ActiveState
+		props["Requires"] = u.Requires
+		props["Wants"] = wants(u)
+		props["After"] = u.After
+	}
+	var requiredBy, wantedBy, before []string
+	for _, other := range m.units {
+		if contains(other.Requires, name) {
+			requiredBy = append(requiredBy, other.Name)
+		}
+		if contains(other.After, name) {
+			before = append(before, other.Name)
+		}
+		if contains(other.Wants, name) {
+			wantedBy = append(wantedBy, other.Name)
+		}
+	}
+	if u, ok := m.units[name]; ok && u.UnitFileState == "enabled" {
+		wantedBy = append(wantedBy, u.WantedBy...)
+	}
+	sort.Strings(requiredBy)
+	sort.Strings(wantedBy)
+	sort.Strings(before)
+	props["RequiredBy"] = requiredBy
+	props["WantedBy"] = wantedBy
+	props["Before"] = before
+	return props
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *FakeManager) DropInPath(ctx context.Context, name string) (string, error) {
+	return filepath.Join(m.dir, UnitName(name)+".d", "override.conf"), nil
+}
+
+// EditDropIn keeps the edited override in memory
+func (m *FakeManager) EditDropIn(ctx context.Context, name string, edit func(path string) error) (string, bool, error) {
+	name = UnitName(name)
+	path, _ := m.DropInPath(ctx, name)
+	m.mu.Lock()
+	current := m.dropIns[name]
+	m.mu.Unlock()
+
+	var sources []UnitFileSource
+	if f, err := m.GetUnitFile(ctx, name); err == nil {
+		sources = append([]UnitFileSource{f.Fragment}, f.DropIns...)
+	}
+	result, changed, err := editText(path, current, sources, edit)
+	if err != nil || !changed {
+		return path, false, err
+	}
+
+	m.mu.Lock()
+	if result == "" {
+		delete(m.dropIns, name)
+	} else {
+		m.dropIns[name] = result
+	}
+	m.mu.Unlock()
+	return path, true, nil
+}
+
+func (m *FakeManager) RenderUnits(ctx context.Context, template string, spec UnitSpec) ([]UnitFileSource, error) {
+	return renderUnits(m.dir, m.systemMode, template, spec)
+}
+
+// InstallUnits loads the units into the simulation, taking the settings
+// the simulation knows from their [Unit], [Service] and [Install] sections
+func (m *FakeManager) InstallUnits(ctx context.Context, units []UnitFileSource, overwrite bool) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if !overwrite {
+		for _, src := range units {
+			if _, ok := m.units[filepath.Base(src.Path)]; ok {
+				return fmt.Errorf("%s already exists", src.Path)
+			}
+		}
+	}
+
+	now := time.Now()
+	for _, src := range units {
+		opts, err := unit.DeserializeOptions(strings.NewReader(src.Content))
+		if err != nil {
+			return fmt.Errorf("invalid unit file %s: %w", src.Path, err)
+		}
+		spec := FakeUnit{Name: filepath.Base(src.Path), Fragment: src.Path, File: src.Content}
+		for _, o := range opts {
+			switch o.Section + "." + o.Name {
+			case "Unit.Description":
+				spec.Description = o.Value
+			case "Unit.Requires":
+				spec.Requires = append(spec.Requires, strings.Fields(o.Value)...)
+			case "Unit.Wants":
+				spec.Wants = append(spec.Wants, strings.Fields(o.Value)...)
+			case "Unit.After":
+				spec.After = append(spec.After, strings.Fields(o.Value)...)
+			case "Install.WantedBy":
+				spec.WantedBy = append(spec.WantedBy, strings.Fields(o.Value)...)
+			case "Service.ExecStart":
+				spec.Command = o.Value
+			case "Service.ExecReload":
+				spec.CanReload = true
+			case "Timer.Unit", "Socket.Service":
+				spec.Unit = o.Value
+			case "Socket.ListenStream", "Socket.ListenDatagram":
+				spec.Listen = append(spec.Listen, o.Value)
+			}
+			if o.Section == "Timer" && strings.HasPrefix(o.Name, "On") {
+				// Calendar expressions are not evaluated, the timer fires hourly
+				spec.NextIn = time.Hour
+				if d, err := time.ParseDuration(o.Value); err == nil && d > 0 {
+					spec.NextIn = d
+				}
+			}
+		}
+		if old, ok := m.units[spec.Name]; ok {
+			// Reinstalling keeps the runtime state, like a daemon-reload
+			spec.ActiveState, spec.UnitFileState = old.ActiveState, old.UnitFileState
+			u := m.addUnit(spec, now)
+			u.subState, u.mainPID, u.activeSince, u.generation = old.subState, old.mainPID, old.activeSince, old.generation
+			continue
+		}
+		u := m.addUnit(spec, now)
+		m.emit(UnitEvent{Kind: UnitAdded, Unit: u.serviceUnit()})
+	}
+	return nil
+}
+
+// RunTransient simulates a transient service. The command is not run: it
+// takes the seconds given to sleep, or two seconds, and false fails.
+func (m *FakeManager) RunTransient(ctx context.Context, spec TransientSpec) (string, error) {
+	if len(spec.Command) == 0 {
+		return "", fmt.Errorf("no command given")
+	}
+	if spec.Wait && spec.OnCalendar != "" {
+		return "", fmt.Errorf("cannot wait for a command run from a timer")
+	}
+	name := spec.Name
+	if name == "" {
+		suffix := make([]byte, 4)
+		if _, err := rand.Read(suffix); err != nil {
+			return "", fmt.Errorf("failed to generate a unit name: %w", err)
+		}
+		name = "run-r" + hex.EncodeToString(suffix)
+	}
+	name = strings.TrimSuffix(name, ".service")
+	if !unitNamePattern.MatchString(name) {
+		return "", fmt.Errorf("invalid unit name %q", name)
+	}
+	if spec.Description == "" {
+		spec.Description = "[svcm run] " + strings.Join(spec.Command, " ")
+	}
+
+	service := FakeUnit{
+		Name:        name + ".service",
+		Description: spec.Description,
+		Transient:   true,
+		Command:     strings.Join(spec.Command, " "),
+		RunFor:      2 * time.Second,
+	}
+	if filepath.Base(spec.Command[0]) == "sleep" && len(spec.Command) > 1 {
+		if d, err := time.ParseDuration(spec.Command[1] + "s"); err == nil {
+			service.RunFor = d
+		}
+	}
+	if filepath.Base(spec.Command[0]) == "false" {
+		service.RunFor, service.ExitStatus = 100*time.Millisecond, 1
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, n := range []string{name + ".service", name + ".timer"} {
+		if _, ok := m.units[n]; ok {
+			return "", fmt.Errorf("failed to start transient unit %s: Unit %s was already loaded or has a fragment file.", n, n)
+		}
+	}
+
+	now := time.Now()
+	u := m.addUnit(service, now)
+	u.collect = spec.Collect
+	m.emit(UnitEvent{Kind: UnitAdded, Unit: u.serviceUnit()})
+	if spec.OnCalendar != "" {
+		// Calendar expressions are not evaluated, the timer fires hourly
+		timer := m.addUnit(FakeUnit{
+			Name:        name + ".timer",
+			Description: spec.Description,
+			Transient:   true,
+			NextIn:      time.Hour,
+		}, now)
+		m.emit(UnitEvent{Kind: UnitAdded, Unit: timer.serviceUnit()})
+		m.start(timer)
+		return timer.Name, nil
+	}
+	u.referenced = spec.Wait
+	if result, _ := m.start(u); result != "done" {
+		return u.Name, &JobError{Verb: "start", Unit: u.Name, Result: result}
+	}
+	return u.Name, nil
+}
+
+func (m *FakeManager) WaitTransient(ctx context.Context, name string) (*TransientResult, error) {
+	name = UnitName(name)
+	for {
+		m.mu.Lock()
+		u, err := m.lookup(name)
+		if err != nil {
+			m.mu.Unlock()
+			return nil, fmt.Errorf("failed to get properties for %s: %w", name, err)
+		}
+		if u.ActiveState != "active" {
+			result := &TransientResult{Unit: name, Result: "success"}
+			if u.result != nil {
+				result = u.result
+			}
+			u.referenced = false
+			m.collect(u)
+			m.mu.Unlock()
+			return result, nil
+		}
+		m.mu.Unlock()
+		select {
+		case <-time.After(200 * time.Millisecond):
+		case <-ctx.Done():
+			return nil, fmt.Errorf("gave up waiting for %s: %w", name, classify(ctx.Err()))
+		}
+	}
+}
+
+func (m *FakeManager) GetUsage(ctx context.Context, names []string) (map[string]ResourceUsage, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	usage := make(map[string]ResourceUsage, len(names))
+	for _, name := range names {
+		name = UnitName(name)
+		if _, ok := cgroupInterfaces[UnitTypeOf(name)]; !ok {
+			continue
+		}
+		if u, ok := m.units[name]; ok {
+			usage[name] = m.usage(u, now)
+		}
+	}
+	return usage, nil
+}
+
+func (m *FakeManager) GetProcesses(ctx context.Context, name string) ([]Process, error) {
+	name = UnitName(name)
+	if _, ok := cgroupInterfaces[UnitTypeOf(name)]; !ok {
+		return nil, fmt.Errorf("%s units have no processes", UnitTypeOf(name))
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	u, err := m.lookup(name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get properties for %s: %w", name, err)
+	}
+	return m.processes(u, time.Now()), nil
+}
+
+func (m *FakeManager) SignalProcess(ctx context.Context, name string, pid uint32, sig syscall.Signal) error {
+	procs, err := m.GetProcesses(ctx, name)
+	if err != nil {
+		return err
+	}
+	name = UnitName(name)
+	for _, p := range procs {
+		if p.PID != pid {
+			continue
+		}
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		if u, ok := m.units[name]; ok && u.ActiveState == "active" {
+			m.signal(u, sig, pid == u.mainPID)
+		}
+		return nil
+	}
+	return notFound("process %d is not part of %s", pid, name)
+}
+
+func (m *FakeManager) KillService(ctx context.Context, name, who string, sig syscall.Signal) error {
+	name = UnitName(name)
+	if err := CheckKillWho(who); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	u, err := m.lookup(name)
+	if err != nil {
+		return fmt.Errorf("failed to send %s to %s: %w", SignalName(sig), name, err)
+	}
+	switch {
+	case who == "control":
+		return fmt.Errorf("failed to send %s to %s: No control process to kill", SignalName(sig), name)
+	case u.ActiveState != "active" || u.mainPID == 0:
+		if who == "main" {
+			return fmt.Errorf("failed to send %s to %s: No main process to kill", SignalName(sig), name)
+		}
+		return nil
+	}
+	m.signal(u, sig, true)
+	return nil
+}
+
+// signal simulates how a process reacts to a signal. Terminating the main
+// process ends the unit; SIGTERM and SIGINT count as a clean exit.
+func (m *FakeManager) signal(u *fakeUnit, sig syscall.Signal, mainProcess bool) {
+	switch sig {
+	case syscall.SIGTERM, syscall.SIGINT, syscall.SIGKILL, syscall.SIGQUIT, syscall.SIGABRT:
+	default:
+		m.log(u, 6, "%s: Received %s", u.Name, SignalName(sig))
+		return
+	}
+	if !mainProcess {
+		return
+	}
+
+	runtime := uint64(time.Since(u.activeSince).Microseconds())
+	u.result = &TransientResult{Unit: u.Name, Result: "success", Code: "killed", Status: int(sig), RuntimeUSec: runtime}
+	name := strings.TrimPrefix(SignalName(sig), "SIG")
+	if sig == syscall.SIGTERM || sig == syscall.SIGINT {
+		m.log(u, 6, "%s: Deactivated successfully.", u.Name)
+		m.setState(u, "inactive")
+	} else {
+		u.result.Result = "signal"
+		m.log(u, 5, "%s: Main process exited, code=killed, status=%d/%s", u.Name, sig, name)
+		m.log(u, 4, "%s: Failed with result 'signal'.", u.Name)
+		m.setState(u, "failed")
+	}
+	m.collect(u)
+}
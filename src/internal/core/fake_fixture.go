@@ -0,0 +1,180 @@
+package core
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// FakeFixture describes the units a FakeManager simulates, e.g.:
+//
+//	units:
+//	  - name: web.service
+//	    description: Demo web server
+//	    active_state: active
+//	    unit_file_state: enabled
+//	    wanted_by: [default.target]
+//	    command: /usr/bin/python3 -m http.server 8080
+//	    can_reload: true
+//	    memory: 48M
+//	    cpu: 3.5
+//	    logs:
+//	      - {ago: 2h, message: "Serving HTTP on 0.0.0.0 port 8080"}
+//	  - name: backup.timer
+//	    active_state: active
+//	    next_in: 45m
+//	  - name: backup.service
+//	    command: /usr/bin/restic backup /home
+//	    run_for: 5s
+//	  - name: broken.service
+//	    active_state: failed
+//	    fail_on_start: true
+//
+// SystemUnits, when given, are used instead of Units for the system
+// instance.
+type FakeFixture struct {
+	Units       []FakeUnit `yaml:"units"`
+	SystemUnits []FakeUnit `yaml:"system_units"`
+}
+
+// FakeUnit is one simulated unit. Only Name is required; names without a
+// suffix are services.
+type FakeUnit struct {
+	Name        string `yaml:"name"`
+	Description string `yaml:"description"`
+	// ActiveState is active, inactive (the default) or failed
+	ActiveState string `yaml:"active_state"`
+	// UnitFileState defaults to transient for transient units, disabled
+	// for units with WantedBy and static otherwise
+	UnitFileState string   `yaml:"unit_file_state"`
+	Transient     bool     `yaml:"transient"`
+	WantedBy      []string `yaml:"wanted_by"`
+	Requires      []string `yaml:"requires"`
+	Wants         []string `yaml:"wants"`
+	After         []string `yaml:"after"`
+	// Fragment and File are the unit file path and content; both are
+	// generated when empty
+	Fragment string `yaml:"fragment"`
+	File     string `yaml:"file"`
+
+	// Command is the main process of a service
+	Command string `yaml:"command"`
+	MainPID uint32 `yaml:"main_pid"`
+	// FailOnStart makes every start job fail and leaves the unit failed
+	FailOnStart bool `yaml:"fail_on_start"`
+	// CanReload allows reload jobs, like a service with ExecReload=
+	CanReload bool `yaml:"can_reload"`
+	// RunFor makes the service exit on its own after running this long,
+	// with ExitStatus; zero runs until stopped
+	RunFor     time.Duration `yaml:"run_for"`
+	ExitStatus int           `yaml:"exit_status"`
+
+	// Memory (e.g. "64M"), CPU in percent of one CPU and Tasks are the
+	// resource usage reported while the unit is active
+	Memory    string        `yaml:"memory"`
+	CPU       float64       `yaml:"cpu"`
+	Tasks     uint64        `yaml:"tasks"`
+	Processes []FakeProcess `yaml:"processes"`
+
+	// Unit is the unit a timer, socket or path activates, by default the
+	// service of the same name
+	Unit string `yaml:"unit"`
+	// NextIn and LastAgo place a timer's next and last elapse relative to
+	// the time the fixture is loaded; an active timer elapses every NextIn
+	NextIn  time.Duration `yaml:"next_in"`
+	LastAgo time.Duration `yaml:"last_ago"`
+	// Listen are the addresses of a socket
+	Listen []string `yaml:"listen"`
+
+	// Logs are journal entries written before the fixture was loaded
+	Logs []FakeLog `yaml:"logs"`
+}
+
+// FakeProcess is a process shown in a simulated unit's control group
+type FakeProcess struct {
+	PID     uint32 `yaml:"pid"`
+	PPID    uint32 `yaml:"ppid"`
+	Command string `yaml:"command"`
+	User    string `yaml:"user"`
+	// RSS is a size such as "12M"
+	RSS string `yaml:"rss"`
+}
+
+// FakeLog is a journal entry of a simulated unit
+type FakeLog struct {
+	Ago time.Duration `yaml:"ago"`
+	// Priority is a name or number, info by default
+	Priority string `yaml:"priority"`
+	Message  string `yaml:"message"`
+}
+
+// LoadFakeFixture reads and checks a fixture file
+func LoadFakeFixture(path string) (*FakeFixture, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read fixture: %w", err)
+	}
+	f := &FakeFixture{}
+	if err := yaml.Unmarshal(data, f); err != nil {
+		return nil, fmt.Errorf("invalid fixture %s: %w", path, err)
+	}
+	for _, units := range [][]FakeUnit{f.Units, f.SystemUnits} {
+		if err := validateFakeUnits(units); err != nil {
+			return nil, fmt.Errorf("invalid fixture %s: %w", path, err)
+		}
+	}
+	return f, nil
+}
+
+func validateFakeUnits(units []FakeUnit) error {
+	seen := map[string]bool{}
+	for _, u := range units {
+		if u.Name == "" {
+			return fmt.Errorf("unit without a name")
+		}
+		name := UnitName(u.Name)
+		if !unitNamePattern.MatchString(name) {
+			return fmt.Errorf("invalid unit name %q", u.Name)
+		}
+		if seen[name] {
+			return fmt.Errorf("unit %s is listed twice", name)
+		}
+		seen[name] = true
+
+		switch u.ActiveState {
+		case "", "active", "inactive", "failed":
+		default:
+			return fmt.Errorf("%s: invalid active_state %q (expected active, inactive or failed)", name, u.ActiveState)
+		}
+		switch u.UnitFileState {
+		case "", "enabled", "disabled", "static", "masked", "transient":
+		default:
+			return fmt.Errorf("%s: invalid unit_file_state %q", name, u.UnitFileState)
+		}
+		if u.Memory != "" {
+			if _, err := ParseSize(u.Memory); err != nil {
+				return fmt.Errorf("%s: %w", name, err)
+			}
+		}
+		for _, p := range u.Processes {
+			if p.PID == 0 {
+				return fmt.Errorf("%s: process without a pid", name)
+			}
+			if p.RSS != "" {
+				if _, err := ParseSize(p.RSS); err != nil {
+					return fmt.Errorf("%s: %w", name, err)
+				}
+			}
+		}
+		for _, l := range u.Logs {
+			if l.Priority != "" {
+				if _, err := ParsePriority(l.Priority); err != nil {
+					return fmt.Errorf("%s: %w", name, err)
+				}
+			}
+		}
+	}
+	return nil
+}
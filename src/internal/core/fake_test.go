@@ -0,0 +1,259 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// fakeFixture is the demo fixture the fake backend ships with
+const fakeFixture = "../../../examples/fake-units.yaml"
+
+func newTestFake(t *testing.T) *FakeManager {
+	t.Helper()
+	fixture, err := LoadFakeFixture(fakeFixture)
+	if err != nil {
+		t.Fatalf("failed to load fixture: %v", err)
+	}
+	m, err := NewFakeManager(fixture, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(m.Close)
+	return m
+}
+
+func activeState(t *testing.T, m Manager, name string) string {
+	t.Helper()
+	d, err := m.GetServiceDetails(context.Background(), name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return d.ActiveState
+}
+
+func TestFakeStartStop(t *testing.T) {
+	m := newTestFake(t)
+	ctx := context.Background()
+
+	if err := m.StopService(ctx, "web"); err != nil {
+		t.Fatal(err)
+	}
+	if got := activeState(t, m, "web"); got != "inactive" {
+		t.Errorf("web is %s after stop", got)
+	}
+	if err := m.StartService(ctx, "web.service"); err != nil {
+		t.Fatal(err)
+	}
+	if got := activeState(t, m, "web"); got != "active" {
+		t.Errorf("web is %s after start", got)
+	}
+
+	// Starting worker starts web, which it requires
+	if err := m.StopService(ctx, "web"); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.StopService(ctx, "worker"); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.StartService(ctx, "worker"); err != nil {
+		t.Fatal(err)
+	}
+	if got := activeState(t, m, "web"); got != "active" {
+		t.Errorf("web is %s after starting worker", got)
+	}
+}
+
+func TestFakeJobErrors(t *testing.T) {
+	m := newTestFake(t)
+	ctx := context.Background()
+
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+
+	tests := []struct {
+		name  string
+		err   error
+		want  error
+		class string
+	}{
+		{"broken", m.StartService(ctx, "broken"), ErrJobFailed, "job_failed"},
+		{"reports", m.StartService(ctx, "reports"), ErrDependencyFailed, "dependency_failed"},
+		{"missing", m.StartService(ctx, "missing"), ErrNotFound, "not_found"},
+		{"canceled", m.StartService(canceled, "web"), ErrCanceled, "canceled"},
+	}
+	for _, tt := range tests {
+		if !errors.Is(tt.err, tt.want) {
+			t.Errorf("starting %s: got %v, want %v", tt.name, tt.err, tt.want)
+		}
+		if got := ErrorClass(tt.err); got != tt.class {
+			t.Errorf("starting %s: got class %q, want %q", tt.name, got, tt.class)
+		}
+	}
+
+	var jobErr *JobError
+	if err := m.StartService(ctx, "reports"); !errors.As(err, &jobErr) || jobErr.Unit != "reports.service" || jobErr.Result != "dependency" {
+		t.Errorf("got %#v, want the dependency result of reports.service", err)
+	}
+	if got := activeState(t, m, "reports"); got != "inactive" {
+		t.Errorf("reports is %s after its dependency failed", got)
+	}
+	if got := activeState(t, m, "broken"); got != "failed" {
+		t.Errorf("broken is %s after failing to start", got)
+	}
+}
+
+func TestFakeEnableDisable(t *testing.T) {
+	m := newTestFake(t)
+	ctx := context.Background()
+	wants := filepath.Join(m.dir, "default.target.wants")
+
+	changes, err := m.DisableService(ctx, "web", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []UnitFileChange{{Type: "unlink", Filename: filepath.Join(wants, "web.service")}}
+	if !reflect.DeepEqual(changes, want) {
+		t.Errorf("disable: got %+v, want %+v", changes, want)
+	}
+	if got := activeState(t, m, "web"); got != "active" {
+		t.Errorf("web is %s after disable without now", got)
+	}
+
+	changes, err = m.DisableService(ctx, "web", false)
+	if err != nil || len(changes) != 0 {
+		t.Errorf("disabling again: got %+v, %v, want no changes", changes, err)
+	}
+
+	changes, err = m.EnableService(ctx, "web", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 1 || changes[0].Type != "symlink" || changes[0].Filename != filepath.Join(wants, "web.service") {
+		t.Errorf("enable: got %+v, want a symlink in %s", changes, wants)
+	}
+
+	if _, err := m.DisableService(ctx, "web", true); err != nil {
+		t.Fatal(err)
+	}
+	if got := activeState(t, m, "web"); got != "inactive" {
+		t.Errorf("web is %s after disable with now", got)
+	}
+
+	// The unit file changes even when the start implied by now fails
+	changes, err = m.EnableService(ctx, "reports", true)
+	if !errors.Is(err, ErrDependencyFailed) {
+		t.Errorf("enabling reports with now: got %v, want a dependency failure", err)
+	}
+	if len(changes) != 1 {
+		t.Errorf("enabling reports: got %+v, want one symlink", changes)
+	}
+
+	if _, err := m.EnableService(ctx, "missing", false); !errors.Is(err, ErrNotFound) {
+		t.Errorf("enabling a missing unit: got %v, want ErrNotFound", err)
+	}
+}
+
+func TestFakeLogs(t *testing.T) {
+	m := newTestFake(t)
+	ctx := context.Background()
+
+	tests := []struct {
+		name  string
+		query LogQuery
+		want  []string
+	}{
+		{"unit", LogQuery{Unit: "web"}, []string{
+			"Serving HTTP on 0.0.0.0 port 8080", `127.0.0.1 - - "GET / HTTP/1.1" 200 -`, `127.0.0.1 - - "GET /missing HTTP/1.1" 404 -`,
+		}},
+		{"priority", LogQuery{Unit: "web", Priority: "warning"}, []string{`127.0.0.1 - - "GET /missing HTTP/1.1" 404 -`}},
+		{"grep", LogQuery{Unit: "web", Grep: "serving"}, []string{"Serving HTTP on 0.0.0.0 port 8080"}},
+		{"lines", LogQuery{Unit: "web", Lines: 1}, []string{`127.0.0.1 - - "GET /missing HTTP/1.1" 404 -`}},
+		{"since", LogQuery{Unit: "web", Since: time.Now().Add(-time.Hour)}, []string{
+			`127.0.0.1 - - "GET / HTTP/1.1" 200 -`, `127.0.0.1 - - "GET /missing HTTP/1.1" 404 -`,
+		}},
+		{"until", LogQuery{Unit: "web", Until: time.Now().Add(-time.Hour)}, []string{"Serving HTTP on 0.0.0.0 port 8080"}},
+		{"other boot", LogQuery{Unit: "web", Boot: "0123"}, []string{}},
+	}
+	for _, tt := range tests {
+		entries, err := m.GetLogs(ctx, tt.query)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if got := messages(entries); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+	}
+
+	// Jobs write the journal like systemd does
+	m.StartService(ctx, "reports")
+	entries, err := m.GetLogs(ctx, LogQuery{Unit: "reports", Priority: "err"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := messages(entries); !reflect.DeepEqual(got, []string{"Dependency failed for Report generator that needs the broken service."}) {
+		t.Errorf("got %q after the dependency failed", got)
+	}
+
+	if _, err := m.GetLogs(ctx, LogQuery{Files: []string{"x.journal"}}); err == nil {
+		t.Error("the fake backend read a journal file")
+	}
+}
+
+func TestFakeTimers(t *testing.T) {
+	m := newTestFake(t)
+	ctx := context.Background()
+
+	timers, err := m.ListTimers(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(timers) != 1 || timers[0].Name != "backup.timer" || timers[0].Unit != "backup.service" {
+		t.Fatalf("got timers %+v, want backup.timer for backup.service", timers)
+	}
+	if next := timers[0].NextElapse(); next.Before(time.Now()) || next.After(time.Now().Add(2*time.Minute)) {
+		t.Errorf("backup.timer elapses at %s, want within 2m", next)
+	}
+
+	before := time.Now()
+	unit, err := m.TriggerTimer(ctx, "backup")
+	if err != nil || unit != "backup.service" {
+		t.Fatalf("trigger: got %q, %v, want backup.service", unit, err)
+	}
+	if got := activeState(t, m, "backup"); got != "active" {
+		t.Errorf("backup.service is %s after the trigger", got)
+	}
+	timer, err := m.GetTimer(ctx, "backup.timer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if last := time.UnixMicro(int64(timer.LastTriggerUSec)); last.Before(before.Truncate(time.Microsecond)) {
+		t.Errorf("last trigger %s is before the trigger at %s", last, before)
+	}
+
+	if _, err := m.TriggerTimer(ctx, "missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("triggering a missing timer: got %v, want ErrNotFound", err)
+	}
+}
+
+func TestJobModePerCall(t *testing.T) {
+	m := newTestFake(t)
+	ctx := context.Background()
+
+	if err := m.RestartService(WithJobMode(ctx, "fail"), "web"); err != nil {
+		t.Errorf("restart in mode fail: %v", err)
+	}
+	if err := m.RestartService(WithJobMode(ctx, "later"), "web"); err == nil {
+		t.Error("restart in an invalid mode succeeded")
+	}
+	// The mode of one call does not stick to the manager
+	if err := m.RestartService(ctx, "web"); err != nil {
+		t.Errorf("restart in the default mode: %v", err)
+	}
+	if _, err := m.TriggerTimer(WithJobMode(ctx, "later"), "backup"); err == nil {
+		t.Error("trigger in an invalid mode succeeded")
+	}
+}
@@ -0,0 +1,174 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Config is the svcm config file, e.g.:
+//
+//	groups:
+//	  projectx:
+//	    description: Project X dev stack
+//	    units: [postgres, redis, projectx-api, "projectx-worker@*"]
+type Config struct {
+	Groups map[string]Group `yaml:"groups"`
+}
+
+// Group is a named set of units managed together. Units start in the
+// order they are listed and stop in reverse; entries may be glob patterns,
+// matched against the loaded units like unit arguments on the command
+// line.
+type Group struct {
+	Description string   `yaml:"description" json:"description,omitempty"`
+	Units       []string `yaml:"units" json:"units"`
+}
+
+// DefaultConfigPath is config.yaml in the svcm directory of the user
+// config directory ($XDG_CONFIG_HOME or ~/.config)
+func DefaultConfigPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "svcm", "config.yaml")
+}
+
+// LoadConfig reads the config at file. An empty file uses the default
+// location, where a missing file yields an empty config.
+func LoadConfig(file string) (*Config, error) {
+	c := &Config{}
+	explicit := file != ""
+	if !explicit {
+		file = DefaultConfigPath()
+	}
+	if file == "" {
+		return c, nil
+	}
+
+	data, err := os.ReadFile(file)
+	switch {
+	case err == nil:
+		if err := yaml.Unmarshal(data, c); err != nil {
+			return nil, fmt.Errorf("invalid config %s: %w", file, err)
+		}
+	case explicit || !errors.Is(err, os.ErrNotExist):
+		return nil, fmt.Errorf("failed to read config: %w", err)
+	}
+
+	if err := c.validate(); err != nil {
+		return nil, fmt.Errorf("invalid config %s: %w", file, err)
+	}
+	return c, nil
+}
+
+func (c *Config) validate() error {
+	for name, g := range c.Groups {
+		if len(g.Units) == 0 {
+			return fmt.Errorf("group %s has no units", name)
+		}
+		for _, pattern := range g.Units {
+			if _, err := path.Match(UnitName(pattern), ""); err != nil {
+				return fmt.Errorf("group %s: bad glob %q: %w", name, pattern, err)
+			}
+		}
+	}
+	return nil
+}
+
+// GroupNames returns the names of the groups, sorted
+func (c *Config) GroupNames() []string {
+	names := make([]string, 0, len(c.Groups))
+	for name := range c.Groups {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Group returns the group called name
+func (c *Config) Group(name string) (Group, error) {
+	g, ok := c.Groups[name]
+	if !ok {
+		return Group{}, notFound("no group named %s", name)
+	}
+	return g, nil
+}
+
+// Contains reports whether unit is one of the group's units or matches
+// one of its patterns
+func (g Group) Contains(unit string) bool {
+	for _, pattern := range g.Units {
+		if ok, _ := path.Match(UnitName(pattern), unit); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Resolve lists the units of g in declared order, expanding its patterns.
+// A pattern that matches no loaded unit stays in the list, so a member that
+// is missing is reported rather than failing the whole group.
+func (g Group) Resolve(ctx context.Context, m Manager) ([]string, error) {
+	return resolveUnits(ctx, m, g.Units, true)
+}
+
+// GroupStatus returns the state of every unit of g, in declared order. A
+// unit that does not exist, or a pattern that matches nothing, shows up as
+// a not-found unit.
+func GroupStatus(ctx context.Context, m Manager, g Group) ([]ServiceUnit, error) {
+	units, err := g.Resolve(ctx, m)
+	if err != nil {
+		return nil, err
+	}
+	states := make([]ServiceUnit, 0, len(units))
+	for _, name := range units {
+		if IsUnitPattern(name) {
+			states = append(states, notFoundUnit(name))
+			continue
+		}
+		d, err := m.GetServiceDetails(ctx, name)
+		switch {
+		case errors.Is(err, ErrNotFound):
+			states = append(states, notFoundUnit(name))
+		case err != nil:
+			return nil, err
+		default:
+			states = append(states, d.ServiceUnit)
+		}
+	}
+	return states, nil
+}
+
+func notFoundUnit(name string) ServiceUnit {
+	return ServiceUnit{Name: name, Type: UnitTypeOf(name), LoadState: "not-found", ActiveState: "inactive", SubState: "dead"}
+}
+
+// StartGroup starts the units of a group, as listed by Group.Resolve, one
+// at a time. Once one fails the rest are skipped, as they may need it.
+func StartGroup(ctx context.Context, m Manager, units []string, progress func(finished int, r BulkResult)) []BulkResult {
+	return RunOrdered(ctx, units, m.StartService, true, progress)
+}
+
+// StopGroup stops the units of a group one at a time in reverse order,
+// going on past failures
+func StopGroup(ctx context.Context, m Manager, units []string, progress func(finished int, r BulkResult)) []BulkResult {
+	reversed := make([]string, len(units))
+	for i, name := range units {
+		reversed[len(units)-1-i] = name
+	}
+	return RunOrdered(ctx, reversed, m.StopService, false, progress)
+}
+
+// RestartGroup restarts the units of a group one at a time in order,
+// skipping the rest once one fails
+func RestartGroup(ctx context.Context, m Manager, units []string, progress func(finished int, r BulkResult)) []BulkResult {
+	return RunOrdered(ctx, units, m.RestartService, true, progress)
+}
@@ -0,0 +1,99 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestGroupUnmatchedMembers(t *testing.T) {
+	m := newTestFake(t)
+	ctx := context.Background()
+	g := Group{Units: []string{"web", "ghost-*", "worker"}}
+
+	units, err := g.Resolve(ctx, m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"web.service", "ghost-*.service", "worker.service"}; !reflect.DeepEqual(units, want) {
+		t.Fatalf("resolved %q, want %q", units, want)
+	}
+
+	states, err := GroupStatus(ctx, m, g)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(states) != 3 || states[1].Name != "ghost-*.service" || states[1].LoadState != "not-found" {
+		t.Errorf("got states %+v, want ghost-*.service not found in the middle", states)
+	}
+
+	if err := m.StopService(ctx, "worker"); err != nil {
+		t.Fatal(err)
+	}
+	results := StartGroup(ctx, m, units, nil)
+	got := make([]string, len(results))
+	for i, r := range results {
+		got[i] = r.Result
+	}
+	if want := []string{"done", "not_found", "done"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("start results %q, want %q", got, want)
+	}
+	if !errors.Is(results[1].Err, ErrNotFound) {
+		t.Errorf("unmatched member failed with %v, want ErrNotFound", results[1].Err)
+	}
+	if state := activeState(t, m, "worker"); state != "active" {
+		t.Errorf("worker is %s after starting the group", state)
+	}
+
+	results = StopGroup(ctx, m, units, nil)
+	if len(results) != 3 || results[0].Unit != "worker.service" || results[1].Result != "not_found" || results[2].Err != nil {
+		t.Errorf("got stop results %+v, want the unmatched member not found and the others stopped", results)
+	}
+
+	if _, err := ResolveUnits(ctx, m, g.Units); !errors.Is(err, ErrNotFound) {
+		t.Errorf("resolving units outside a group: got %v, want ErrNotFound", err)
+	}
+}
+
+// detailsNotFound fails GetServiceDetails for units it does not know, as
+// systemd does for names it cannot load
+type detailsNotFound struct {
+	Manager
+	missing string
+}
+
+func (m detailsNotFound) GetServiceDetails(ctx context.Context, name string) (*ServiceDetails, error) {
+	if UnitName(name) == m.missing {
+		return nil, notFound("unit %s not found", name)
+	}
+	return m.Manager.GetServiceDetails(ctx, name)
+}
+
+func TestGroupMissingPlainName(t *testing.T) {
+	fake := newTestFake(t)
+	m := detailsNotFound{Manager: fake, missing: "missing.service"}
+	ctx := context.Background()
+	g := Group{Units: []string{"web", "missing", "worker"}}
+
+	states, err := GroupStatus(ctx, m, g)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(states) != 3 || states[1].Name != "missing.service" || states[1].LoadState != "not-found" || states[2].Name != "worker.service" {
+		t.Errorf("got states %+v, want missing.service not found in the middle", states)
+	}
+
+	// The missing unit does not keep the rest of the group from starting
+	if err := fake.StopService(ctx, "worker"); err != nil {
+		t.Fatal(err)
+	}
+	units, err := g.Resolve(ctx, m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	results := StartGroup(ctx, m, units, nil)
+	if len(results) != 3 || results[1].Result != "not_found" || results[2].Result != "done" {
+		t.Errorf("got start results %+v, want missing.service not found and worker started", results)
+	}
+}
@@ -0,0 +1,474 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/coreos/go-systemd/v22/sdjournal"
+)
+
+// Syslog priority levels as stored in the PRIORITY journal field
+var priorityNames = []string{"emerg", "alert", "crit", "err", "warning", "notice", "info", "debug"}
+
+// LogEntry is a single journal record
+type LogEntry struct {
+	Cursor     string            `json:"cursor"`
+	Timestamp  time.Time         `json:"timestamp"`
+	Monotonic  uint64            `json:"monotonic_usec"`
+	BootID     string            `json:"boot_id"`
+	Priority   int               `json:"priority"`
+	Unit       string            `json:"unit,omitempty"`
+	Identifier string            `json:"identifier,omitempty"`
+	PID        int               `json:"pid,omitempty"`
+	Hostname   string            `json:"hostname,omitempty"`
+	Message    string            `json:"message"`
+	Fields     map[string]string `json:"fields,omitempty"`
+}
+
+// LogQuery selects which journal entries GetLogs returns. Zero values mean
+// "no filter", except Lines where 0 returns every matching entry.
+type LogQuery struct {
+	Unit     string
+	Since    time.Time
+	Until    time.Time
+	Priority string // maximum priority, by name ("err") or number ("3")
+	Grep     string // regular expression matched against MESSAGE
+	Boot     string // "current" or a boot ID
+	Lines    int    // only the newest N entries
+
+	// Directory or Files read a specific journal instead of the local one,
+	// e.g. journal files exported from another host or kept as test data.
+	Directory string
+	Files     []string
+}
+
+// ParsePriority accepts a priority name or number and returns its level.
+func ParsePriority(s string) (int, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	for i, name := range priorityNames {
+		if s == name {
+			return i, nil
+		}
+	}
+	switch s {
+	case "error":
+		return 3, nil
+	case "warn":
+		return 4, nil
+	}
+	if n, err := strconv.Atoi(s); err == nil && n >= 0 && n < len(priorityNames) {
+		return n, nil
+	}
+	return 0, fmt.Errorf("invalid priority %q (expected 0-7 or one of %s)", s, strings.Join(priorityNames, ", "))
+}
+
+// PriorityName returns the syslog name of a priority level.
+func PriorityName(p int) string {
+	if p >= 0 && p < len(priorityNames) {
+		return priorityNames[p]
+	}
+	return strconv.Itoa(p)
+}
+
+// ParseLogTime understands absolute timestamps ("2006-01-02 15:04:05",
+// "2006-01-02", RFC 3339), the words "now", "today" and "yesterday", and
+// relative offsets such as "-1h", "30m" or "2h ago".
+func ParseLogTime(s string, now time.Time) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	switch s {
+	case "now":
+		return now, nil
+	case "today":
+		y, m, d := now.Date()
+		return time.Date(y, m, d, 0, 0, 0, 0, now.Location()), nil
+	case "yesterday":
+		y, m, d := now.Date()
+		return time.Date(y, m, d-1, 0, 0, 0, 0, now.Location()), nil
+	}
+
+	rel := strings.TrimSuffix(s, " ago")
+	rel = strings.TrimPrefix(rel, "-")
+	if d, err := time.ParseDuration(rel); err == nil {
+		return now.Add(-d), nil
+	}
+
+	for _, layout := range []string{time.RFC3339, "2006-01-02 15:04:05", "2006-01-02 15:04", "2006-01-02"} {
+		if t, err := time.ParseInLocation(layout, s, now.Location()); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid time %q", s)
+}
+
+// FormatLogEntry renders an entry like journalctl's default "short" output.
+func FormatLogEntry(e LogEntry) string {
+	ident := e.Identifier
+	if ident == "" {
+		ident = "unknown"
+	}
+	if e.PID != 0 {
+		ident = fmt.Sprintf("%s[%d]", ident, e.PID)
+	}
+	if e.Hostname != "" {
+		return fmt.Sprintf("%s %s %s: %s", e.Timestamp.Format(time.Stamp), e.Hostname, ident, e.Message)
+	}
+	return fmt.Sprintf("%s %s: %s", e.Timestamp.Format(time.Stamp), ident, e.Message)
+}
+
+func (m *SystemdManager) GetLogs(ctx context.Context, q LogQuery) ([]LogEntry, error) {
+	return readJournal(ctx, q, m.journalUID())
+}
+
+// journalUID is the user whose units the logs are about, or -1 for the
+// units of the system instance
+func (m *SystemdManager) journalUID() int {
+	if m.systemMode {
+		return -1
+	}
+	return os.Getuid()
+}
+
+// readJournal returns the newest matching entries, oldest first.
+func readJournal(ctx context.Context, q LogQuery, uid int) ([]LogEntry, error) {
+	matcher, err := newLogMatcher(q)
+	if err != nil {
+		return nil, err
+	}
+
+	j, err := openJournal(q, uid)
+	if err != nil {
+		return nil, err
+	}
+	defer j.Close()
+
+	return readBackwards(ctx, j, q, matcher)
+}
+
+// readBackwards walks the journal backwards from the newest matching entry
+// so that Lines can stop the scan early. ctx can cut a long scan short.
+func readBackwards(ctx context.Context, j *sdjournal.Journal, q LogQuery, matcher *regexp.Regexp) ([]LogEntry, error) {
+	var err error
+	if !q.Until.IsZero() {
+		err = j.SeekRealtimeUsec(uint64(q.Until.UnixMicro()))
+	} else {
+		err = j.SeekTail()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to seek journal: %w", err)
+	}
+
+	var entries []LogEntry
+	for q.Lines <= 0 || len(entries) < q.Lines {
+		if err := ctx.Err(); err != nil {
+			return nil, fmt.Errorf("failed to read journal: %w", classify(err))
+		}
+		n, err := j.Previous()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read journal: %w", err)
+		}
+		if n == 0 {
+			break
+		}
+
+		raw, err := j.GetEntry()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read journal entry: %w", err)
+		}
+		entry := convertEntry(raw)
+		if !q.Since.IsZero() && entry.Timestamp.Before(q.Since) {
+			break
+		}
+		if !q.Until.IsZero() && entry.Timestamp.After(q.Until) {
+			continue
+		}
+		if matcher != nil && !matcher.MatchString(entry.Message) {
+			continue
+		}
+		entries = append(entries, entry)
+	}
+
+	// Oldest first, like journalctl
+	for i, k := 0, len(entries)-1; i < k; i, k = i+1, k-1 {
+		entries[i], entries[k] = entries[k], entries[i]
+	}
+	if entries == nil {
+		entries = []LogEntry{}
+	}
+	return entries, nil
+}
+
+// LogStream delivers journal entries as they are written until it is
+// closed. Entries is closed when the stream ends; Err reports why if it was
+// not closed by the caller.
+type LogStream struct {
+	Entries <-chan LogEntry
+
+	done      chan struct{}
+	closeOnce sync.Once
+	mu        sync.Mutex
+	err       error
+}
+
+// Close stops following the journal.
+func (s *LogStream) Close() {
+	s.closeOnce.Do(func() {
+		close(s.done)
+	})
+}
+
+// Err returns the error that ended the stream, if any.
+func (s *LogStream) Err() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
+}
+
+func (s *LogStream) fail(err error) {
+	s.mu.Lock()
+	s.err = err
+	s.mu.Unlock()
+}
+
+// followWait bounds each sd_journal_wait so Close is noticed promptly
+const followWait = 250 * time.Millisecond
+
+// FollowLogs first sends the newest q.Lines entries, like journalctl -f,
+// then keeps sending new entries as they are appended. Until is ignored.
+// ctx bounds reading the backlog; the stream runs until closed.
+func (m *SystemdManager) FollowLogs(ctx context.Context, q LogQuery) (*LogStream, error) {
+	q.Until = time.Time{}
+	matcher, err := newLogMatcher(q)
+	if err != nil {
+		return nil, err
+	}
+
+	j, err := openJournal(q, m.journalUID())
+	if err != nil {
+		return nil, err
+	}
+
+	backlog, err := readBackwards(ctx, j, q, matcher)
+	if err != nil {
+		j.Close()
+		return nil, err
+	}
+
+	// Continue after the newest entry sent rather than from the tail, which
+	// would skip what was written while the backlog was read. Without a
+	// backlog the scan stopped where every later entry was filtered out,
+	// and Next filters them out again.
+	if len(backlog) > 0 {
+		if err := j.SeekCursor(backlog[len(backlog)-1].Cursor); err != nil {
+			j.Close()
+			return nil, fmt.Errorf("failed to seek journal: %w", err)
+		}
+		if _, err := j.Next(); err != nil {
+			j.Close()
+			return nil, fmt.Errorf("failed to read journal: %w", err)
+		}
+	}
+
+	entries := make(chan LogEntry, 64)
+	stream := &LogStream{Entries: entries, done: make(chan struct{})}
+
+	go func() {
+		defer close(entries)
+		defer j.Close()
+
+		send := func(e LogEntry) bool {
+			select {
+			case entries <- e:
+				return true
+			case <-stream.done:
+				return false
+			}
+		}
+
+		for _, e := range backlog {
+			if !send(e) {
+				return
+			}
+		}
+
+		for {
+			select {
+			case <-stream.done:
+				return
+			default:
+			}
+
+			n, err := j.Next()
+			if err != nil {
+				stream.fail(fmt.Errorf("failed to read journal: %w", err))
+				return
+			}
+			if n == 0 {
+				j.Wait(followWait)
+				continue
+			}
+
+			raw, err := j.GetEntry()
+			if err != nil {
+				stream.fail(fmt.Errorf("failed to read journal entry: %w", err))
+				return
+			}
+			entry := convertEntry(raw)
+			if matcher != nil && !matcher.MatchString(entry.Message) {
+				continue
+			}
+			if !send(entry) {
+				return
+			}
+		}
+	}()
+
+	return stream, nil
+}
+
+// newLogMatcher compiles the grep pattern. Like journalctl, a pattern
+// without upper case letters matches case-insensitively.
+func newLogMatcher(q LogQuery) (*regexp.Regexp, error) {
+	if q.Grep == "" {
+		return nil, nil
+	}
+	pattern := q.Grep
+	if strings.IndexFunc(pattern, unicode.IsUpper) < 0 {
+		pattern = "(?i)" + pattern
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid grep pattern %q: %w", q.Grep, err)
+	}
+	return re, nil
+}
+
+// openJournal opens the requested journal and installs the unit, priority
+// and boot matches. Matches on the same field are OR-ed by sd-journal, the
+// groups are joined with conjunctions the same way journalctl does it. A
+// unit is looked up among the user units of uid, or the system units when
+// uid is negative.
+func openJournal(q LogQuery, uid int) (*sdjournal.Journal, error) {
+	var j *sdjournal.Journal
+	var err error
+	switch {
+	case len(q.Files) > 0:
+		j, err = sdjournal.NewJournalFromFiles(q.Files...)
+	case q.Directory != "":
+		j, err = sdjournal.NewJournalFromDir(q.Directory)
+	default:
+		j, err = sdjournal.NewJournal()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open journal: %w", classify(err))
+	}
+
+	if err := addJournalMatches(j, q, uid); err != nil {
+		j.Close()
+		return nil, err
+	}
+	return j, nil
+}
+
+func addJournalMatches(j *sdjournal.Journal, q LogQuery, uid int) error {
+	var groups [][][]string
+
+	if q.Unit != "" {
+		unit := UnitName(q.Unit)
+		if uid >= 0 {
+			owner := "_UID=" + strconv.Itoa(uid)
+			groups = append(groups, [][]string{
+				{"_SYSTEMD_USER_UNIT=" + unit, owner},
+				{"USER_UNIT=" + unit, owner},
+			})
+		} else {
+			groups = append(groups, [][]string{
+				{"_SYSTEMD_UNIT=" + unit},
+				{"UNIT=" + unit, "_PID=1"},
+			})
+		}
+	}
+
+	if q.Priority != "" {
+		max, err := ParsePriority(q.Priority)
+		if err != nil {
+			return err
+		}
+		var prio []string
+		for p := 0; p <= max; p++ {
+			prio = append(prio, "PRIORITY="+strconv.Itoa(p))
+		}
+		groups = append(groups, [][]string{prio})
+	}
+
+	if q.Boot != "" {
+		boot := q.Boot
+		if boot == "current" {
+			id, err := j.GetBootID()
+			if err != nil {
+				return fmt.Errorf("failed to determine current boot: %w", err)
+			}
+			boot = id
+		}
+		groups = append(groups, [][]string{{"_BOOT_ID=" + strings.ReplaceAll(boot, "-", "")}})
+	}
+
+	for gi, group := range groups {
+		if gi > 0 {
+			if err := j.AddConjunction(); err != nil {
+				return fmt.Errorf("failed to add journal match: %w", err)
+			}
+		}
+		for ti, term := range group {
+			if ti > 0 {
+				if err := j.AddDisjunction(); err != nil {
+					return fmt.Errorf("failed to add journal match: %w", err)
+				}
+			}
+			for _, match := range term {
+				if err := j.AddMatch(match); err != nil {
+					return fmt.Errorf("failed to add journal match %s: %w", match, err)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func convertEntry(raw *sdjournal.JournalEntry) LogEntry {
+	f := raw.Fields
+	first := func(keys ...string) string {
+		for _, k := range keys {
+			if v := f[k]; v != "" {
+				return v
+			}
+		}
+		return ""
+	}
+
+	entry := LogEntry{
+		Cursor:     raw.Cursor,
+		Timestamp:  time.UnixMicro(int64(raw.RealtimeTimestamp)),
+		Monotonic:  raw.MonotonicTimestamp,
+		BootID:     f["_BOOT_ID"],
+		Priority:   6,
+		Unit:       first("_SYSTEMD_USER_UNIT", "USER_UNIT", "_SYSTEMD_UNIT", "UNIT"),
+		Identifier: first("SYSLOG_IDENTIFIER", "_COMM"),
+		Hostname:   f["_HOSTNAME"],
+		Message:    f["MESSAGE"],
+		Fields:     f,
+	}
+	if p, err := strconv.Atoi(f["PRIORITY"]); err == nil {
+		entry.Priority = p
+	}
+	if pid, err := strconv.Atoi(first("SYSLOG_PID", "_PID")); err == nil {
+		entry.PID = pid
+	}
+	return entry
+}
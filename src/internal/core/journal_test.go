@@ -0,0 +1,220 @@
+package core
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// testJournal is written by testdata/mkjournal.go
+var testJournal = []string{"testdata/test.journal"}
+
+// Boots of testJournal
+const (
+	testBootA = "0b7f3a5c9e1d4f2a8c6b0e9d7a5f3c1e"
+	testBootB = "5d2e8f1a3c7b4e9d0f6a2c8e4b1d7f3a"
+)
+
+// at is a time in testJournal, which starts at 10:00 UTC
+func at(clock string) time.Time {
+	t, err := time.Parse("2006-01-02 15:04:05", "2024-05-01 "+clock)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+func messages(entries []LogEntry) []string {
+	list := []string{}
+	for _, e := range entries {
+		list = append(list, e.Message)
+	}
+	return list
+}
+
+func TestReadJournal(t *testing.T) {
+	tests := []struct {
+		name  string
+		query LogQuery
+		uid   int
+		want  []string
+	}{
+		{"all", LogQuery{}, -1, []string{
+			"Starting web.service...", "Serving HTTP on port 8080", "GET /missing 404", "connection refused",
+			"user web listening", "Started web.service.", "other user web listening",
+			"Serving HTTP again", "GET / 200", "db ready",
+		}},
+		{"lines", LogQuery{Lines: 3}, -1, []string{"Serving HTTP again", "GET / 200", "db ready"}},
+		{"system unit", LogQuery{Unit: "web"}, -1, []string{
+			"Starting web.service...", "Serving HTTP on port 8080", "GET /missing 404", "Serving HTTP again", "GET / 200",
+		}},
+		{"system unit lines", LogQuery{Unit: "web.service", Lines: 2}, -1, []string{"Serving HTTP again", "GET / 200"}},
+		{"user unit", LogQuery{Unit: "web"}, 1000, []string{"user web listening", "Started web.service."}},
+		{"user unit of another user", LogQuery{Unit: "web"}, 1001, []string{"other user web listening"}},
+		{"user unit of a user without logs", LogQuery{Unit: "web"}, 1002, []string{}},
+		{"priority name", LogQuery{Priority: "warning"}, -1, []string{"GET /missing 404", "connection refused"}},
+		{"priority number", LogQuery{Priority: "3"}, -1, []string{"connection refused"}},
+		{"unit and priority", LogQuery{Unit: "web", Priority: "warning"}, -1, []string{"GET /missing 404"}},
+		{"grep ignores case", LogQuery{Grep: "get"}, -1, []string{"GET /missing 404", "GET / 200"}},
+		{"grep with upper case matches case", LogQuery{Grep: "Get"}, -1, []string{}},
+		{"grep regexp", LogQuery{Grep: "^Serving HTTP (on|again)"}, -1, []string{"Serving HTTP on port 8080", "Serving HTTP again"}},
+		{"since", LogQuery{Since: at("11:00:30")}, -1, []string{"GET / 200", "db ready"}},
+		{"until", LogQuery{Until: at("10:07:00")}, -1, []string{"Starting web.service...", "Serving HTTP on port 8080", "GET /missing 404"}},
+		{"since and until", LogQuery{Since: at("10:04:00"), Until: at("10:12:00")}, -1, []string{"GET /missing 404", "connection refused"}},
+		{"until and lines", LogQuery{Until: at("10:12:00"), Lines: 1}, -1, []string{"connection refused"}},
+		{"boot", LogQuery{Boot: testBootB}, -1, []string{"Serving HTTP again", "GET / 200", "db ready"}},
+		{"boot with dashes", LogQuery{Boot: "0b7f3a5c-9e1d-4f2a-8c6b-0e9d7a5f3c1e", Unit: "web"}, -1, []string{
+			"Starting web.service...", "Serving HTTP on port 8080", "GET /missing 404",
+		}},
+		{"no match", LogQuery{Unit: "missing"}, -1, []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := tt.query
+			q.Files = testJournal
+			entries, err := readJournal(context.Background(), q, tt.uid)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := messages(entries); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadJournalEntry(t *testing.T) {
+	entries, err := readJournal(context.Background(), LogQuery{Files: testJournal, Lines: 1, Grep: "missing"}, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("got %d entries, want 1", len(entries))
+	}
+	e := entries[0]
+	if e.Cursor == "" {
+		t.Error("entry has no cursor")
+	}
+	if !e.Timestamp.Equal(at("10:05:00")) {
+		t.Errorf("got timestamp %s", e.Timestamp)
+	}
+	want := LogEntry{BootID: testBootA, Priority: 4, Unit: "web.service", Identifier: "web", PID: 2101, Hostname: "testhost", Message: "GET /missing 404"}
+	got := LogEntry{BootID: e.BootID, Priority: e.Priority, Unit: e.Unit, Identifier: e.Identifier, PID: e.PID, Hostname: e.Hostname, Message: e.Message}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestReadJournalErrors(t *testing.T) {
+	if _, err := readJournal(context.Background(), LogQuery{Files: testJournal, Priority: "loud"}, -1); err == nil {
+		t.Error("invalid priority accepted")
+	}
+	if _, err := readJournal(context.Background(), LogQuery{Files: testJournal, Grep: "("}, -1); err == nil {
+		t.Error("invalid grep pattern accepted")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := readJournal(ctx, LogQuery{Files: testJournal}, -1)
+	if ErrorClass(err) != "canceled" {
+		t.Errorf("got %v, want a canceled error", err)
+	}
+}
+
+func TestParsePriority(t *testing.T) {
+	tests := []struct {
+		in   string
+		want int
+	}{
+		{"emerg", 0}, {"err", 3}, {"error", 3}, {"ERR", 3}, {"warn", 4}, {"warning", 4},
+		{" notice ", 5}, {"debug", 7}, {"0", 0}, {"7", 7},
+	}
+	for _, tt := range tests {
+		got, err := ParsePriority(tt.in)
+		if err != nil || got != tt.want {
+			t.Errorf("ParsePriority(%q) = %d, %v, want %d", tt.in, got, err, tt.want)
+		}
+	}
+	for _, in := range []string{"", "8", "-1", "loud"} {
+		if _, err := ParsePriority(in); err == nil {
+			t.Errorf("ParsePriority(%q) succeeded", in)
+		}
+	}
+}
+
+func TestParseLogTime(t *testing.T) {
+	loc := time.FixedZone("test", 2*60*60)
+	now := time.Date(2024, 5, 1, 12, 30, 0, 0, loc)
+	tests := []struct {
+		in   string
+		want time.Time
+	}{
+		{"now", now},
+		{"today", time.Date(2024, 5, 1, 0, 0, 0, 0, loc)},
+		{"yesterday", time.Date(2024, 4, 30, 0, 0, 0, 0, loc)},
+		{"-1h", now.Add(-time.Hour)},
+		{"30m", now.Add(-30 * time.Minute)},
+		{"2h ago", now.Add(-2 * time.Hour)},
+		{"2024-04-30 08:15:00", time.Date(2024, 4, 30, 8, 15, 0, 0, loc)},
+		{"2024-04-30 08:15", time.Date(2024, 4, 30, 8, 15, 0, 0, loc)},
+		{"2024-04-30", time.Date(2024, 4, 30, 0, 0, 0, 0, loc)},
+		{"2024-04-30T08:15:00Z", time.Date(2024, 4, 30, 8, 15, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		got, err := ParseLogTime(tt.in, now)
+		if err != nil || !got.Equal(tt.want) {
+			t.Errorf("ParseLogTime(%q) = %s, %v, want %s", tt.in, got, err, tt.want)
+		}
+	}
+	for _, in := range []string{"", "soon", "2024-13-01"} {
+		if _, err := ParseLogTime(in, now); err == nil {
+			t.Errorf("ParseLogTime(%q) succeeded", in)
+		}
+	}
+}
+
+func TestFollowLogs(t *testing.T) {
+	m := &SystemdManager{systemMode: true}
+	tests := []struct {
+		name  string
+		query LogQuery
+		want  []string
+	}{
+		{"lines", LogQuery{Lines: 2}, []string{"GET / 200", "db ready"}},
+		{"unit", LogQuery{Unit: "web", Lines: 2}, []string{"Serving HTTP again", "GET / 200"}},
+		{"grep", LogQuery{Grep: "http", Lines: 1}, []string{"Serving HTTP again"}},
+		{"no backlog", LogQuery{Grep: "nothing like this"}, []string{}},
+		{"since", LogQuery{Since: at("11:01:30")}, []string{"db ready"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := tt.query
+			q.Files = testJournal
+			stream, err := m.FollowLogs(context.Background(), q)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer stream.Close()
+
+			// Following resumes after the backlog, so nothing is sent twice
+			got := []string{}
+			timeout := time.After(2 * followWait)
+		read:
+			for {
+				select {
+				case e, ok := <-stream.Entries:
+					if !ok {
+						t.Fatalf("stream ended: %v", stream.Err())
+					}
+					got = append(got, e.Message)
+				case <-timeout:
+					break read
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
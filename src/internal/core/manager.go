@@ -2,14 +2,17 @@ package core
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/coreos/go-systemd/v22/dbus"
 )
 
 type SystemdManager struct {
-	conn *dbus.Conn
+	conn       *dbus.Conn
+	systemMode bool
 }
 
 func NewSystemdManager(systemMode bool) (*SystemdManager, error) {
@@ -23,93 +26,168 @@ func NewSystemdManager(systemMode bool) (*SystemdManager, error) {
 	}
 
 	if err != nil {
-		return nil, fmt.Errorf("failed to connect to systemd bus (system=%v): %w", systemMode, err)
+		return nil, fmt.Errorf("failed to connect to systemd bus (system=%v): %w", systemMode, classify(err))
 	}
-	return &SystemdManager{conn: conn}, nil
+	return &SystemdManager{conn: conn, systemMode: systemMode}, nil
 }
 
 func (m *SystemdManager) Close() {
 	m.conn.Close()
 }
 
-func (m *SystemdManager) ListServices() ([]ServiceUnit, error) {
-	// ListUnitsByPatterns(states, patterns)
-	// We want all service units.
-	units, err := m.conn.ListUnitsByPatternsContext(context.Background(), nil, []string{"*.service"})
-	if err != nil {
-		return nil, fmt.Errorf("failed to list units: %w", err)
-	}
+// ListServices lists the loaded service units
+func (m *SystemdManager) ListServices(ctx context.Context) ([]ServiceUnit, error) {
+	return m.ListUnits(ctx, "service")
+}
 
-	var services []ServiceUnit
-	for _, u := range units {
-		services = append(services, ServiceUnit{
-			Name:        u.Name,
-			Description: u.Description,
-			LoadState:   u.LoadState,
-			ActiveState: u.ActiveState,
-			SubState:    u.SubState,
-		})
+// JobModes are the modes systemd accepts for queueing a job, see the
+// --job-mode option of systemctl
+var JobModes = []string{
+	"replace", "fail", "isolate", "ignore-dependencies", "ignore-requirements",
+	"flush", "replace-irreversibly", "triggering", "restart-dependencies",
+}
+
+type jobModeKey struct{}
+
+// WithJobMode returns a context under which jobs treat conflicting jobs
+// according to mode instead of the default "replace"
+func WithJobMode(ctx context.Context, mode string) context.Context {
+	return context.WithValue(ctx, jobModeKey{}, mode)
+}
+
+// jobMode is the mode jobs queued under ctx use
+func jobMode(ctx context.Context) (string, error) {
+	mode, _ := ctx.Value(jobModeKey{}).(string)
+	if mode == "" {
+		return "replace", nil
 	}
-	return services, nil
+	return mode, CheckJobMode(mode)
 }
 
-func ensureServiceSuffix(name string) string {
-	if !strings.HasSuffix(name, ".service") {
-		return name + ".service"
+// CheckJobMode reports whether mode is one of JobModes
+func CheckJobMode(mode string) error {
+	for _, valid := range JobModes {
+		if mode == valid {
+			return nil
+		}
 	}
-	return name
+	return fmt.Errorf("invalid job mode %q (expected one of %s)", mode, strings.Join(JobModes, ", "))
 }
 
-func (m *SystemdManager) StartService(name string) error {
-	name = ensureServiceSuffix(name)
-	// Mode "replace" is standard
-	ch := make(chan string)
-	_, err := m.conn.StartUnitContext(context.Background(), name, "replace", ch)
+// jobFunc is the signature go-systemd uses for the methods queueing a job
+type jobFunc func(ctx context.Context, name, mode string, ch chan<- string) (int, error)
+
+// runJob queues a job in the mode of ctx and waits for it with waitJob
+func (m *SystemdManager) runJob(ctx context.Context, verb, name string, queue jobFunc) error {
+	name = UnitName(name)
+	mode, err := jobMode(ctx)
 	if err != nil {
-		return fmt.Errorf("failed to start service %s: %w", name, err)
+		return fmt.Errorf("failed to %s %s: %w", verb, name, err)
 	}
-	result := <-ch
-	if result != "done" {
-		return fmt.Errorf("start job for %s failed with result: %s", name, result)
+	ch := make(chan string, 1)
+	id, err := queue(ctx, name, mode, ch)
+	if err != nil {
+		return fmt.Errorf("failed to %s %s: %w", verb, name, classify(err))
 	}
-	return nil
+	return m.waitJob(ctx, verb, name, id, ch)
 }
 
-func (m *SystemdManager) StopService(name string) error {
-	name = ensureServiceSuffix(name)
-	ch := make(chan string)
-	_, err := m.conn.StopUnitContext(context.Background(), name, "replace", ch)
+// waitJob waits for the result of job id on ch. When ctx is done first the
+// job is cancelled, so the unit does not change after the caller was told
+// it gave up.
+func (m *SystemdManager) waitJob(ctx context.Context, verb, name string, id int, ch <-chan string) error {
+	select {
+	case result := <-ch:
+		if result != "done" {
+			return &JobError{Verb: verb, Unit: name, Result: result}
+		}
+		return nil
+	case <-ctx.Done():
+		if err := m.cancelJob(uint32(id)); err != nil {
+			return fmt.Errorf("gave up waiting for the %s job of %s, which goes on (failed to cancel it: %v): %w", verb, name, err, classify(ctx.Err()))
+		}
+		return fmt.Errorf("cancelled the %s job of %s: %w", verb, name, classify(ctx.Err()))
+	}
+}
+
+// cancelJob removes a job from systemd's queue, or stops it if it already
+// runs. A job that finished in the meantime is not an error.
+func (m *SystemdManager) cancelJob(id uint32) error {
+	conn, err := m.privateBus()
 	if err != nil {
-		return fmt.Errorf("failed to stop service %s: %w", name, err)
+		return err
 	}
-	result := <-ch
-	if result != "done" {
-		return fmt.Errorf("stop job for %s failed with result: %s", name, result)
+	defer conn.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	err = conn.Object(systemdDest, systemdPath).CallWithContext(ctx, systemdManagerIf+".CancelJob", 0, id).Store()
+	if errors.Is(classify(err), ErrNotFound) {
+		return nil
+	}
+	return err
+}
+
+func (m *SystemdManager) StartService(ctx context.Context, name string) error {
+	return m.runJob(ctx, "start", name, m.conn.StartUnitContext)
+}
+
+func (m *SystemdManager) StopService(ctx context.Context, name string) error {
+	return m.runJob(ctx, "stop", name, m.conn.StopUnitContext)
+}
+
+func (m *SystemdManager) RestartService(ctx context.Context, name string) error {
+	return m.runJob(ctx, "restart", name, m.conn.RestartUnitContext)
+}
+
+// ReloadService asks the unit to reload its configuration (ExecReload=)
+// without stopping it.
+func (m *SystemdManager) ReloadService(ctx context.Context, name string) error {
+	return m.runJob(ctx, "reload", name, m.conn.ReloadUnitContext)
+}
+
+// ReloadOrRestartService reloads the unit if it supports reloading and
+// restarts it otherwise; inactive units are started.
+func (m *SystemdManager) ReloadOrRestartService(ctx context.Context, name string) error {
+	return m.runJob(ctx, "reload-or-restart", name, m.conn.ReloadOrRestartUnitContext)
+}
+
+// TryRestartService restarts the unit only if it is running.
+func (m *SystemdManager) TryRestartService(ctx context.Context, name string) error {
+	return m.runJob(ctx, "try-restart", name, m.conn.TryRestartUnitContext)
+}
+
+// ResetFailedService clears the failed state and restart counter of a unit.
+func (m *SystemdManager) ResetFailedService(ctx context.Context, name string) error {
+	name = UnitName(name)
+	if err := m.conn.ResetFailedUnitContext(ctx, name); err != nil {
+		return fmt.Errorf("failed to reset %s: %w", name, classify(err))
 	}
 	return nil
 }
 
-func (m *SystemdManager) RestartService(name string) error {
-	name = ensureServiceSuffix(name)
-	ch := make(chan string)
-	_, err := m.conn.RestartUnitContext(context.Background(), name, "replace", ch)
+// ResetAllFailed resets every failed unit and returns their names.
+func (m *SystemdManager) ResetAllFailed(ctx context.Context) ([]string, error) {
+	units, err := m.conn.ListUnitsFilteredContext(ctx, []string{"failed"})
 	if err != nil {
-		return fmt.Errorf("failed to restart service %s: %w", name, err)
+		return nil, fmt.Errorf("failed to list failed units: %w", classify(err))
 	}
-	result := <-ch
-	if result != "done" {
-		return fmt.Errorf("restart job for %s failed with result: %s", name, result)
+	names := make([]string, 0, len(units))
+	for _, u := range units {
+		if err := m.ResetFailedService(ctx, u.Name); err != nil {
+			return names, err
+		}
+		names = append(names, u.Name)
 	}
-	return nil
+	return names, nil
 }
 
-func (m *SystemdManager) GetServiceDetails(name string) (*ServiceDetails, error) {
-	name = ensureServiceSuffix(name)
+func (m *SystemdManager) GetServiceDetails(ctx context.Context, name string) (*ServiceDetails, error) {
+	name = UnitName(name)
 
 	// Get Unit Properties
-	props, err := m.conn.GetAllPropertiesContext(context.Background(), name)
+	props, err := m.conn.GetAllPropertiesContext(ctx, name)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get properties for %s: %w", name, err)
+		return nil, fmt.Errorf("failed to get properties for %s: %w", name, classify(err))
 	}
 
 	// Helper to safely get string/uint types
@@ -135,6 +213,7 @@ func (m *SystemdManager) GetServiceDetails(name string) (*ServiceDetails, error)
 	details := &ServiceDetails{
 		ServiceUnit: ServiceUnit{
 			Name:        getString("Id"),
+			Type:        UnitTypeOf(getString("Id")),
 			Description: getString("Description"),
 			LoadState:   getString("LoadState"),
 			ActiveState: getString("ActiveState"),
@@ -142,8 +221,16 @@ func (m *SystemdManager) GetServiceDetails(name string) (*ServiceDetails, error)
 		},
 		MainPID:                getUint32("MainPID"),
 		FragmentPath:           getString("FragmentPath"),
+		UnitFileState:          getString("UnitFileState"),
 		ActiveEnterTimestamp:   getUint64("ActiveEnterTimestamp"),
 		InactiveEnterTimestamp: getUint64("InactiveEnterTimestamp"),
+		ResourceUsage:          resourceUsage(props),
+	}
+	details.Transient, _ = props["Transient"].(bool)
+	// The process list is best effort, cgroupfs may not be readable
+	if details.ControlGroup = getString("ControlGroup"); details.ControlGroup != "" {
+		details.Processes, _ = cgroupProcesses(details.ControlGroup)
 	}
+	typeDetails(details, props)
 	return details, nil
 }
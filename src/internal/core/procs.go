@@ -0,0 +1,287 @@
+package core
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/coreos/go-systemd/v22/dbus"
+	"golang.org/x/sys/unix"
+)
+
+// Process is one process in a unit's control group
+type Process struct {
+	PID     uint32 `json:"pid"`
+	PPID    uint32 `json:"ppid"`
+	Command string `json:"command"`
+	User    string `json:"user"`
+	// RSSBytes is the resident set size
+	RSSBytes uint64 `json:"rss_bytes"`
+	// CPUTimeNSec is the user plus system CPU time used so far
+	CPUTimeNSec uint64 `json:"cpu_time_nsec"`
+}
+
+// ProcessNode is a process with the processes it started
+type ProcessNode struct {
+	Process
+	Children []*ProcessNode `json:"children,omitempty"`
+}
+
+// clockTicks is USER_HZ, the unit of the times in /proc/<pid>/stat. It is
+// 100 on every architecture Linux supports.
+const clockTicks = 100
+
+// cgroupRoots are where the unified hierarchy is mounted, on pure cgroup v2
+// systems and in hybrid setups
+var cgroupRoots = []string{"/sys/fs/cgroup", "/sys/fs/cgroup/unified", "/sys/fs/cgroup/systemd"}
+
+// GetProcesses lists the processes in the control group of a unit and its
+// sub-groups, ordered by PID. A unit without processes has an empty list.
+func (m *SystemdManager) GetProcesses(ctx context.Context, name string) ([]Process, error) {
+	name = UnitName(name)
+	iface, ok := cgroupInterfaces[UnitTypeOf(name)]
+	if !ok {
+		return nil, fmt.Errorf("%s units have no processes", UnitTypeOf(name))
+	}
+	prop, err := m.conn.GetUnitTypePropertyContext(ctx, name, iface, "ControlGroup")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get control group of %s: %w", name, classify(err))
+	}
+	group, _ := prop.Value.Value().(string)
+	if group == "" {
+		return []Process{}, nil
+	}
+	return cgroupProcesses(group)
+}
+
+// cgroupProcesses reads the PIDs of a control group from cgroupfs and the
+// process details from procfs. Processes exiting meanwhile are skipped.
+func cgroupProcesses(group string) ([]Process, error) {
+	dir := ""
+	for _, root := range cgroupRoots {
+		if _, err := os.Stat(filepath.Join(root, group, "cgroup.procs")); err == nil {
+			dir = filepath.Join(root, group)
+			break
+		}
+	}
+	if dir == "" {
+		return nil, fmt.Errorf("control group %s not found in cgroupfs", group)
+	}
+
+	var pids []uint32
+	err := filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			// Sub-groups may be removed while walking
+			return nil
+		}
+		if d.Name() != "cgroup.procs" {
+			return nil
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil
+		}
+		for _, field := range strings.Fields(string(data)) {
+			if pid, err := strconv.ParseUint(field, 10, 32); err == nil {
+				pids = append(pids, uint32(pid))
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read control group %s: %w", group, classify(err))
+	}
+
+	users := map[uint32]string{}
+	procs := make([]Process, 0, len(pids))
+	for _, pid := range pids {
+		if p, ok := readProcess(pid, users); ok {
+			procs = append(procs, p)
+		}
+	}
+	sort.Slice(procs, func(i, j int) bool { return procs[i].PID < procs[j].PID })
+	return procs, nil
+}
+
+// readProcess gathers the details of a process; users caches uid lookups
+func readProcess(pid uint32, users map[uint32]string) (Process, bool) {
+	dir := filepath.Join("/proc", strconv.FormatUint(uint64(pid), 10))
+	stat, err := os.ReadFile(filepath.Join(dir, "stat"))
+	if err != nil {
+		return Process{}, false
+	}
+	// The command name is in parentheses and may contain spaces or ')'
+	open, end := bytes.IndexByte(stat, '('), bytes.LastIndexByte(stat, ')')
+	if open < 0 || end < open {
+		return Process{}, false
+	}
+	comm := string(stat[open+1 : end])
+	// Fields from "state" on: state ppid ... utime(14) stime(15)
+	fields := strings.Fields(string(stat[end+1:]))
+	if len(fields) < 13 {
+		return Process{}, false
+	}
+	ppid, _ := strconv.ParseUint(fields[1], 10, 32)
+	utime, _ := strconv.ParseUint(fields[11], 10, 64)
+	stime, _ := strconv.ParseUint(fields[12], 10, 64)
+
+	p := Process{
+		PID:         pid,
+		PPID:        uint32(ppid),
+		Command:     "[" + comm + "]",
+		CPUTimeNSec: (utime + stime) * (1e9 / clockTicks),
+	}
+	if cmdline, err := os.ReadFile(filepath.Join(dir, "cmdline")); err == nil && len(cmdline) > 0 {
+		// Arguments may contain newlines, which would break up listings
+		p.Command = strings.Join(strings.Fields(strings.ReplaceAll(string(cmdline), "\x00", " ")), " ")
+	}
+	if statm, err := os.ReadFile(filepath.Join(dir, "statm")); err == nil {
+		if f := strings.Fields(string(statm)); len(f) > 1 {
+			pages, _ := strconv.ParseUint(f[1], 10, 64)
+			p.RSSBytes = pages * uint64(os.Getpagesize())
+		}
+	}
+	if uid, ok := processUID(dir); ok {
+		if _, cached := users[uid]; !cached {
+			users[uid] = strconv.FormatUint(uint64(uid), 10)
+			if u, err := user.LookupId(users[uid]); err == nil {
+				users[uid] = u.Username
+			}
+		}
+		p.User = users[uid]
+	}
+	return p, true
+}
+
+// processUID reads the real uid from /proc/<pid>/status
+func processUID(dir string) (uint32, bool) {
+	f, err := os.Open(filepath.Join(dir, "status"))
+	if err != nil {
+		return 0, false
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if rest, ok := strings.CutPrefix(scanner.Text(), "Uid:"); ok {
+			if f := strings.Fields(rest); len(f) > 0 {
+				uid, err := strconv.ParseUint(f[0], 10, 32)
+				return uint32(uid), err == nil
+			}
+		}
+	}
+	return 0, false
+}
+
+// BuildProcessTree nests processes under their parents. Processes whose
+// parent is outside the list become roots.
+func BuildProcessTree(procs []Process) []*ProcessNode {
+	nodes := make(map[uint32]*ProcessNode, len(procs))
+	for _, p := range procs {
+		nodes[p.PID] = &ProcessNode{Process: p}
+	}
+	var roots []*ProcessNode
+	for _, p := range procs {
+		node := nodes[p.PID]
+		if parent, ok := nodes[p.PPID]; ok && p.PPID != p.PID {
+			parent.Children = append(parent.Children, node)
+		} else {
+			roots = append(roots, node)
+		}
+	}
+	return roots
+}
+
+// Signals are the signals the frontends offer in their pickers
+var Signals = []struct {
+	Name   string
+	Signal syscall.Signal
+}{
+	{"SIGTERM", syscall.SIGTERM},
+	{"SIGKILL", syscall.SIGKILL},
+	{"SIGHUP", syscall.SIGHUP},
+	{"SIGINT", syscall.SIGINT},
+	{"SIGQUIT", syscall.SIGQUIT},
+	{"SIGUSR1", syscall.SIGUSR1},
+	{"SIGUSR2", syscall.SIGUSR2},
+	{"SIGSTOP", syscall.SIGSTOP},
+	{"SIGCONT", syscall.SIGCONT},
+}
+
+// ParseSignal accepts a signal name with or without the SIG prefix, in any
+// case, or a signal number
+func ParseSignal(s string) (syscall.Signal, error) {
+	if n, err := strconv.Atoi(s); err == nil && n > 0 && n < 65 {
+		return syscall.Signal(n), nil
+	}
+	name := strings.ToUpper(s)
+	if !strings.HasPrefix(name, "SIG") {
+		name = "SIG" + name
+	}
+	if sig := unix.SignalNum(name); sig != 0 {
+		return sig, nil
+	}
+	return 0, fmt.Errorf("unknown signal %q", s)
+}
+
+// SignalProcess sends a signal to one process of a unit. The PID is checked
+// against the unit's control group first, so a PID that was reused by an
+// unrelated process is not hit.
+func (m *SystemdManager) SignalProcess(ctx context.Context, name string, pid uint32, sig syscall.Signal) error {
+	procs, err := m.GetProcesses(ctx, name)
+	if err != nil {
+		return err
+	}
+	for _, p := range procs {
+		if p.PID == pid {
+			if err := syscall.Kill(int(pid), sig); err != nil {
+				return fmt.Errorf("failed to send %s to %d: %w", SignalName(sig), pid, classify(err))
+			}
+			return nil
+		}
+	}
+	return notFound("process %d is not part of %s", pid, UnitName(name))
+}
+
+// SignalName returns the SIG* name of a signal, or its number
+func SignalName(sig syscall.Signal) string {
+	if name := unix.SignalName(sig); name != "" {
+		return name
+	}
+	return strconv.Itoa(int(sig))
+}
+
+// KillWho are the processes KillService can target: all processes of the
+// unit, only the main process, or only the control process (e.g. a running
+// ExecReload=)
+var KillWho = []string{"all", "main", "control"}
+
+// KillService sends a signal to processes of a unit without stopping it,
+// like systemctl kill
+func (m *SystemdManager) KillService(ctx context.Context, name, who string, sig syscall.Signal) error {
+	name = UnitName(name)
+	if err := CheckKillWho(who); err != nil {
+		return err
+	}
+	if err := m.conn.KillUnitWithTarget(ctx, name, dbus.Who(who), int32(sig)); err != nil {
+		return fmt.Errorf("failed to send %s to %s: %w", SignalName(sig), name, classify(err))
+	}
+	return nil
+}
+
+// CheckKillWho reports whether who is one of KillWho
+func CheckKillWho(who string) error {
+	for _, w := range KillWho {
+		if w == who {
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid kill target %q (expected %s)", who, strings.Join(KillWho, ", "))
+}
@@ -0,0 +1,366 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/coreos/go-systemd/v22/unit"
+)
+
+// UnitSpec describes a unit to scaffold. Templates see it as their data.
+type UnitSpec struct {
+	// Name is the unit name without a suffix
+	Name        string
+	Description string
+	// Exec is the command line run by the service
+	Exec             string
+	Restart          string
+	Environment      []string
+	After            []string
+	WorkingDirectory string
+	// Schedule is the OnCalendar= expression of timer templates
+	Schedule string
+	// Listen is the ListenStream= address of socket templates
+	Listen string
+	// WantedBy defaults to the target a unit of this manager starts with
+	WantedBy string
+}
+
+// builtinTemplates map a template name to its files keyed by unit type
+var builtinTemplates = map[string]map[string]string{
+	"simple":  {"service": serviceTemplate("simple", true)},
+	"oneshot": {"service": serviceTemplate("oneshot", true)},
+	"timer": {
+		"service": serviceTemplate("oneshot", false),
+		"timer": `[Unit]
+Description={{.Description}} (timer)
+
+[Timer]
+OnCalendar={{.Schedule}}
+Persistent=true
+
+[Install]
+WantedBy=timers.target
+`,
+	},
+	"socket": {
+		"service": serviceTemplate("simple", false),
+		"socket": `[Unit]
+Description={{.Description}} (socket)
+
+[Socket]
+ListenStream={{.Listen}}
+
+[Install]
+WantedBy=sockets.target
+`,
+	},
+}
+
+// serviceTemplate builds the service part of the built-in templates. Units
+// activated by a timer or socket get no [Install] section of their own.
+func serviceTemplate(serviceType string, install bool) string {
+	t := `[Unit]
+Description={{.Description}}
+{{- range .After}}
+After={{.}}
+{{- end}}
+
+[Service]
+Type=` + serviceType + `
+ExecStart={{.Exec}}
+{{- with .Restart}}
+Restart={{.}}
+{{- end}}
+{{- range .Environment}}
+Environment={{quote .}}
+{{- end}}
+{{- with .WorkingDirectory}}
+WorkingDirectory={{.}}
+{{- end}}
+`
+	if install {
+		t += `
+[Install]
+WantedBy={{.WantedBy}}
+`
+	}
+	return t
+}
+
+// TemplateDir holds user templates, one directory per template with a
+// <type>.tmpl file for each unit it creates, e.g. worker/service.tmpl
+func TemplateDir() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "svcm", "templates")
+}
+
+// ListTemplates returns the names of the built-in and user templates
+func ListTemplates() []string {
+	names := map[string]bool{}
+	for name := range builtinTemplates {
+		names[name] = true
+	}
+	if entries, err := os.ReadDir(TemplateDir()); err == nil {
+		for _, e := range entries {
+			if e.IsDir() {
+				names[e.Name()] = true
+			}
+		}
+	}
+	list := make([]string, 0, len(names))
+	for name := range names {
+		list = append(list, name)
+	}
+	sort.Strings(list)
+	return list
+}
+
+// loadTemplate finds a template, preferring a user template over a
+// built-in one of the same name
+func loadTemplate(name string) (map[string]string, error) {
+	dir := filepath.Join(TemplateDir(), name)
+	entries, err := os.ReadDir(dir)
+	if err == nil {
+		files := map[string]string{}
+		for _, e := range entries {
+			unitType, ok := strings.CutSuffix(e.Name(), ".tmpl")
+			if !ok || e.IsDir() {
+				continue
+			}
+			if !isKnownType(unitType) {
+				return nil, fmt.Errorf("template %s: %s is not named after a unit type", name, e.Name())
+			}
+			data, err := os.ReadFile(filepath.Join(dir, e.Name()))
+			if err != nil {
+				return nil, fmt.Errorf("failed to read template: %w", err)
+			}
+			files[unitType] = string(data)
+		}
+		if len(files) == 0 {
+			return nil, fmt.Errorf("template %s has no <type>.tmpl files", name)
+		}
+		return files, nil
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("failed to read template %s: %w", name, err)
+	}
+	if files, ok := builtinTemplates[name]; ok {
+		return files, nil
+	}
+	return nil, fmt.Errorf("unknown template %q (available: %s)", name, strings.Join(ListTemplates(), ", "))
+}
+
+var unitNamePattern = regexp.MustCompile(`^[A-Za-z0-9:_.\\@-]+$`)
+
+// RenderUnits fills in a template. The first unit returned is the one to
+// enable: the timer or socket if the template has one, else the service.
+func (m *SystemdManager) RenderUnits(ctx context.Context, templateName string, spec UnitSpec) ([]UnitFileSource, error) {
+	dir, err := m.unitDir()
+	if err != nil {
+		return nil, err
+	}
+	return renderUnits(dir, m.systemMode, templateName, spec)
+}
+
+// renderUnits fills in a template for units installed into dir
+func renderUnits(dir string, systemMode bool, templateName string, spec UnitSpec) ([]UnitFileSource, error) {
+	spec.Name = strings.TrimSuffix(spec.Name, ".service")
+	if !unitNamePattern.MatchString(spec.Name) {
+		return nil, fmt.Errorf("invalid unit name %q", spec.Name)
+	}
+	if spec.Description == "" {
+		spec.Description = spec.Name
+	}
+	if spec.WantedBy == "" {
+		spec.WantedBy = "default.target"
+		if systemMode {
+			spec.WantedBy = "multi-user.target"
+		}
+	}
+
+	files, err := loadTemplate(templateName)
+	if err != nil {
+		return nil, err
+	}
+
+	types := make([]string, 0, len(files))
+	for t := range files {
+		types = append(types, t)
+	}
+	// The activating unit goes first, services last
+	sort.Slice(types, func(i, j int) bool {
+		if (types[i] == "service") != (types[j] == "service") {
+			return types[j] == "service"
+		}
+		return types[i] < types[j]
+	})
+
+	funcs := template.FuncMap{"quote": strconv.Quote}
+	units := make([]UnitFileSource, 0, len(files))
+	for _, t := range types {
+		tmpl, err := template.New(t).Funcs(funcs).Option("missingkey=error").Parse(files[t])
+		if err != nil {
+			return nil, fmt.Errorf("template %s/%s: %w", templateName, t, err)
+		}
+		var buf bytes.Buffer
+		if err := tmpl.Execute(&buf, spec); err != nil {
+			return nil, fmt.Errorf("template %s/%s: %w", templateName, t, err)
+		}
+		units = append(units, UnitFileSource{
+			Path:    filepath.Join(dir, spec.Name+"."+t),
+			Content: buf.String(),
+		})
+	}
+	return units, nil
+}
+
+var (
+	validRestart = map[string]bool{
+		"no": true, "on-success": true, "on-failure": true, "on-abnormal": true,
+		"on-watchdog": true, "on-abort": true, "always": true,
+	}
+	validServiceType = map[string]bool{
+		"simple": true, "exec": true, "forking": true, "oneshot": true,
+		"dbus": true, "notify": true, "notify-reload": true, "idle": true,
+	}
+	envKeyPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+)
+
+// LintUnit checks a generated unit file for mistakes systemd would only
+// report when the unit is loaded or started. It returns one message per
+// problem found.
+func LintUnit(path, content string) []string {
+	if err := ValidateDropIn(content); err != nil {
+		return []string{err.Error()}
+	}
+	opts, err := unit.DeserializeOptions(strings.NewReader(content))
+	if err != nil {
+		return []string{err.Error()}
+	}
+
+	values := map[string][]string{}
+	for _, o := range opts {
+		key := o.Section + "." + o.Name
+		values[key] = append(values[key], o.Value)
+	}
+	first := func(key string) string {
+		if v := values[key]; len(v) > 0 {
+			return v[len(v)-1]
+		}
+		return ""
+	}
+
+	var problems []string
+	switch UnitTypeOf(path) {
+	case "service":
+		execs := values["Service.ExecStart"]
+		if len(execs) == 0 {
+			problems = append(problems, "[Service] has no ExecStart=")
+		}
+		for _, e := range execs {
+			cmd := strings.TrimLeft(e, "-@:+!")
+			if fields := strings.Fields(cmd); len(fields) == 0 || !filepath.IsAbs(fields[0]) {
+				problems = append(problems, fmt.Sprintf("ExecStart=%s: the command must be an absolute path", e))
+			}
+		}
+		serviceType := first("Service.Type")
+		if serviceType != "" && !validServiceType[serviceType] {
+			problems = append(problems, fmt.Sprintf("Type=%s is not a service type", serviceType))
+		}
+		restart := first("Service.Restart")
+		if restart != "" && !validRestart[restart] {
+			problems = append(problems, fmt.Sprintf("Restart=%s is not a restart policy", restart))
+		}
+		if serviceType == "oneshot" && (restart == "always" || restart == "on-success") {
+			problems = append(problems, fmt.Sprintf("Restart=%s is not allowed for Type=oneshot", restart))
+		}
+		for _, env := range values["Service.Environment"] {
+			for _, assignment := range splitEnvironment(env) {
+				key, _, ok := strings.Cut(assignment, "=")
+				if !ok || !envKeyPattern.MatchString(key) {
+					problems = append(problems, fmt.Sprintf("Environment=%s is not a KEY=VALUE assignment", assignment))
+				}
+			}
+		}
+	case "timer":
+		found := false
+		for key, v := range values {
+			if strings.HasPrefix(key, "Timer.On") && v[len(v)-1] != "" {
+				found = true
+			}
+		}
+		if !found {
+			problems = append(problems, "[Timer] has no On*= setting, it would never elapse")
+		}
+	case "socket":
+		found := false
+		for key, v := range values {
+			if strings.HasPrefix(key, "Socket.Listen") && v[len(v)-1] != "" {
+				found = true
+			}
+		}
+		if !found {
+			problems = append(problems, "[Socket] has no Listen*= setting")
+		}
+	}
+	return problems
+}
+
+// splitEnvironment splits an Environment= value into assignments, honoring
+// double quotes
+func splitEnvironment(value string) []string {
+	var result []string
+	var cur strings.Builder
+	quoted := false
+	for _, r := range value {
+		switch {
+		case r == '"':
+			quoted = !quoted
+		case r == ' ' && !quoted:
+			if cur.Len() > 0 {
+				result = append(result, cur.String())
+				cur.Reset()
+			}
+		default:
+			cur.WriteRune(r)
+		}
+	}
+	if cur.Len() > 0 {
+		result = append(result, cur.String())
+	}
+	return result
+}
+
+// InstallUnits writes generated unit files and reloads systemd. Existing
+// files are only replaced when overwrite is set.
+func (m *SystemdManager) InstallUnits(ctx context.Context, units []UnitFileSource, overwrite bool) error {
+	if !overwrite {
+		for _, u := range units {
+			if _, err := os.Stat(u.Path); err == nil {
+				return fmt.Errorf("%s already exists", u.Path)
+			}
+		}
+	}
+	for _, u := range units {
+		if err := writeFileAtomic(u.Path, []byte(u.Content)); err != nil {
+			return err
+		}
+	}
+	if err := m.conn.ReloadContext(ctx); err != nil {
+		return fmt.Errorf("failed to reload systemd: %w", classify(err))
+	}
+	return nil
+}
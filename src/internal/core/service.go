@@ -1,28 +1,132 @@
 package core
 
-// ServiceUnit represents a systemd service unit
+import (
+	"context"
+	"syscall"
+)
+
+// ServiceUnit represents a systemd unit. Services were the only type svcm
+// knew originally, hence the name; Type tells which kind it is.
 type ServiceUnit struct {
 	Name        string `json:"name"`
+	Type        string `json:"type"`
 	Description string `json:"description"`
 	LoadState   string `json:"load_state"`
 	ActiveState string `json:"active_state"`
 	SubState    string `json:"sub_state"`
+	// Transient units were created at runtime, e.g. by svcm run
+	Transient bool `json:"transient,omitempty"`
 }
 
-// ServiceDetails contains extended information for status command
+// ServiceDetails contains extended information for status command. Only
+// the section matching the unit's type is set.
 type ServiceDetails struct {
 	ServiceUnit
-	MainPID                uint32
-	FragmentPath           string
-	ActiveEnterTimestamp   uint64
-	InactiveEnterTimestamp uint64
+	MainPID                uint32 `json:"main_pid"`
+	FragmentPath           string `json:"fragment_path"`
+	UnitFileState          string `json:"unit_file_state"`
+	ActiveEnterTimestamp   uint64 `json:"active_enter_timestamp"`
+	InactiveEnterTimestamp uint64 `json:"inactive_enter_timestamp"`
+	ResourceUsage
+	ControlGroup string    `json:"control_group,omitempty"`
+	Processes    []Process `json:"processes,omitempty"`
+
+	Socket *SocketDetails `json:"socket,omitempty"`
+	Path   *PathDetails   `json:"path,omitempty"`
+	Mount  *MountDetails  `json:"mount,omitempty"`
+	Target *TargetDetails `json:"target,omitempty"`
+}
+
+// TypedValue is a (type, value) pair such as a socket listener
+// ("Stream", "/run/foo.sock") or a path condition ("PathChanged", "/etc/foo")
+type TypedValue struct {
+	Type  string `json:"type"`
+	Value string `json:"value"`
+}
+
+type SocketDetails struct {
+	Listen      []TypedValue `json:"listen"`
+	Connections uint32       `json:"connections"`
+	Accepted    uint32       `json:"accepted"`
+	Triggers    []string     `json:"triggers"`
+}
+
+type PathDetails struct {
+	Conditions []TypedValue `json:"conditions"`
+	Triggers   []string     `json:"triggers"`
+}
+
+type MountDetails struct {
+	What    string `json:"what"`
+	Where   string `json:"where"`
+	FSType  string `json:"fs_type"`
+	Options string `json:"options"`
+}
+
+// TargetDetails lists the units a target pulls in and the targets that
+// pull it in
+type TargetDetails struct {
+	Wants    []string `json:"wants"`
+	Requires []string `json:"requires"`
+	WantedBy []string `json:"wanted_by"`
+}
+
+// UnitFileChange describes a symlink created or removed by systemd while
+// enabling, disabling, masking or unmasking a unit
+type UnitFileChange struct {
+	Type        string `json:"type"`
+	Filename    string `json:"filename"`
+	Destination string `json:"destination"`
+}
+
+// UnitFileSource is one file making up a unit definition
+type UnitFileSource struct {
+	Path    string `json:"path"`
+	Content string `json:"content"`
+}
+
+// UnitFile is the on-disk definition of a unit: the main fragment followed
+// by its drop-ins in the order systemd applies them
+type UnitFile struct {
+	Name     string           `json:"name"`
+	Fragment UnitFileSource   `json:"fragment"`
+	DropIns  []UnitFileSource `json:"drop_ins"`
 }
 
 // Manager defines the interface for interacting with system services
 type Manager interface {
-	ListServices() ([]ServiceUnit, error)
-	StartService(name string) error
-	StopService(name string) error
-	RestartService(name string) error
-	GetServiceDetails(name string) (*ServiceDetails, error)
+	ListServices(ctx context.Context) ([]ServiceUnit, error)
+	ListUnits(ctx context.Context, types ...string) ([]ServiceUnit, error)
+	StartService(ctx context.Context, name string) error
+	StopService(ctx context.Context, name string) error
+	RestartService(ctx context.Context, name string) error
+	ReloadService(ctx context.Context, name string) error
+	ReloadOrRestartService(ctx context.Context, name string) error
+	TryRestartService(ctx context.Context, name string) error
+	ResetFailedService(ctx context.Context, name string) error
+	ResetAllFailed(ctx context.Context) ([]string, error)
+	GetServiceDetails(ctx context.Context, name string) (*ServiceDetails, error)
+	EnableService(ctx context.Context, name string, now bool) ([]UnitFileChange, error)
+	DisableService(ctx context.Context, name string, now bool) ([]UnitFileChange, error)
+	MaskService(ctx context.Context, name string, now bool) ([]UnitFileChange, error)
+	UnmaskService(ctx context.Context, name string) ([]UnitFileChange, error)
+	Subscribe(ctx context.Context) (*Subscription, error)
+	GetLogs(ctx context.Context, q LogQuery) ([]LogEntry, error)
+	FollowLogs(ctx context.Context, q LogQuery) (*LogStream, error)
+	GetUnitFile(ctx context.Context, name string) (*UnitFile, error)
+	ListTimers(ctx context.Context) ([]TimerUnit, error)
+	GetTimer(ctx context.Context, name string) (*TimerUnit, error)
+	TriggerTimer(ctx context.Context, name string) (string, error)
+	Dependencies(ctx context.Context, name string, q DependencyQuery) (*DependencyNode, error)
+	DropInPath(ctx context.Context, name string) (string, error)
+	EditDropIn(ctx context.Context, name string, edit func(path string) error) (string, bool, error)
+	RenderUnits(ctx context.Context, template string, spec UnitSpec) ([]UnitFileSource, error)
+	InstallUnits(ctx context.Context, units []UnitFileSource, overwrite bool) error
+	RunTransient(ctx context.Context, spec TransientSpec) (string, error)
+	WaitTransient(ctx context.Context, name string) (*TransientResult, error)
+	GetUsage(ctx context.Context, names []string) (map[string]ResourceUsage, error)
+	GetProcesses(ctx context.Context, name string) ([]Process, error)
+	SignalProcess(ctx context.Context, name string, pid uint32, sig syscall.Signal) error
+	KillService(ctx context.Context, name, who string, sig syscall.Signal) error
+	Close()
 }
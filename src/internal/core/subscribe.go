@@ -0,0 +1,239 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"strconv"
+	"sync"
+
+	godbus "github.com/godbus/dbus/v5"
+)
+
+// UnitEventKind tells what happened to a unit in a UnitEvent
+type UnitEventKind string
+
+const (
+	UnitChanged  UnitEventKind = "changed"
+	UnitAdded    UnitEventKind = "added"
+	UnitRemoved  UnitEventKind = "removed"
+	JobCompleted UnitEventKind = "job_completed"
+)
+
+// UnitEvent is a single change reported by systemd. For UnitChanged only the
+// state fields present in the signal are filled in; empty fields mean
+// "unchanged".
+type UnitEvent struct {
+	Kind      UnitEventKind `json:"kind"`
+	Unit      ServiceUnit   `json:"unit"`
+	JobID     uint32        `json:"job_id,omitempty"`
+	JobResult string        `json:"job_result,omitempty"`
+}
+
+// Subscription streams unit events until it is closed or the bus connection
+// drops, at which point Events is closed and Err reports the reason.
+type Subscription struct {
+	Events <-chan UnitEvent
+
+	// release frees what feeds the stream, e.g. the bus connection
+	release   func()
+	done      chan struct{}
+	closeOnce sync.Once
+	mu        sync.Mutex
+	err       error
+}
+
+// Close stops the subscription and releases its bus connection.
+func (s *Subscription) Close() {
+	s.closeOnce.Do(func() {
+		close(s.done)
+		s.release()
+	})
+}
+
+// Err returns why the event stream ended, or nil if it was closed normally.
+func (s *Subscription) Err() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
+}
+
+const (
+	systemdDest      = "org.freedesktop.systemd1"
+	systemdPath      = "/org/freedesktop/systemd1"
+	systemdManagerIf = "org.freedesktop.systemd1.Manager"
+	systemdUnitIf    = "org.freedesktop.systemd1.Unit"
+	propertiesIf     = "org.freedesktop.DBus.Properties"
+)
+
+// privateBus opens a bus connection of our own to the manager's bus, for
+// calls and signals go-systemd does not cover
+func (m *SystemdManager) privateBus() (*godbus.Conn, error) {
+	var conn *godbus.Conn
+	var err error
+	if m.systemMode {
+		conn, err = godbus.SystemBusPrivate()
+	} else {
+		conn, err = godbus.SessionBusPrivate()
+	}
+	if err != nil {
+		return nil, classify(err)
+	}
+
+	if err := conn.Auth([]godbus.Auth{godbus.AuthExternal(strconv.Itoa(os.Getuid()))}); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to authenticate: %w", err)
+	}
+	if err := conn.Hello(); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to register: %w", err)
+	}
+	return conn, nil
+}
+
+// Subscribe listens for UnitNew, UnitRemoved, JobRemoved and
+// PropertiesChanged signals on a dedicated bus connection. go-systemd only
+// forwards a subset of these, so the signals are decoded here directly.
+// ctx only bounds setting up the subscription; it runs until closed.
+func (m *SystemdManager) Subscribe(ctx context.Context) (*Subscription, error) {
+	conn, err := m.privateBus()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open signal connection: %w", err)
+	}
+
+	matches := [][]godbus.MatchOption{
+		{godbus.WithMatchInterface(systemdManagerIf), godbus.WithMatchMember("UnitNew")},
+		{godbus.WithMatchInterface(systemdManagerIf), godbus.WithMatchMember("UnitRemoved")},
+		{godbus.WithMatchInterface(systemdManagerIf), godbus.WithMatchMember("JobRemoved")},
+		{godbus.WithMatchInterface(propertiesIf), godbus.WithMatchMember("PropertiesChanged"), godbus.WithMatchArg(0, systemdUnitIf)},
+	}
+	for _, opts := range matches {
+		opts = append(opts, godbus.WithMatchSender(systemdDest))
+		if err := conn.AddMatchSignal(opts...); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("failed to add signal match: %w", err)
+		}
+	}
+
+	// Without Subscribe systemd only emits signals for units someone asked about
+	if err := conn.Object(systemdDest, systemdPath).CallWithContext(ctx, systemdManagerIf+".Subscribe", 0).Store(); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to subscribe to systemd: %w", classify(err))
+	}
+
+	signals := make(chan *godbus.Signal, 64)
+	conn.Signal(signals)
+
+	events := make(chan UnitEvent, 64)
+	sub := &Subscription{Events: events, release: func() { conn.Close() }, done: make(chan struct{})}
+
+	go func() {
+		defer close(events)
+		for {
+			select {
+			case <-sub.done:
+				return
+			case sig, ok := <-signals:
+				if !ok {
+					sub.mu.Lock()
+					sub.err = fmt.Errorf("systemd signal connection closed")
+					sub.mu.Unlock()
+					return
+				}
+				ev, ok := decodeSignal(sig)
+				if !ok {
+					continue
+				}
+				select {
+				case events <- ev:
+				case <-sub.done:
+					return
+				}
+			}
+		}
+	}()
+
+	return sub, nil
+}
+
+func decodeSignal(sig *godbus.Signal) (UnitEvent, bool) {
+	switch sig.Name {
+	case systemdManagerIf + ".UnitNew", systemdManagerIf + ".UnitRemoved":
+		if len(sig.Body) < 1 {
+			return UnitEvent{}, false
+		}
+		id, ok := sig.Body[0].(string)
+		if !ok {
+			return UnitEvent{}, false
+		}
+		kind := UnitAdded
+		if sig.Name == systemdManagerIf+".UnitRemoved" {
+			kind = UnitRemoved
+		}
+		return UnitEvent{Kind: kind, Unit: ServiceUnit{Name: id, Type: UnitTypeOf(id)}}, true
+
+	case systemdManagerIf + ".JobRemoved":
+		if len(sig.Body) < 4 {
+			return UnitEvent{}, false
+		}
+		id, _ := sig.Body[0].(uint32)
+		unit, _ := sig.Body[2].(string)
+		result, _ := sig.Body[3].(string)
+		return UnitEvent{Kind: JobCompleted, Unit: ServiceUnit{Name: unit, Type: UnitTypeOf(unit)}, JobID: id, JobResult: result}, true
+
+	case propertiesIf + ".PropertiesChanged":
+		if len(sig.Body) < 2 {
+			return UnitEvent{}, false
+		}
+		if iface, _ := sig.Body[0].(string); iface != systemdUnitIf {
+			return UnitEvent{}, false
+		}
+		changed, ok := sig.Body[1].(map[string]godbus.Variant)
+		if !ok {
+			return UnitEvent{}, false
+		}
+		getString := func(k string) string {
+			if v, ok := changed[k]; ok {
+				if s, ok := v.Value().(string); ok {
+					return s
+				}
+			}
+			return ""
+		}
+		unit := ServiceUnit{
+			Name:        getString("Id"),
+			Description: getString("Description"),
+			LoadState:   getString("LoadState"),
+			ActiveState: getString("ActiveState"),
+			SubState:    getString("SubState"),
+		}
+		if unit.Name == "" {
+			unit.Name = unitNameFromPath(sig.Path)
+		}
+		unit.Type = UnitTypeOf(unit.Name)
+		if unit.ActiveState == "" && unit.SubState == "" && unit.LoadState == "" && unit.Description == "" {
+			return UnitEvent{}, false
+		}
+		return UnitEvent{Kind: UnitChanged, Unit: unit}, true
+	}
+	return UnitEvent{}, false
+}
+
+// unitNameFromPath reverses systemd's object path escaping, where every
+// byte outside [A-Za-z0-9] is written as _xx.
+func unitNameFromPath(p godbus.ObjectPath) string {
+	escaped := path.Base(string(p))
+	name := make([]byte, 0, len(escaped))
+	for i := 0; i < len(escaped); i++ {
+		if escaped[i] == '_' && i+2 < len(escaped) {
+			if b, err := strconv.ParseUint(escaped[i+1:i+3], 16, 8); err == nil {
+				name = append(name, byte(b))
+				i += 2
+				continue
+			}
+		}
+		name = append(name, escaped[i])
+	}
+	return string(name)
+}
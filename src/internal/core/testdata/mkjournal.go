@@ -0,0 +1,315 @@
+//go:build ignore
+
+// mkjournal writes test.journal, the journal the tests of journal.go read
+// through LogQuery.Files. It emits the journal file format directly so no
+// systemd tools are needed; check the result with
+//
+//	go run mkjournal.go && journalctl --file test.journal --verify
+package main
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	bootA = "0b7f3a5c9e1d4f2a8c6b0e9d7a5f3c1e"
+	bootB = "5d2e8f1a3c7b4e9d0f6a2c8e4b1d7f3a"
+)
+
+// entries are the records of test.journal, oldest first
+var entries = []struct {
+	boot   string
+	offset time.Duration
+	fields []string
+}{
+	{bootA, 0, []string{"MESSAGE=Starting web.service...", "PRIORITY=6", "SYSLOG_IDENTIFIER=systemd", "UNIT=web.service", "_PID=1", "_UID=0"}},
+	{bootA, time.Second, []string{"MESSAGE=Serving HTTP on port 8080", "PRIORITY=6", "SYSLOG_IDENTIFIER=web", "_SYSTEMD_UNIT=web.service", "_PID=2101", "_UID=0"}},
+	{bootA, 5 * time.Minute, []string{"MESSAGE=GET /missing 404", "PRIORITY=4", "SYSLOG_IDENTIFIER=web", "_SYSTEMD_UNIT=web.service", "_PID=2101", "_UID=0"}},
+	{bootA, 10 * time.Minute, []string{"MESSAGE=connection refused", "PRIORITY=3", "SYSLOG_IDENTIFIER=db", "_SYSTEMD_UNIT=db.service", "_PID=2200", "_UID=0"}},
+	{bootA, 15 * time.Minute, []string{"MESSAGE=user web listening", "PRIORITY=6", "SYSLOG_IDENTIFIER=web", "_SYSTEMD_USER_UNIT=web.service", "_PID=3100", "_UID=1000"}},
+	{bootA, 16 * time.Minute, []string{"MESSAGE=Started web.service.", "PRIORITY=6", "SYSLOG_IDENTIFIER=systemd", "USER_UNIT=web.service", "_PID=1500", "_UID=1000"}},
+	{bootA, 17 * time.Minute, []string{"MESSAGE=other user web listening", "PRIORITY=6", "SYSLOG_IDENTIFIER=web", "_SYSTEMD_USER_UNIT=web.service", "_PID=3200", "_UID=1001"}},
+	{bootB, time.Hour, []string{"MESSAGE=Serving HTTP again", "PRIORITY=6", "SYSLOG_IDENTIFIER=web", "_SYSTEMD_UNIT=web.service", "_PID=2101", "_UID=0"}},
+	{bootB, time.Hour + time.Minute, []string{"MESSAGE=GET / 200", "PRIORITY=7", "SYSLOG_IDENTIFIER=web", "_SYSTEMD_UNIT=web.service", "_PID=2101", "_UID=0"}},
+	{bootB, time.Hour + 2*time.Minute, []string{"MESSAGE=db ready", "PRIORITY=5", "SYSLOG_IDENTIFIER=db", "_SYSTEMD_UNIT=db.service", "_PID=2200", "_UID=0"}},
+}
+
+// start is the time of the first entry
+var start = time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+
+// Object types
+const (
+	objectData           = 1
+	objectField          = 2
+	objectEntry          = 3
+	objectDataHashTable  = 4
+	objectFieldHashTable = 5
+	objectEntryArray     = 6
+)
+
+const (
+	headerSize    = 256
+	dataBuckets   = 64
+	fieldBuckets  = 16
+	objectHdrSize = 16
+)
+
+var le = binary.LittleEndian
+
+type dataObject struct {
+	offset  uint64
+	hash    uint64
+	entries []uint64
+}
+
+type fieldObject struct {
+	offset uint64
+	hash   uint64
+	data   []uint64
+}
+
+type writer struct {
+	buf []byte
+	n   uint64 // objects written
+	// tail is the offset of the last object
+	tail uint64
+}
+
+// object appends an object of the given type and payload size, 8-byte
+// aligned, and returns its offset
+func (w *writer) object(typ byte, size int) uint64 {
+	for len(w.buf)%8 != 0 {
+		w.buf = append(w.buf, 0)
+	}
+	offset := uint64(len(w.buf))
+	w.buf = append(w.buf, make([]byte, objectHdrSize+size)...)
+	w.buf[offset] = typ
+	le.PutUint64(w.buf[offset+8:], uint64(objectHdrSize+size))
+	w.n++
+	w.tail = offset
+	return offset
+}
+
+func (w *writer) put(at, v uint64) {
+	le.PutUint64(w.buf[at:], v)
+}
+
+func main() {
+	w := &writer{buf: make([]byte, headerSize)}
+
+	fieldTable := w.object(objectFieldHashTable, fieldBuckets*16) + objectHdrSize
+	dataTable := w.object(objectDataHashTable, dataBuckets*16) + objectHdrSize
+
+	// link appends an object to the chain of its hash table bucket
+	link := func(table uint64, buckets int, hash, offset uint64, nextAt func(uint64) uint64) {
+		bucket := table + (hash%uint64(buckets))*16
+		if tail := le.Uint64(w.buf[bucket+8:]); tail != 0 {
+			w.put(nextAt(tail), offset)
+		} else {
+			w.put(bucket, offset)
+		}
+		w.put(bucket+8, offset)
+	}
+
+	fields := map[string]*fieldObject{}
+	data := map[string]*dataObject{}
+	var nData, nFields uint64
+	addData := func(payload string) *dataObject {
+		if d, ok := data[payload]; ok {
+			return d
+		}
+		name := payload[:strings.IndexByte(payload, '=')]
+		f, ok := fields[name]
+		if !ok {
+			f = &fieldObject{hash: jenkinsHash64([]byte(name))}
+			f.offset = w.object(objectField, 24+len(name))
+			w.put(f.offset+16, f.hash)
+			copy(w.buf[f.offset+40:], name)
+			link(fieldTable, fieldBuckets, f.hash, f.offset, func(o uint64) uint64 { return o + 24 })
+			fields[name] = f
+			nFields++
+		}
+
+		d := &dataObject{hash: jenkinsHash64([]byte(payload))}
+		d.offset = w.object(objectData, 48+len(payload))
+		w.put(d.offset+16, d.hash)
+		copy(w.buf[d.offset+64:], payload)
+		link(dataTable, dataBuckets, d.hash, d.offset, func(o uint64) uint64 { return o + 24 })
+		// Chain the data objects of a field, newest first like journald
+		if len(f.data) > 0 {
+			w.put(d.offset+32, f.data[len(f.data)-1])
+		}
+		w.put(f.offset+32, d.offset)
+		f.data = append(f.data, d.offset)
+		data[payload] = d
+		nData++
+		return d
+	}
+
+	var entryOffsets []uint64
+	var monotonic uint64
+	var lastBoot string
+	for i, e := range entries {
+		var items []*dataObject
+		for _, payload := range append([]string{"_BOOT_ID=" + e.boot, "_HOSTNAME=testhost", "_TRANSPORT=journal"}, e.fields...) {
+			items = append(items, addData(payload))
+		}
+		sort.Slice(items, func(a, b int) bool { return items[a].offset < items[b].offset })
+
+		if e.boot != lastBoot {
+			monotonic = 5_000_000
+			lastBoot = e.boot
+		}
+		monotonic += 1_000_000
+
+		offset := w.object(objectEntry, 48+16*len(items))
+		w.put(offset+16, uint64(i+1))
+		w.put(offset+24, uint64(start.Add(e.offset).UnixMicro()))
+		w.put(offset+32, monotonic)
+		copy(w.buf[offset+40:], id128(e.boot))
+		var xor uint64
+		for k, d := range items {
+			w.put(offset+64+uint64(k)*16, d.offset)
+			w.put(offset+72+uint64(k)*16, d.hash)
+			xor ^= d.hash
+			d.entries = append(d.entries, offset)
+		}
+		w.put(offset+56, xor)
+		entryOffsets = append(entryOffsets, offset)
+	}
+
+	// Data objects keep their first entry inline and the rest in an array
+	var nArrays uint64
+	for _, d := range data {
+		w.put(d.offset+40, d.entries[0])
+		w.put(d.offset+56, uint64(len(d.entries)))
+		if rest := d.entries[1:]; len(rest) > 0 {
+			w.put(d.offset+48, entryArray(w, rest))
+			nArrays++
+		}
+	}
+	mainArray := entryArray(w, entryOffsets)
+	nArrays++
+
+	h := w.buf
+	copy(h, "LPKSHHRH")
+	copy(h[24:], id128("4a1d8e6f2b9c4d7e8a3f5b1c9e2d6a4f")) // file_id
+	copy(h[40:], id128("9f8e7d6c5b4a39281706f5e4d3c2b1a0")) // machine_id
+	copy(h[56:], id128(bootB))
+	copy(h[72:], id128("c3b2a19f8e7d4c6b5a4938271605f4e3")) // seqnum_id
+	last := entries[len(entries)-1]
+	fields64 := []struct {
+		at, v uint64
+	}{
+		{88, headerSize},
+		{96, uint64(len(w.buf)) - headerSize},
+		{104, dataTable},
+		{112, dataBuckets * 16},
+		{120, fieldTable},
+		{128, fieldBuckets * 16},
+		{136, w.tail},
+		{144, w.n},
+		{152, uint64(len(entries))},
+		{160, uint64(len(entries))},
+		{168, 1},
+		{176, mainArray},
+		{184, uint64(start.UnixMicro())},
+		{192, uint64(start.Add(last.offset).UnixMicro())},
+		{200, monotonic},
+		{208, nData},
+		{216, nFields},
+		{224, 0},
+		{232, nArrays},
+		{240, 1},
+		{248, 1},
+	}
+	for _, f := range fields64 {
+		w.put(f.at, f.v)
+	}
+
+	if err := os.WriteFile("test.journal", w.buf, 0o644); err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("wrote test.journal: %d entries, %d bytes\n", len(entries), len(w.buf))
+}
+
+// entryArray writes an entry array object holding offsets
+func entryArray(w *writer, offsets []uint64) uint64 {
+	offset := w.object(objectEntryArray, 8+8*len(offsets))
+	for i, o := range offsets {
+		w.put(offset+24+uint64(i)*8, o)
+	}
+	return offset
+}
+
+func id128(s string) []byte {
+	b, err := hex.DecodeString(s)
+	if err != nil || len(b) != 16 {
+		log.Fatalf("bad id %s", s)
+	}
+	return b
+}
+
+// jenkinsHash64 is the lookup3 hashlittle2 hash journal files without the
+// keyed-hash flag use
+func jenkinsHash64(k []byte) uint64 {
+	rot := func(x uint32, n uint) uint32 { return x<<n | x>>(32-n) }
+	a := 0xdeadbeef + uint32(len(k))
+	b, c := a, a
+
+	for len(k) > 12 {
+		a += le.Uint32(k[0:])
+		b += le.Uint32(k[4:])
+		c += le.Uint32(k[8:])
+		a -= c
+		a ^= rot(c, 4)
+		c += b
+		b -= a
+		b ^= rot(a, 6)
+		a += c
+		c -= b
+		c ^= rot(b, 8)
+		b += a
+		a -= c
+		a ^= rot(c, 16)
+		c += b
+		b -= a
+		b ^= rot(a, 19)
+		a += c
+		c -= b
+		c ^= rot(b, 4)
+		b += a
+		k = k[12:]
+	}
+	if len(k) == 0 {
+		return uint64(c)<<32 | uint64(b)
+	}
+
+	var tail [12]byte
+	copy(tail[:], k)
+	a += le.Uint32(tail[0:])
+	b += le.Uint32(tail[4:])
+	c += le.Uint32(tail[8:])
+	c ^= b
+	c -= rot(b, 14)
+	a ^= c
+	a -= rot(c, 11)
+	b ^= a
+	b -= rot(a, 25)
+	c ^= b
+	c -= rot(b, 16)
+	a ^= c
+	a -= rot(c, 4)
+	b ^= a
+	b -= rot(a, 14)
+	c ^= b
+	c -= rot(b, 24)
+	return uint64(c)<<32 | uint64(b)
+}
@@ -0,0 +1,229 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// TimerUnit is a timer together with the unit it activates. Timestamps are
+// microseconds since the epoch like the other systemd timestamps; zero
+// means never (LastTriggerUSec) or not scheduled (NextElapseUSec).
+type TimerUnit struct {
+	ServiceUnit
+	Unit            string   `json:"unit"`
+	NextElapseUSec  uint64   `json:"next_elapse_usec"`
+	LastTriggerUSec uint64   `json:"last_trigger_usec"`
+	Persistent      bool     `json:"persistent"`
+	AccuracyUSec    uint64   `json:"accuracy_usec"`
+	Triggers        []string `json:"triggers"`
+}
+
+// NextElapse returns when the timer fires next, or the zero time
+func (t TimerUnit) NextElapse() time.Time {
+	if t.NextElapseUSec == 0 {
+		return time.Time{}
+	}
+	return time.UnixMicro(int64(t.NextElapseUSec))
+}
+
+// LastTrigger returns when the timer last fired, or the zero time
+func (t TimerUnit) LastTrigger() time.Time {
+	if t.LastTriggerUSec == 0 {
+		return time.Time{}
+	}
+	return time.UnixMicro(int64(t.LastTriggerUSec))
+}
+
+func ensureTimerSuffix(name string) string {
+	if !strings.HasSuffix(name, ".timer") {
+		return name + ".timer"
+	}
+	return name
+}
+
+// ListTimers returns all loaded timers, soonest first like systemctl
+// list-timers; timers with nothing scheduled come last.
+func (m *SystemdManager) ListTimers(ctx context.Context) ([]TimerUnit, error) {
+	units, err := m.conn.ListUnitsByPatternsContext(ctx, nil, []string{"*.timer"})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list units: %w", classify(err))
+	}
+
+	timers := make([]TimerUnit, 0, len(units))
+	for _, u := range units {
+		t := TimerUnit{ServiceUnit: ServiceUnit{
+			Name:        u.Name,
+			Type:        "timer",
+			Description: u.Description,
+			LoadState:   u.LoadState,
+			ActiveState: u.ActiveState,
+			SubState:    u.SubState,
+		}}
+		if err := m.loadTimerProperties(ctx, &t); err != nil {
+			return nil, err
+		}
+		timers = append(timers, t)
+	}
+	sortTimers(timers)
+	return timers, nil
+}
+
+// sortTimers orders timers soonest first, unscheduled ones last
+func sortTimers(timers []TimerUnit) {
+	sort.SliceStable(timers, func(i, j int) bool {
+		a, b := timers[i].NextElapseUSec, timers[j].NextElapseUSec
+		if a == 0 || b == 0 {
+			return b == 0 && a != 0
+		}
+		return a < b
+	})
+}
+
+// GetTimer returns a single timer
+func (m *SystemdManager) GetTimer(ctx context.Context, name string) (*TimerUnit, error) {
+	name = ensureTimerSuffix(name)
+	props, err := m.conn.GetUnitPropertiesContext(ctx, name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get properties for %s: %w", name, classify(err))
+	}
+	getString := func(k string) string {
+		v, _ := props[k].(string)
+		return v
+	}
+
+	t := &TimerUnit{ServiceUnit: ServiceUnit{
+		Name:        name,
+		Type:        "timer",
+		Description: getString("Description"),
+		LoadState:   getString("LoadState"),
+		ActiveState: getString("ActiveState"),
+		SubState:    getString("SubState"),
+	}}
+	if t.LoadState == "not-found" {
+		return nil, notFound("timer %s not found", name)
+	}
+	if err := m.loadTimerProperties(ctx, t); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// TriggerTimer starts the unit a timer activates without waiting for the
+// timer to elapse, and returns that unit's name. The start job is queued;
+// its progress can be followed through Subscribe.
+func (m *SystemdManager) TriggerTimer(ctx context.Context, name string) (string, error) {
+	t, err := m.GetTimer(ctx, name)
+	if err != nil {
+		return "", err
+	}
+	if t.Unit == "" {
+		return "", fmt.Errorf("timer %s does not activate any unit", t.Name)
+	}
+	mode, err := jobMode(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to start %s for timer %s: %w", t.Unit, t.Name, err)
+	}
+	if _, err := m.conn.StartUnitContext(ctx, t.Unit, mode, nil); err != nil {
+		return "", fmt.Errorf("failed to start %s for timer %s: %w", t.Unit, t.Name, classify(err))
+	}
+	return t.Unit, nil
+}
+
+func (m *SystemdManager) loadTimerProperties(ctx context.Context, t *TimerUnit) error {
+	props, err := m.conn.GetUnitTypePropertiesContext(ctx, t.Name, "Timer")
+	if err != nil {
+		return fmt.Errorf("failed to get timer properties for %s: %w", t.Name, classify(err))
+	}
+	getUint64 := func(k string) uint64 {
+		v, _ := props[k].(uint64)
+		return v
+	}
+
+	t.Unit, _ = props["Unit"].(string)
+	t.Persistent, _ = props["Persistent"].(bool)
+	t.AccuracyUSec = getUint64("AccuracyUSec")
+	t.LastTriggerUSec = getUint64("LastTriggerUSec")
+
+	// A timer has a realtime deadline from OnCalendar= and a monotonic one
+	// from the On*Sec= settings; the earlier of the two fires first
+	next := getUint64("NextElapseUSecRealtime")
+	if mono := getUint64("NextElapseUSecMonotonic"); mono != 0 {
+		if rt := monotonicToRealtime(mono); rt != 0 && (next == 0 || rt < next) {
+			next = rt
+		}
+	}
+	t.NextElapseUSec = next
+
+	t.Triggers = []string{}
+	if list, ok := props["TimersCalendar"].([][]interface{}); ok {
+		for _, entry := range list {
+			if len(entry) >= 2 {
+				t.Triggers = append(t.Triggers, fmt.Sprintf("%v=%v", entry[0], entry[1]))
+			}
+		}
+	}
+	if list, ok := props["TimersMonotonic"].([][]interface{}); ok {
+		for _, entry := range list {
+			if len(entry) < 2 {
+				continue
+			}
+			base, _ := entry[0].(string)
+			usec, _ := entry[1].(uint64)
+			// systemd names these OnBootUSec etc. on the bus
+			base = strings.TrimSuffix(base, "USec") + "Sec"
+			t.Triggers = append(t.Triggers, fmt.Sprintf("%s=%s", base, time.Duration(usec)*time.Microsecond))
+		}
+	}
+	return nil
+}
+
+// monotonicToRealtime converts a CLOCK_MONOTONIC timestamp in microseconds
+// to microseconds since the epoch.
+func monotonicToRealtime(mono uint64) uint64 {
+	var ts unix.Timespec
+	if err := unix.ClockGettime(unix.CLOCK_MONOTONIC, &ts); err != nil {
+		return 0
+	}
+	now := uint64(time.Now().UnixMicro())
+	monoNow := uint64(ts.Nano() / 1000)
+	if mono > monoNow {
+		return now + (mono - monoNow)
+	}
+	return now - (monoNow - mono)
+}
+
+// FormatDuration renders a duration the way systemctl does, with the two
+// largest units, e.g. "2h 13min" or "45s".
+func FormatDuration(d time.Duration) string {
+	if d < 0 {
+		d = -d
+	}
+	if d < time.Second {
+		return fmt.Sprintf("%dms", d.Milliseconds())
+	}
+	units := []struct {
+		size time.Duration
+		name string
+	}{
+		{24 * time.Hour, "d"},
+		{time.Hour, "h"},
+		{time.Minute, "min"},
+		{time.Second, "s"},
+	}
+	var parts []string
+	for _, u := range units {
+		if d >= u.size {
+			parts = append(parts, fmt.Sprintf("%d%s", d/u.size, u.name))
+			d %= u.size
+		}
+		if len(parts) == 2 {
+			break
+		}
+	}
+	return strings.Join(parts, " ")
+}
@@ -0,0 +1,240 @@
+package core
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/coreos/go-systemd/v22/dbus"
+	godbus "github.com/godbus/dbus/v5"
+)
+
+// TransientSpec describes a command to run as a transient service, the
+// equivalent of systemd-run
+type TransientSpec struct {
+	// Name is the unit name without a suffix; a random run-* name is used
+	// when empty
+	Name             string
+	Command          []string
+	Description      string
+	Environment      []string
+	WorkingDirectory string
+	// MemoryMax in bytes, 0 for no limit
+	MemoryMax uint64
+	// CPUQuota in percent of one CPU, 0 for no limit
+	CPUQuota uint64
+	// OnCalendar runs the command from a transient timer instead of right away
+	OnCalendar string
+	// Collect unloads the unit even if it failed
+	Collect bool
+	// Wait keeps the unit referenced so WaitTransient can read its result
+	Wait bool
+}
+
+// TransientResult is how a transient service ended
+type TransientResult struct {
+	Unit   string `json:"unit"`
+	Result string `json:"result"`
+	// Code is how the main process ended: exited, killed or dumped
+	Code        string `json:"code"`
+	Status      int    `json:"status"`
+	RuntimeUSec uint64 `json:"runtime_usec"`
+}
+
+// ExitStatus maps the result onto a shell exit status: the exit code of the
+// main process, or 128 plus the signal that killed it
+func (r *TransientResult) ExitStatus() int {
+	switch r.Code {
+	case "exited":
+		return r.Status
+	case "killed", "dumped":
+		return 128 + r.Status
+	}
+	if r.Result != "success" {
+		return 1
+	}
+	return 0
+}
+
+// RunTransient starts a command as a transient service, or schedules it
+// with a transient timer when OnCalendar is set. It returns the name of the
+// unit started.
+func (m *SystemdManager) RunTransient(ctx context.Context, spec TransientSpec) (string, error) {
+	if len(spec.Command) == 0 {
+		return "", fmt.Errorf("no command given")
+	}
+	if spec.Wait && spec.OnCalendar != "" {
+		return "", fmt.Errorf("cannot wait for a command run from a timer")
+	}
+	name := spec.Name
+	if name == "" {
+		suffix := make([]byte, 4)
+		if _, err := rand.Read(suffix); err != nil {
+			return "", fmt.Errorf("failed to generate a unit name: %w", classify(err))
+		}
+		name = "run-r" + hex.EncodeToString(suffix)
+	}
+	name = strings.TrimSuffix(name, ".service")
+	if !unitNamePattern.MatchString(name) {
+		return "", fmt.Errorf("invalid unit name %q", name)
+	}
+	if spec.Description == "" {
+		spec.Description = "[svcm run] " + strings.Join(spec.Command, " ")
+	}
+
+	// systemd-run resolves the command the same way, ExecStart= needs a path
+	command := append([]string(nil), spec.Command...)
+	if !filepath.IsAbs(command[0]) {
+		path, err := exec.LookPath(command[0])
+		if err != nil {
+			return "", err
+		}
+		if command[0], err = filepath.Abs(path); err != nil {
+			return "", err
+		}
+	}
+
+	service := []dbus.Property{
+		dbus.PropDescription(spec.Description),
+		dbus.PropType("exec"),
+		dbus.PropExecStart(command, true),
+	}
+	if len(spec.Environment) > 0 {
+		service = append(service, dbus.Property{Name: "Environment", Value: godbus.MakeVariant(spec.Environment)})
+	}
+	if spec.WorkingDirectory != "" {
+		service = append(service, dbus.Property{Name: "WorkingDirectory", Value: godbus.MakeVariant(spec.WorkingDirectory)})
+	}
+	if spec.MemoryMax > 0 {
+		service = append(service, dbus.Property{Name: "MemoryMax", Value: godbus.MakeVariant(spec.MemoryMax)})
+	}
+	if spec.CPUQuota > 0 {
+		// CPUQuota=100% is one second of CPU time per second
+		service = append(service, dbus.Property{Name: "CPUQuotaPerSecUSec", Value: godbus.MakeVariant(spec.CPUQuota * 10000)})
+	}
+	if spec.Collect {
+		service = append(service, dbus.Property{Name: "CollectMode", Value: godbus.MakeVariant("inactive-or-failed")})
+	}
+	if spec.Wait {
+		service = append(service, dbus.Property{Name: "AddRef", Value: godbus.MakeVariant(true)})
+	}
+
+	ch := make(chan string, 1)
+	var id int
+	var err error
+	if spec.OnCalendar != "" {
+		timer := []dbus.Property{
+			dbus.PropDescription(spec.Description),
+			{Name: "OnCalendar", Value: godbus.MakeVariant(spec.OnCalendar)},
+			{Name: "RemainAfterElapse", Value: godbus.MakeVariant(false)},
+		}
+		aux := []dbus.PropertyCollection{{Name: name + ".service", Properties: service}}
+		name += ".timer"
+		if id, err = m.conn.StartTransientUnitAux(ctx, name, "fail", timer, aux, ch); err != nil {
+			return "", fmt.Errorf("failed to start transient timer %s: %w", name, classify(err))
+		}
+	} else {
+		name += ".service"
+		if id, err = m.conn.StartTransientUnitContext(ctx, name, "fail", service, ch); err != nil {
+			return "", fmt.Errorf("failed to start transient service %s: %w", name, classify(err))
+		}
+	}
+	return name, m.waitJob(ctx, "start", name, id, ch)
+}
+
+// WaitTransient waits for a service started by RunTransient with Wait set
+// to finish and returns its result.
+func (m *SystemdManager) WaitTransient(ctx context.Context, name string) (*TransientResult, error) {
+	name = UnitName(name)
+	for {
+		props, err := m.conn.GetAllPropertiesContext(ctx, name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get properties for %s: %w", name, classify(err))
+		}
+		state, _ := props["ActiveState"].(string)
+		if state == "inactive" || state == "failed" {
+			return transientResult(name, props), nil
+		}
+		select {
+		case <-time.After(200 * time.Millisecond):
+		case <-ctx.Done():
+			return nil, fmt.Errorf("gave up waiting for %s: %w", name, classify(ctx.Err()))
+		}
+	}
+}
+
+func transientResult(name string, props map[string]interface{}) *TransientResult {
+	r := &TransientResult{Unit: name}
+	r.Result, _ = props["Result"].(string)
+	code, _ := props["ExecMainCode"].(int32)
+	status, _ := props["ExecMainStatus"].(int32)
+	r.Status = int(status)
+	// Values of si_code for SIGCHLD
+	switch code {
+	case 1:
+		r.Code = "exited"
+	case 2:
+		r.Code = "killed"
+	case 3:
+		r.Code = "dumped"
+	}
+	start, _ := props["ExecMainStartTimestamp"].(uint64)
+	exit, _ := props["ExecMainExitTimestamp"].(uint64)
+	if start != 0 && exit > start {
+		r.RuntimeUSec = exit - start
+	}
+	return r
+}
+
+// transientUnits returns the names of the transient units systemd has
+// written to its runtime directory
+func (m *SystemdManager) transientUnits() map[string]bool {
+	dir := "/run/systemd/transient"
+	if !m.systemMode {
+		runtime := os.Getenv("XDG_RUNTIME_DIR")
+		if runtime == "" {
+			runtime = "/run/user/" + strconv.Itoa(os.Getuid())
+		}
+		dir = filepath.Join(runtime, "systemd", "transient")
+	}
+	units := map[string]bool{}
+	entries, _ := os.ReadDir(dir)
+	for _, e := range entries {
+		units[e.Name()] = true
+	}
+	return units
+}
+
+// ParseSize parses a byte size with an optional K, M, G or T suffix, which
+// like in systemd are powers of 1024
+func ParseSize(s string) (uint64, error) {
+	multiplier := uint64(1)
+	number := strings.TrimSpace(s)
+	if number != "" {
+		switch strings.ToUpper(number[len(number)-1:]) {
+		case "K":
+			multiplier = 1 << 10
+		case "M":
+			multiplier = 1 << 20
+		case "G":
+			multiplier = 1 << 30
+		case "T":
+			multiplier = 1 << 40
+		}
+		if multiplier > 1 {
+			number = number[:len(number)-1]
+		}
+	}
+	n, err := strconv.ParseUint(number, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	return n * multiplier, nil
+}
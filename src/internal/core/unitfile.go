@@ -0,0 +1,143 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/coreos/go-systemd/v22/dbus"
+)
+
+// EnableService creates the [Install] symlinks for a unit so it is started on
+// boot/login. With now set the unit is also started right away.
+func (m *SystemdManager) EnableService(ctx context.Context, name string, now bool) ([]UnitFileChange, error) {
+	name = UnitName(name)
+	_, changes, err := m.conn.EnableUnitFilesContext(ctx, []string{name}, false, false)
+	if err != nil {
+		return nil, fmt.Errorf("failed to enable service %s: %w", name, classify(err))
+	}
+
+	result := convertChanges(changes)
+	if err := m.reloadAfterChange(ctx, name, result); err != nil {
+		return result, err
+	}
+	if now {
+		return result, m.StartService(ctx, name)
+	}
+	return result, nil
+}
+
+// DisableService removes the [Install] symlinks of a unit. With now set the
+// unit is also stopped.
+func (m *SystemdManager) DisableService(ctx context.Context, name string, now bool) ([]UnitFileChange, error) {
+	name = UnitName(name)
+	changes, err := m.conn.DisableUnitFilesContext(ctx, []string{name}, false)
+	if err != nil {
+		return nil, fmt.Errorf("failed to disable service %s: %w", name, classify(err))
+	}
+
+	result := convertChanges(changes)
+	if err := m.reloadAfterChange(ctx, name, result); err != nil {
+		return result, err
+	}
+	if now {
+		return result, m.StopService(ctx, name)
+	}
+	return result, nil
+}
+
+// MaskService links a unit to /dev/null so it cannot be started at all. With
+// now set the unit is also stopped.
+func (m *SystemdManager) MaskService(ctx context.Context, name string, now bool) ([]UnitFileChange, error) {
+	name = UnitName(name)
+	changes, err := m.conn.MaskUnitFilesContext(ctx, []string{name}, false, false)
+	if err != nil {
+		return nil, fmt.Errorf("failed to mask service %s: %w", name, classify(err))
+	}
+
+	result := convertChanges(changes)
+	if err := m.reloadAfterChange(ctx, name, result); err != nil {
+		return result, err
+	}
+	if now {
+		return result, m.StopService(ctx, name)
+	}
+	return result, nil
+}
+
+// UnmaskService removes the /dev/null link created by MaskService.
+func (m *SystemdManager) UnmaskService(ctx context.Context, name string) ([]UnitFileChange, error) {
+	name = UnitName(name)
+	changes, err := m.conn.UnmaskUnitFilesContext(ctx, []string{name}, false)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmask service %s: %w", name, classify(err))
+	}
+
+	result := convertChanges(changes)
+	if err := m.reloadAfterChange(ctx, name, result); err != nil {
+		return result, err
+	}
+	return result, nil
+}
+
+// reloadAfterChange mirrors systemctl, which issues a daemon-reload whenever
+// the unit file links actually changed.
+func (m *SystemdManager) reloadAfterChange(ctx context.Context, name string, changes []UnitFileChange) error {
+	if len(changes) == 0 {
+		return nil
+	}
+	if err := m.conn.ReloadContext(ctx); err != nil {
+		return fmt.Errorf("failed to reload systemd after changing %s: %w", name, classify(err))
+	}
+	return nil
+}
+
+// convertChanges maps the per-method change types of go-systemd, which all
+// share the same layout, onto UnitFileChange.
+func convertChanges[T dbus.EnableUnitFileChange | dbus.DisableUnitFileChange | dbus.MaskUnitFileChange | dbus.UnmaskUnitFileChange](changes []T) []UnitFileChange {
+	result := make([]UnitFileChange, 0, len(changes))
+	for _, c := range changes {
+		result = append(result, UnitFileChange(c))
+	}
+	return result
+}
+
+// GetUnitFile reads the fragment and drop-in files systemd loaded the unit from.
+func (m *SystemdManager) GetUnitFile(ctx context.Context, name string) (*UnitFile, error) {
+	name = UnitName(name)
+	props, err := m.conn.GetUnitPropertiesContext(ctx, name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get properties for %s: %w", name, classify(err))
+	}
+
+	fragment, _ := props["FragmentPath"].(string)
+	dropIns, _ := props["DropInPaths"].([]string)
+	if fragment == "" && len(dropIns) == 0 {
+		return nil, notFound("unit %s has no unit file", name)
+	}
+
+	unit := &UnitFile{Name: name, DropIns: []UnitFileSource{}}
+	if fragment != "" {
+		source, err := readUnitSource(fragment)
+		if err != nil {
+			return nil, err
+		}
+		unit.Fragment = source
+	}
+	for _, path := range dropIns {
+		source, err := readUnitSource(path)
+		if err != nil {
+			return nil, err
+		}
+		unit.DropIns = append(unit.DropIns, source)
+	}
+	return unit, nil
+}
+
+func readUnitSource(path string) (UnitFileSource, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return UnitFileSource{}, fmt.Errorf("failed to read %s: %w", path, classify(err))
+	}
+	return UnitFileSource{Path: path, Content: string(data)}, nil
+}
@@ -0,0 +1,142 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// UnitTypes are the unit types svcm lists, in the order frontends show them
+var UnitTypes = []string{"service", "socket", "timer", "path", "mount", "target"}
+
+// knownSuffixes are all unit types systemd has; a name ending in one of
+// them is taken as is
+var knownSuffixes = []string{
+	".service", ".socket", ".timer", ".path", ".mount", ".target",
+	".automount", ".swap", ".device", ".slice", ".scope",
+}
+
+// UnitName completes a unit name given on the command line. Names without
+// a unit type suffix are taken to be services.
+func UnitName(name string) string {
+	if UnitTypeOf(name) != "" {
+		return name
+	}
+	return name + ".service"
+}
+
+// UnitTypeOf returns the type of a unit name ("service", "socket", ...) or
+// "" if the name has no known suffix
+func UnitTypeOf(name string) string {
+	for _, suffix := range knownSuffixes {
+		if strings.HasSuffix(name, suffix) {
+			return suffix[1:]
+		}
+	}
+	return ""
+}
+
+// ListUnits lists the loaded units of the given types, or of all types in
+// UnitTypes when none are given.
+func (m *SystemdManager) ListUnits(ctx context.Context, types ...string) ([]ServiceUnit, error) {
+	if len(types) == 0 {
+		types = UnitTypes
+	}
+	patterns := make([]string, 0, len(types))
+	for _, t := range types {
+		if !isKnownType(t) {
+			return nil, fmt.Errorf("unknown unit type %q", t)
+		}
+		patterns = append(patterns, "*."+t)
+	}
+
+	units, err := m.conn.ListUnitsByPatternsContext(ctx, nil, patterns)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list units: %w", classify(err))
+	}
+
+	transient := m.transientUnits()
+	list := make([]ServiceUnit, 0, len(units))
+	for _, u := range units {
+		list = append(list, ServiceUnit{
+			Name:        u.Name,
+			Type:        UnitTypeOf(u.Name),
+			Description: u.Description,
+			LoadState:   u.LoadState,
+			ActiveState: u.ActiveState,
+			SubState:    u.SubState,
+			Transient:   transient[u.Name],
+		})
+	}
+	return list, nil
+}
+
+func isKnownType(t string) bool {
+	for _, suffix := range knownSuffixes {
+		if suffix[1:] == t {
+			return true
+		}
+	}
+	return false
+}
+
+// typeDetails builds the part of the details specific to the unit's type
+// from the properties of all its interfaces.
+func typeDetails(d *ServiceDetails, props map[string]interface{}) {
+	getString := func(k string) string {
+		v, _ := props[k].(string)
+		return v
+	}
+	getStrings := func(k string) []string {
+		v, _ := props[k].([]string)
+		return v
+	}
+	getUint32 := func(k string) uint32 {
+		v, _ := props[k].(uint32)
+		return v
+	}
+
+	switch d.Type {
+	case "socket":
+		d.Socket = &SocketDetails{
+			Listen:      pairs(props["Listen"]),
+			Connections: getUint32("NConnections"),
+			Accepted:    getUint32("NAccepted"),
+			Triggers:    getStrings("Triggers"),
+		}
+	case "path":
+		d.Path = &PathDetails{
+			Conditions: pairs(props["Paths"]),
+			Triggers:   getStrings("Triggers"),
+		}
+	case "mount":
+		d.Mount = &MountDetails{
+			What:    getString("What"),
+			Where:   getString("Where"),
+			FSType:  getString("Type"),
+			Options: getString("Options"),
+		}
+	case "target":
+		d.Target = &TargetDetails{
+			Wants:    getStrings("Wants"),
+			Requires: getStrings("Requires"),
+			WantedBy: getStrings("WantedBy"),
+		}
+	}
+}
+
+// pairs decodes the a(ss) properties used for socket listeners and path
+// conditions
+func pairs(v interface{}) []TypedValue {
+	list, _ := v.([][]interface{})
+	result := make([]TypedValue, 0, len(list))
+	for _, entry := range list {
+		if len(entry) < 2 {
+			continue
+		}
+		t, _ := entry[0].(string)
+		value, _ := entry[1].(string)
+		result = append(result, TypedValue{Type: t, Value: value})
+	}
+	return result
+}
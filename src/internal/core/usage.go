@@ -0,0 +1,139 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
+
+// ResourceUsage is the cgroup accounting of a unit. Counters systemd does
+// not track, because accounting is off or the unit has no processes, are
+// zero.
+type ResourceUsage struct {
+	MemoryCurrent  uint64 `json:"memory_current"`
+	CPUUsageNSec   uint64 `json:"cpu_usage_nsec"`
+	TasksCurrent   uint64 `json:"tasks_current"`
+	IOReadBytes    uint64 `json:"io_read_bytes"`
+	IOWriteBytes   uint64 `json:"io_write_bytes"`
+	IPIngressBytes uint64 `json:"ip_ingress_bytes"`
+	IPEgressBytes  uint64 `json:"ip_egress_bytes"`
+}
+
+// cgroupInterfaces maps the unit types that run processes to the D-Bus
+// interface carrying their accounting properties
+var cgroupInterfaces = map[string]string{
+	"service": "Service",
+	"socket":  "Socket",
+	"mount":   "Mount",
+	"swap":    "Swap",
+	"slice":   "Slice",
+	"scope":   "Scope",
+}
+
+// resourceUsage reads the accounting properties. systemd reports counters
+// it does not track as UINT64_MAX.
+func resourceUsage(props map[string]interface{}) ResourceUsage {
+	get := func(k string) uint64 {
+		v, _ := props[k].(uint64)
+		if v == math.MaxUint64 {
+			return 0
+		}
+		return v
+	}
+	return ResourceUsage{
+		MemoryCurrent:  get("MemoryCurrent"),
+		CPUUsageNSec:   get("CPUUsageNSec"),
+		TasksCurrent:   get("TasksCurrent"),
+		IOReadBytes:    get("IOReadBytes"),
+		IOWriteBytes:   get("IOWriteBytes"),
+		IPIngressBytes: get("IPIngressBytes"),
+		IPEgressBytes:  get("IPEgressBytes"),
+	}
+}
+
+// GetUsage samples the accounting of the given units. Units that have no
+// cgroup or have gone away in the meantime are left out.
+func (m *SystemdManager) GetUsage(ctx context.Context, names []string) (map[string]ResourceUsage, error) {
+	usage := make(map[string]ResourceUsage, len(names))
+	var firstErr error
+	for _, name := range names {
+		name = UnitName(name)
+		iface, ok := cgroupInterfaces[UnitTypeOf(name)]
+		if !ok {
+			continue
+		}
+		props, err := m.conn.GetUnitTypePropertiesContext(ctx, name, iface)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = fmt.Errorf("failed to get usage of %s: %w", name, classify(err))
+			}
+			continue
+		}
+		usage[name] = resourceUsage(props)
+	}
+	// A unit stopping between listing and sampling is not an error, losing
+	// the bus is
+	if len(usage) == 0 && firstErr != nil {
+		return nil, firstErr
+	}
+	return usage, nil
+}
+
+// UsageSample is a unit's usage together with the CPU share it used since
+// the previous sample
+type UsageSample struct {
+	ResourceUsage
+	// CPUPercent is relative to one CPU, so busy multi-threaded units can
+	// exceed 100; it is negative until there are two samples
+	CPUPercent float64 `json:"cpu_percent"`
+}
+
+// UsageTracker turns successive samples of cumulative CPU time into CPU
+// percentages
+type UsageTracker struct {
+	last map[string]usagePoint
+}
+
+type usagePoint struct {
+	cpu uint64
+	at  time.Time
+}
+
+func NewUsageTracker() *UsageTracker {
+	return &UsageTracker{last: map[string]usagePoint{}}
+}
+
+// Update records samples taken at the given time and returns them with
+// their CPU share. Units missing from usage are forgotten.
+func (t *UsageTracker) Update(usage map[string]ResourceUsage, at time.Time) map[string]UsageSample {
+	samples := make(map[string]UsageSample, len(usage))
+	next := make(map[string]usagePoint, len(usage))
+	for name, u := range usage {
+		s := UsageSample{ResourceUsage: u, CPUPercent: -1}
+		if prev, ok := t.last[name]; ok && at.After(prev.at) && u.CPUUsageNSec >= prev.cpu {
+			s.CPUPercent = float64(u.CPUUsageNSec-prev.cpu) / float64(at.Sub(prev.at).Nanoseconds()) * 100
+		}
+		samples[name] = s
+		next[name] = usagePoint{cpu: u.CPUUsageNSec, at: at}
+	}
+	t.last = next
+	return samples
+}
+
+// FormatBytes renders a byte count the way systemctl does, e.g. "12.3M"
+func FormatBytes(n uint64) string {
+	const units = "KMGTPE"
+	if n < 1024 {
+		return fmt.Sprintf("%dB", n)
+	}
+	value := float64(n)
+	i := -1
+	for value >= 1024 && i < len(units)-1 {
+		value /= 1024
+		i++
+	}
+	s := fmt.Sprintf("%.1f", value)
+	return strings.TrimSuffix(s, ".0") + units[i:i+1]
+}
@@ -1,12 +1,18 @@
 package gui
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"log"
 	"sort"
+	"strings"
+	"time"
 
 	"fyne.io/fyne/v2"
 	"fyne.io/fyne/v2/app"
 	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
 	"fyne.io/fyne/v2/driver/desktop"
 	"fyne.io/fyne/v2/layout"
 	"fyne.io/fyne/v2/widget"
@@ -14,7 +20,52 @@ import (
 	"svcm/src/internal/core"
 )
 
-func Run(systemMode bool) {
+// refreshDelay is how long events that need the unit list fetched again
+// are gathered before fetching it
+const refreshDelay = time.Second
+
+// serviceRow keeps the widgets of a listed service so state changes can be
+// applied without rebuilding the whole list
+type serviceRow struct {
+	name   string
+	box    fyne.CanvasObject
+	state  *widget.Label
+	cpu    *widget.Label
+	mem    *widget.Label
+	action *widget.Button
+	active string
+	usage  core.UsageSample
+}
+
+// setUsage shows a resource sample; CPU% appears from the second sample on
+func (r *serviceRow) setUsage(u core.UsageSample, ok bool) {
+	if !ok {
+		u = core.UsageSample{CPUPercent: -1}
+	}
+	r.usage = u
+	r.cpu.SetText("")
+	r.mem.SetText("")
+	if u.CPUPercent >= 0 {
+		r.cpu.SetText(fmt.Sprintf("%.1f%%", u.CPUPercent))
+	}
+	if u.MemoryCurrent > 0 {
+		r.mem.SetText(core.FormatBytes(u.MemoryCurrent))
+	}
+}
+
+func (r *serviceRow) setActive(state string) {
+	r.active = state
+	r.state.SetText(state)
+	if state == "active" {
+		r.action.SetText("Stop")
+	} else {
+		r.action.SetText("Start")
+	}
+}
+
+// Run shows the tray and window for the manager backend opens. The tray
+// menu toggles the groups of config.
+func Run(backend core.Backend, systemMode bool, config *core.Config) {
 	a := app.NewWithID("com.arya.lsysctl")
 	w := a.NewWindow("lsysctl - Service Manager")
 
@@ -27,7 +78,7 @@ func Run(systemMode bool) {
 	}
 
 	// Service Manager Connection
-	manager, err := core.NewSystemdManager(systemMode)
+	manager, err := backend(systemMode)
 	if err != nil {
 		w.SetContent(widget.NewLabel("Failed to connect to systemd: " + err.Error()))
 		w.ShowAndRun()
@@ -35,17 +86,80 @@ func Run(systemMode bool) {
 	}
 	defer manager.Close()
 
-	// UI Components
-	listContainer := container.NewVBox()
-	scroll := container.NewVScroll(listContainer)
+	// stop ends the background refreshes once the app quits
+	stop := make(chan struct{})
+	defer close(stop)
+
+	// UI Components: one tab per unit type, only the visible one is kept
+	// up to date
+	currentType := "service"
+	lists := map[string]*fyne.Container{}
+	tabs := container.NewAppTabs()
+	for _, t := range core.UnitTypes {
+		list := container.NewVBox()
+		lists[t] = list
+		tabs.Append(container.NewTabItem(strings.ToUpper(t[:1])+t[1:]+"s", container.NewVScroll(list)))
+	}
 
 	statusLabel := widget.NewLabel("Ready")
 
+	// runAction waits for action off the UI goroutine behind a dialog whose
+	// Cancel button stops the wait; a job already queued still runs
+	runAction := func(verb, done, name string, action func(context.Context) error) {
+		ctx, cancel := context.WithCancel(context.Background())
+		progress := dialog.NewCustom(strings.ToUpper(verb[:1])+verb[1:]+" "+name, "Cancel", widget.NewProgressBarInfinite(), w)
+		progress.SetOnClosed(cancel)
+		progress.Show()
+		go func() {
+			err := action(ctx)
+			fyne.Do(func() {
+				progress.Hide()
+				switch {
+				case errors.Is(err, core.ErrCanceled):
+					statusLabel.SetText("Stopped waiting to " + verb + " " + name)
+				case err != nil:
+					statusLabel.SetText("Failed to " + verb + " " + name + ": " + err.Error())
+				default:
+					statusLabel.SetText(done + " " + name)
+				}
+			})
+		}()
+	}
+
+	rows := map[string]*serviceRow{}
+	var order []*serviceRow
+	usage := map[string]core.UsageSample{}
+	sortBy := "Name"
+
+	// sortRows reorders the existing row widgets, busiest first when
+	// sorting by CPU or memory
+	sortRows := func() {
+		sort.SliceStable(order, func(i, j int) bool {
+			ui, uj := order[i].usage, order[j].usage
+			switch {
+			case sortBy == "CPU" && ui.CPUPercent != uj.CPUPercent:
+				return ui.CPUPercent > uj.CPUPercent
+			case sortBy == "Memory" && ui.MemoryCurrent != uj.MemoryCurrent:
+				return ui.MemoryCurrent > uj.MemoryCurrent
+			}
+			return order[i].name < order[j].name
+		})
+		listContainer := lists[currentType]
+		listContainer.Objects = nil
+		for _, r := range order {
+			listContainer.Add(r.box)
+		}
+		listContainer.Refresh()
+	}
+
 	refreshServices := func() {
+		listContainer := lists[currentType]
 		listContainer.Objects = nil
-		services, err := manager.ListServices()
+		rows = map[string]*serviceRow{}
+		order = nil
+		services, err := manager.ListUnits(context.Background(), currentType)
 		if err != nil {
-			statusLabel.SetText("Error listing services: " + err.Error())
+			statusLabel.SetText("Error listing units: " + err.Error())
 			return
 		}
 
@@ -68,60 +182,282 @@ func Run(systemMode bool) {
 				descLabel.SetText(s.Description[:47] + "...")
 			}
 
-			var actionBtn *widget.Button
-			if svcActive == "active" {
-				actionBtn = widget.NewButton("Stop", func() {
-					if err := manager.StopService(svcName); err != nil {
-						statusLabel.SetText("Failed to stop " + svcName + ": " + err.Error())
-					} else {
-						statusLabel.SetText("Stopped " + svcName)
-						// Simple refresh after action
-						// In a real app we might watch signals
-					}
-				})
-			} else {
-				actionBtn = widget.NewButton("Start", func() {
-					if err := manager.StartService(svcName); err != nil {
-						statusLabel.SetText("Failed to start " + svcName + ": " + err.Error())
-					} else {
-						statusLabel.SetText("Started " + svcName)
-					}
-				})
+			row := &serviceRow{
+				name:  svcName,
+				state: stateLabel,
+				cpu:   widget.NewLabel(""),
+				mem:   widget.NewLabel(""),
 			}
+			row.action = widget.NewButton("", func() {
+				if row.active == "active" {
+					runAction("stop", "Stopped", svcName, func(ctx context.Context) error {
+						return manager.StopService(ctx, svcName)
+					})
+				} else {
+					runAction("start", "Started", svcName, func(ctx context.Context) error {
+						return manager.StartService(ctx, svcName)
+					})
+				}
+			})
+			row.setActive(svcActive)
+			u, ok := usage[svcName]
+			row.setUsage(u, ok)
+			rows[svcName] = row
+			order = append(order, row)
+
+			// Unit file actions live in a popup menu to keep rows compact
+			unitFileAction := func(verb, done string, action func(context.Context, string) ([]core.UnitFileChange, error)) func() {
+				return func() {
+					runAction(verb, done, svcName, func(ctx context.Context) error {
+						_, err := action(ctx, svcName)
+						return err
+					})
+				}
+			}
+			moreMenu := fyne.NewMenu("",
+				fyne.NewMenuItem("Enable", unitFileAction("enable", "Enabled", func(ctx context.Context, n string) ([]core.UnitFileChange, error) {
+					return manager.EnableService(ctx, n, false)
+				})),
+				fyne.NewMenuItem("Disable", unitFileAction("disable", "Disabled", func(ctx context.Context, n string) ([]core.UnitFileChange, error) {
+					return manager.DisableService(ctx, n, false)
+				})),
+				fyne.NewMenuItem("Mask", unitFileAction("mask", "Masked", func(ctx context.Context, n string) ([]core.UnitFileChange, error) {
+					return manager.MaskService(ctx, n, false)
+				})),
+				fyne.NewMenuItem("Unmask", unitFileAction("unmask", "Unmasked", manager.UnmaskService)),
+				fyne.NewMenuItemSeparator(),
+				fyne.NewMenuItem("Logs", func() {
+					showLogs(a, manager, svcName)
+				}),
+			)
+			var moreBtn *widget.Button
+			moreBtn = widget.NewButton("More", func() {
+				pos := fyne.CurrentApp().Driver().AbsolutePositionForObject(moreBtn)
+				widget.ShowPopUpMenuAtPosition(moreMenu, w.Canvas(), pos.AddXY(0, moreBtn.Size().Height))
+			})
 
 			// Row layout
-			row := container.New(layout.NewGridLayout(4), nameLabel, stateLabel, descLabel, actionBtn)
-			listContainer.Add(row)
+			row.box = container.New(layout.NewGridLayout(7), nameLabel, stateLabel, row.cpu, row.mem, descLabel, row.action, moreBtn)
+		}
+		sortRows()
+	}
+
+	// Rebuild once for a burst of events, as during a daemon-reload
+	refreshQueued := false
+	queueRefresh := func() {
+		if refreshQueued {
+			return
+		}
+		refreshQueued = true
+		time.AfterFunc(refreshDelay, func() {
+			select {
+			case <-stop:
+			default:
+				fyne.Do(func() {
+					refreshQueued = false
+					refreshServices()
+				})
+			}
+		})
+	}
+
+	// Patch rows from systemd signals, rebuilding only when units come and go
+	applyEvent := func(ev core.UnitEvent) {
+		if ev.Unit.Type != currentType {
+			return
+		}
+		row, known := rows[ev.Unit.Name]
+		switch ev.Kind {
+		case core.UnitAdded:
+			if !known {
+				queueRefresh()
+			}
+		case core.UnitRemoved:
+			if known {
+				queueRefresh()
+			}
+		case core.UnitChanged:
+			if !known {
+				queueRefresh()
+			} else if ev.Unit.ActiveState != "" {
+				row.setActive(ev.Unit.ActiveState)
+			}
 		}
-		listContainer.Refresh()
 	}
 
 	// Initial load
 	refreshServices()
+	tabs.OnSelected = func(*container.TabItem) {
+		currentType = core.UnitTypes[tabs.SelectedIndex()]
+		refreshServices()
+	}
+
+	poll := func() {
+		ticker := time.NewTicker(2 * time.Second)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				fyne.Do(refreshServices)
+			}
+		}
+	}
+	if sub, err := manager.Subscribe(context.Background()); err != nil {
+		go poll()
+	} else {
+		defer sub.Close()
+		go func() {
+			for ev := range sub.Events {
+				fyne.Do(func() { applyEvent(ev) })
+			}
+			// Subscription lost, fall back to polling until the app quits
+			poll()
+		}()
+	}
+
+	// CPU% needs two samples of the cumulative CPU time, so it shows up
+	// after the first interval
+	sampleUsage := func() {
+		tracker := core.NewUsageTracker()
+		ticker := time.NewTicker(2 * time.Second)
+		defer ticker.Stop()
+		for {
+			var active []string
+			fyne.DoAndWait(func() {
+				for _, r := range order {
+					if r.active == "active" {
+						active = append(active, r.name)
+					}
+				}
+			})
+			if current, err := manager.GetUsage(context.Background(), active); err == nil {
+				samples := tracker.Update(current, time.Now())
+				fyne.Do(func() {
+					usage = samples
+					for name, r := range rows {
+						u, ok := usage[name]
+						r.setUsage(u, ok)
+					}
+					if sortBy != "Name" {
+						sortRows()
+					}
+				})
+			}
+
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+			}
+		}
+	}
+	go sampleUsage()
 
 	refreshBtn := widget.NewButton("Refresh", refreshServices)
+	sortSelect := widget.NewSelect([]string{"Name", "CPU", "Memory"}, func(choice string) {
+		sortBy = choice
+		sortRows()
+	})
+	sortSelect.SetSelected(sortBy)
 
 	// Main Layout
 	content := container.NewBorder(
 		nil,
-		container.NewVBox(statusLabel, refreshBtn),
+		container.NewVBox(statusLabel, container.NewBorder(nil, nil, widget.NewLabel("Sort by"), nil, sortSelect), refreshBtn),
 		nil, nil,
-		scroll,
+		tabs,
 	)
 	w.SetContent(content)
 	w.Resize(fyne.NewSize(800, 600))
 
 	// Setup Tray
 	if desk != nil {
-		menu := fyne.NewMenu("lsysctl",
+		items := []*fyne.MenuItem{
 			fyne.NewMenuItem("Show", func() {
 				w.Show()
 			}),
-			fyne.NewMenuItem("Quit", func() {
-				a.Quit()
-			}),
-		)
+		}
+		var menu *fyne.Menu
+		var updateChecks func()
+		if len(config.Groups) > 0 {
+			groups := fyne.NewMenuItem("Groups", nil)
+			groups.ChildMenu = fyne.NewMenu("")
+			groupItems := map[string]*fyne.MenuItem{}
+
+			// A group is checked while all of its units that exist are
+			// active, so a missing member does not keep it unchecked
+			updateChecks = func() {
+				checked := map[string]bool{}
+				for _, name := range config.GroupNames() {
+					units, err := core.GroupStatus(context.Background(), manager, config.Groups[name])
+					found := 0
+					checked[name] = err == nil
+					for _, u := range units {
+						if u.LoadState == "not-found" {
+							continue
+						}
+						found++
+						checked[name] = checked[name] && u.ActiveState == "active"
+					}
+					checked[name] = checked[name] && found > 0
+				}
+				fyne.Do(func() {
+					for name, item := range groupItems {
+						item.Checked = checked[name]
+					}
+					menu.Refresh()
+				})
+			}
+
+			for _, name := range config.GroupNames() {
+				item := fyne.NewMenuItem(name, nil)
+				item.Action = func() {
+					g := config.Groups[name]
+					verb, done, run := "start group", "Started group", core.StartGroup
+					if item.Checked {
+						verb, done, run = "stop group", "Stopped group", core.StopGroup
+					}
+					runAction(verb, done, name, func(ctx context.Context) error {
+						defer updateChecks()
+						units, err := g.Resolve(ctx, manager)
+						if err != nil {
+							return err
+						}
+						for _, r := range run(ctx, manager, units, nil) {
+							if r.Err != nil {
+								return r.Err
+							}
+						}
+						return nil
+					})
+				}
+				groupItems[name] = item
+				groups.ChildMenu.Items = append(groups.ChildMenu.Items, item)
+			}
+			items = append(items, groups)
+		}
+		items = append(items, fyne.NewMenuItem("Quit", func() {
+			a.Quit()
+		}))
+		menu = fyne.NewMenu("lsysctl", items...)
 		desk.SetSystemTrayMenu(menu)
+
+		if updateChecks != nil {
+			go func() {
+				ticker := time.NewTicker(5 * time.Second)
+				defer ticker.Stop()
+				for {
+					updateChecks()
+					select {
+					case <-stop:
+						return
+					case <-ticker.C:
+					}
+				}
+			}()
+		}
 	}
 
 	w.SetCloseIntercept(func() {
@@ -130,3 +466,24 @@ func Run(systemMode bool) {
 
 	w.ShowAndRun()
 }
+
+func showLogs(a fyne.App, manager core.Manager, name string) {
+	w := a.NewWindow("Logs: " + name)
+
+	entries, err := manager.GetLogs(context.Background(), core.LogQuery{Unit: name, Lines: 200})
+	if err != nil {
+		w.SetContent(widget.NewLabel("Error fetching logs: " + err.Error()))
+	} else {
+		lines := make([]string, 0, len(entries))
+		for _, e := range entries {
+			lines = append(lines, core.FormatLogEntry(e))
+		}
+		grid := widget.NewTextGridFromString(strings.Join(lines, "\n"))
+		scroll := container.NewScroll(grid)
+		w.SetContent(scroll)
+		scroll.ScrollToBottom()
+	}
+
+	w.Resize(fyne.NewSize(900, 500))
+	w.Show()
+}
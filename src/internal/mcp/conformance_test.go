@@ -0,0 +1,459 @@
+package mcp
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"svcm/src/internal/core"
+)
+
+const fixturePath = "../../../examples/fake-units.yaml"
+
+// message is any line the server writes: a response or a notification
+type message struct {
+	ID     json.RawMessage `json:"id"`
+	Method string          `json:"method"`
+	Params json.RawMessage `json:"params"`
+	Result json.RawMessage `json:"result"`
+	Error  *JSONRPCError   `json:"error"`
+}
+
+// client drives ServeStdio over a pair of pipes
+type client struct {
+	t        *testing.T
+	in       *io.PipeWriter
+	messages chan message
+	done     chan error
+}
+
+func fakeBackend(t *testing.T) core.Backend {
+	t.Helper()
+	fixture, err := core.LoadFakeFixture(fixturePath)
+	if err != nil {
+		t.Fatalf("failed to load fixture: %v", err)
+	}
+	return core.FakeBackend(fixture)
+}
+
+func startServer(t *testing.T, cfg Config) *client {
+	t.Helper()
+	if cfg.Backend == nil {
+		cfg.Backend = fakeBackend(t)
+	}
+	inR, inW := io.Pipe()
+	outR, outW := io.Pipe()
+	c := &client{t: t, in: inW, messages: make(chan message, 100), done: make(chan error, 1)}
+
+	go func() {
+		err := NewServer(cfg).ServeStdio(context.Background(), inR, outW)
+		outW.Close()
+		c.done <- err
+	}()
+	go func() {
+		defer close(c.messages)
+		scanner := bufio.NewScanner(outR)
+		for scanner.Scan() {
+			var msg message
+			if err := json.Unmarshal(scanner.Bytes(), &msg); err != nil {
+				t.Errorf("server wrote invalid JSON %q: %v", scanner.Text(), err)
+				continue
+			}
+			c.messages <- msg
+		}
+	}()
+	t.Cleanup(func() { c.close() })
+	return c
+}
+
+func (c *client) send(line string) {
+	c.t.Helper()
+	if _, err := fmt.Fprintln(c.in, line); err != nil {
+		c.t.Fatalf("failed to send %s: %v", line, err)
+	}
+}
+
+func (c *client) request(id int, method string, params interface{}) {
+	c.t.Helper()
+	data, err := json.Marshal(map[string]interface{}{"jsonrpc": "2.0", "id": id, "method": method, "params": params})
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	c.send(string(data))
+}
+
+func (c *client) next() message {
+	c.t.Helper()
+	select {
+	case msg, ok := <-c.messages:
+		if !ok {
+			c.t.Fatal("server closed its output")
+		}
+		return msg
+	case <-time.After(5 * time.Second):
+		c.t.Fatal("timed out waiting for the server")
+	}
+	return message{}
+}
+
+// call sends a request and returns its response
+func (c *client) call(id int, method string, params interface{}) message {
+	c.t.Helper()
+	c.request(id, method, params)
+	msg := c.next()
+	if string(msg.ID) != fmt.Sprint(id) {
+		c.t.Fatalf("got response to %s, want %d", msg.ID, id)
+	}
+	return msg
+}
+
+func (c *client) initialize() {
+	c.t.Helper()
+	c.initializeWith(map[string]interface{}{})
+}
+
+// initializeWith initializes the session declaring the given client
+// capabilities
+func (c *client) initializeWith(capabilities map[string]interface{}) {
+	c.t.Helper()
+	msg := c.call(0, "initialize", map[string]interface{}{"protocolVersion": supportedProtocolVersions[0], "capabilities": capabilities})
+	if msg.Error != nil {
+		c.t.Fatalf("initialize failed: %v", msg.Error)
+	}
+	c.send(`{"jsonrpc":"2.0","method":"notifications/initialized"}`)
+}
+
+// close ends the input and returns what the server wrote after that
+func (c *client) close() []message {
+	c.in.Close()
+	var rest []message
+	for msg := range c.messages {
+		rest = append(rest, msg)
+	}
+	if err := <-c.done; err != nil {
+		c.t.Errorf("ServeStdio failed: %v", err)
+	}
+	c.done <- nil
+	return rest
+}
+
+func expectError(t *testing.T, msg message, code int) {
+	t.Helper()
+	if msg.Error == nil {
+		t.Fatalf("got result %s, want error %d", msg.Result, code)
+	}
+	if msg.Error.Code != code {
+		t.Fatalf("got error %d (%s), want %d", msg.Error.Code, msg.Error.Message, code)
+	}
+}
+
+func toolResult(t *testing.T, msg message) ToolResult {
+	t.Helper()
+	if msg.Error != nil {
+		t.Fatalf("tools/call failed: %d %s", msg.Error.Code, msg.Error.Message)
+	}
+	var result ToolResult
+	if err := json.Unmarshal(msg.Result, &result); err != nil {
+		t.Fatalf("invalid tool result %s: %v", msg.Result, err)
+	}
+	return result
+}
+
+func TestInitializeNegotiatesVersion(t *testing.T) {
+	tests := []struct {
+		requested, want string
+	}{
+		{"2025-06-18", "2025-06-18"},
+		{"2025-03-26", "2025-03-26"},
+		{"2024-11-05", "2024-11-05"},
+		{"1999-01-01", supportedProtocolVersions[0]},
+	}
+	for _, tt := range tests {
+		c := startServer(t, Config{})
+		msg := c.call(1, "initialize", map[string]interface{}{"protocolVersion": tt.requested})
+		var result struct {
+			ProtocolVersion string                 `json:"protocolVersion"`
+			ServerInfo      map[string]string      `json:"serverInfo"`
+			Capabilities    map[string]interface{} `json:"capabilities"`
+		}
+		if err := json.Unmarshal(msg.Result, &result); err != nil {
+			t.Fatal(err)
+		}
+		if result.ProtocolVersion != tt.want {
+			t.Errorf("requested %s: got %s, want %s", tt.requested, result.ProtocolVersion, tt.want)
+		}
+		if result.ServerInfo["name"] != serverName {
+			t.Errorf("got server name %q", result.ServerInfo["name"])
+		}
+		for _, capability := range []string{"tools", "resources", "prompts"} {
+			if _, ok := result.Capabilities[capability]; !ok {
+				t.Errorf("capability %s missing", capability)
+			}
+		}
+	}
+
+	c := startServer(t, Config{})
+	expectError(t, c.call(1, "initialize", map[string]interface{}{}), codeInvalidParams)
+}
+
+func TestPing(t *testing.T) {
+	c := startServer(t, Config{})
+	c.initialize()
+	msg := c.call(1, "ping", nil)
+	if msg.Error != nil || string(msg.Result) != "{}" {
+		t.Fatalf("got %s %v, want an empty result", msg.Result, msg.Error)
+	}
+}
+
+func TestProtocolErrors(t *testing.T) {
+	c := startServer(t, Config{})
+	c.initialize()
+
+	c.send(`{"jsonrpc":"2.0","id":1,"method":`)
+	msg := c.next()
+	expectError(t, msg, codeParseError)
+	if string(msg.ID) != "null" {
+		t.Errorf("parse error has id %s, want null", msg.ID)
+	}
+
+	c.send(`{"jsonrpc":"1.0","id":2,"method":"ping"}`)
+	msg = c.next()
+	expectError(t, msg, codeInvalidRequest)
+	if string(msg.ID) != "2" {
+		t.Errorf("invalid request has id %s, want 2", msg.ID)
+	}
+
+	c.send(`{"jsonrpc":"2.0","id":3}`)
+	expectError(t, c.next(), codeInvalidRequest)
+
+	c.send(`[{"jsonrpc":"2.0","id":4,"method":"ping"}]`)
+	expectError(t, c.next(), codeInvalidRequest)
+
+	expectError(t, c.call(5, "no/such/method", nil), codeMethodNotFound)
+
+	// Notifications, known or not, are never answered
+	c.send(`{"jsonrpc":"2.0","method":"notifications/unknown"}`)
+	if msg := c.call(6, "ping", nil); msg.Error != nil {
+		t.Fatalf("ping failed: %v", msg.Error)
+	}
+}
+
+func TestToolCallErrors(t *testing.T) {
+	c := startServer(t, Config{})
+	c.initialize()
+
+	expectError(t, c.call(1, "tools/call", map[string]interface{}{}), codeInvalidParams)
+	expectError(t, c.call(2, "tools/call", map[string]interface{}{"name": "no_such_tool"}), codeInvalidParams)
+	expectError(t, c.call(3, "tools/call", map[string]interface{}{"name": "start_service", "arguments": map[string]interface{}{}}), codeInvalidParams)
+
+	// Failures of systemd are tool results, so the model sees them
+	tests := []struct {
+		unit, class string
+		code        int
+	}{
+		{"broken", "job_failed", codeJobFailed},
+		{"reports", "dependency_failed", codeDependencyFailed},
+		{"missing", "not_found", codeResourceNotFound},
+	}
+	for i, tt := range tests {
+		msg := c.call(10+i, "tools/call", map[string]interface{}{"name": "start_service", "arguments": map[string]interface{}{"name": tt.unit}})
+		result := toolResult(t, msg)
+		if !result.IsError {
+			t.Errorf("starting %s: got success, want isError", tt.unit)
+			continue
+		}
+		structured, _ := result.StructuredContent.(map[string]interface{})
+		if structured["error"] != tt.class || structured["code"] != float64(tt.code) {
+			t.Errorf("starting %s: got %v, want error %s code %d", tt.unit, structured, tt.class, tt.code)
+		}
+	}
+}
+
+func TestToolCallProgress(t *testing.T) {
+	c := startServer(t, Config{})
+	c.initialize()
+
+	c.request(1, "tools/call", map[string]interface{}{
+		"name":      "restart_service",
+		"arguments": map[string]interface{}{"name": "web"},
+		"_meta":     map[string]interface{}{"progressToken": "tok"},
+	})
+	var progress []float64
+	for {
+		msg := c.next()
+		if msg.Method == "notifications/progress" {
+			var params struct {
+				ProgressToken string  `json:"progressToken"`
+				Progress      float64 `json:"progress"`
+				Total         float64 `json:"total"`
+			}
+			if err := json.Unmarshal(msg.Params, &params); err != nil {
+				t.Fatal(err)
+			}
+			if params.ProgressToken != "tok" || params.Total != 1 {
+				t.Errorf("got progress %s", msg.Params)
+			}
+			progress = append(progress, params.Progress)
+			continue
+		}
+		if result := toolResult(t, msg); result.IsError {
+			t.Fatalf("restart failed: %v", result.Content)
+		}
+		break
+	}
+	if len(progress) != 2 || progress[0] != 0 || progress[1] != 1 {
+		t.Errorf("got progress %v before the response, want [0 1]", progress)
+	}
+}
+
+// blockingManager holds StartService until released or cancelled
+type blockingManager struct {
+	core.Manager
+	started chan string
+	release chan struct{}
+}
+
+func (m *blockingManager) StartService(ctx context.Context, name string) error {
+	m.started <- name
+	select {
+	case <-m.release:
+		return m.Manager.StartService(ctx, name)
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func blockingBackend(t *testing.T) (core.Backend, *blockingManager) {
+	backend := fakeBackend(t)
+	blocking := &blockingManager{started: make(chan string, 1), release: make(chan struct{})}
+	return func(systemMode bool) (core.Manager, error) {
+		m, err := backend(systemMode)
+		if err != nil {
+			return nil, err
+		}
+		blocking.Manager = m
+		return blocking, nil
+	}, blocking
+}
+
+func waitStarted(t *testing.T, m *blockingManager) {
+	t.Helper()
+	select {
+	case <-m.started:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the job never started")
+	}
+}
+
+func TestConcurrentRequestsAnswerOutOfOrder(t *testing.T) {
+	backend, blocking := blockingBackend(t)
+	c := startServer(t, Config{Backend: backend})
+	c.initialize()
+
+	c.request(1, "tools/call", map[string]interface{}{"name": "start_service", "arguments": map[string]interface{}{"name": "reports"}})
+	waitStarted(t, blocking)
+
+	// The ping overtakes the blocked start
+	if msg := c.call(2, "ping", nil); msg.Error != nil {
+		t.Fatalf("ping failed: %v", msg.Error)
+	}
+
+	close(blocking.release)
+	msg := c.next()
+	if string(msg.ID) != "1" {
+		t.Fatalf("got response to %s, want 1", msg.ID)
+	}
+	toolResult(t, msg)
+}
+
+func TestCancelledRequestGetsNoResponse(t *testing.T) {
+	backend, blocking := blockingBackend(t)
+	c := startServer(t, Config{Backend: backend})
+	c.initialize()
+
+	c.request(1, "tools/call", map[string]interface{}{"name": "start_service", "arguments": map[string]interface{}{"name": "web"}})
+	waitStarted(t, blocking)
+	c.send(`{"jsonrpc":"2.0","method":"notifications/cancelled","params":{"requestId":1,"reason":"test"}}`)
+
+	if msg := c.call(2, "ping", nil); msg.Error != nil {
+		t.Fatalf("ping failed: %v", msg.Error)
+	}
+	for _, msg := range c.close() {
+		if string(msg.ID) == "1" {
+			t.Fatalf("cancelled request was answered: %s %v", msg.Result, msg.Error)
+		}
+	}
+}
+
+func TestPolicyGuardsJobImpliedByNow(t *testing.T) {
+	c := startServer(t, Config{Policy: &Policy{Confirm: []string{"stop"}}})
+	c.initialize()
+
+	disable := func(id int, args map[string]interface{}) ToolResult {
+		return toolResult(t, c.call(id, "tools/call", map[string]interface{}{"name": "disable_service", "arguments": args}))
+	}
+
+	result := disable(1, map[string]interface{}{"name": "web", "now": true})
+	if !result.IsError || !strings.Contains(result.Content[0].Text, "stop requires confirmation") {
+		t.Fatalf("disable with now=true was not held for confirmation: %v", result.Content)
+	}
+	if result := disable(2, map[string]interface{}{"name": "web"}); result.IsError {
+		t.Fatalf("disable without now failed: %v", result.Content)
+	}
+}
+
+// elicit waits for the server to ask for confirmation and answers with
+// action, returning the message shown to the user
+func (c *client) elicit(action string) string {
+	c.t.Helper()
+	msg := c.next()
+	if msg.Method != "elicitation/create" || len(msg.ID) == 0 {
+		c.t.Fatalf("got %s %s, want an elicitation request", msg.Method, msg.Result)
+	}
+	var params struct {
+		Message string `json:"message"`
+	}
+	json.Unmarshal(msg.Params, &params)
+	c.send(fmt.Sprintf(`{"jsonrpc":"2.0","id":%s,"result":{"action":%q}}`, msg.ID, action))
+	return params.Message
+}
+
+func TestPolicyConfirmationAsksTheUser(t *testing.T) {
+	c := startServer(t, Config{Policy: &Policy{Confirm: []string{"stop"}}})
+	c.initializeWith(map[string]interface{}{"elicitation": map[string]interface{}{}})
+
+	stop := func(id int, args map[string]interface{}) {
+		c.request(id, "tools/call", map[string]interface{}{"name": "stop_service", "arguments": args})
+	}
+
+	// A confirm argument from the model does not stand in for the user
+	stop(1, map[string]interface{}{"name": "web", "confirm": true})
+	if question := c.elicit("decline"); !strings.Contains(question, "stop web.service") {
+		t.Errorf("asked %q, want the action and unit named", question)
+	}
+	if result := toolResult(t, c.next()); !result.IsError || !strings.Contains(result.Content[0].Text, "did not confirm") {
+		t.Fatalf("declined stop was not denied: %v", result.Content)
+	}
+
+	stop(2, map[string]interface{}{"name": "web"})
+	c.elicit("accept")
+	if result := toolResult(t, c.next()); result.IsError {
+		t.Fatalf("accepted stop failed: %v", result.Content)
+	}
+
+	// A question left open when the client goes away denies the call
+	stop(3, map[string]interface{}{"name": "worker"})
+	if msg := c.next(); msg.Method != "elicitation/create" {
+		t.Fatalf("got %s, want an elicitation request", msg.Method)
+	}
+	for _, msg := range c.close() {
+		if string(msg.ID) == "3" && !toolResult(t, msg).IsError {
+			t.Error("stop went ahead without an answer")
+		}
+	}
+}
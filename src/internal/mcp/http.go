@@ -0,0 +1,554 @@
+package mcp
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Streamable HTTP transport. Everything goes to a single endpoint:
+//
+//	POST   one JSON-RPC message; a request is answered with JSON, or with an
+//	       event stream carrying its progress when the client accepts one
+//	GET    event stream of notifications that answer no request
+//	DELETE ends the session
+//
+// initialize creates a session whose id the client sends back in the
+// Mcp-Session-Id header. Sessions end on DELETE or after going unused for
+// sessionIdleTimeout.
+const (
+	mcpEndpoint    = "/mcp"
+	sessionHeader  = "Mcp-Session-Id"
+	protocolHeader = "Mcp-Protocol-Version"
+
+	// keepAliveInterval spaces comments on idle event streams so proxies
+	// do not time them out
+	keepAliveInterval = 30 * time.Second
+	// eventBacklog is how many notifications a session holds while no
+	// GET stream is open
+	eventBacklog = 64
+	// sessionIdleTimeout ends sessions no request has used for this long,
+	// for clients that go away without a DELETE
+	sessionIdleTimeout = 30 * time.Minute
+	// maxSessions bounds the sessions open at once
+	maxSessions = 64
+)
+
+// errTooManySessions is returned by newSession once maxSessions are open
+var errTooManySessions = errors.New("too many MCP sessions")
+
+type httpTransport struct {
+	server      *Server
+	token       string
+	idleTimeout time.Duration
+	maxSessions int
+	// hosts are the names the Host header may carry; nil allows any, as
+	// for a unix socket no browser can reach
+	hosts map[string]bool
+
+	mu       sync.Mutex
+	sessions map[string]*httpSession
+}
+
+type httpSession struct {
+	*session
+	events chan interface{}
+	done   chan struct{}
+
+	streamMu  sync.Mutex
+	streaming bool
+
+	// requests counts the requests using the session and lastUsed is when
+	// the last one ended; both are guarded by the transport's mu
+	requests int
+	lastUsed time.Time
+}
+
+// ListenAndServe serves MCP over HTTP on host:port or unix:/path until ctx
+// is cancelled. A non-empty token is required as a bearer token.
+func (s *Server) ListenAndServe(ctx context.Context, addr, token string) error {
+	ln, err := listen(addr)
+	if err != nil {
+		return err
+	}
+	if token == "" && !strings.HasPrefix(addr, "unix:") && !isLoopback(ln.Addr()) {
+		log.Printf("warning: MCP server on %s accepts requests without a token", ln.Addr())
+	}
+
+	t := newHTTPTransport(s, token)
+	if strings.HasPrefix(addr, "unix:") {
+		t.hosts = nil
+	} else {
+		t.addListenHosts(ln.Addr())
+	}
+	srv := &http.Server{Handler: t, ReadHeaderTimeout: 10 * time.Second}
+
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(t.idleTimeout / 4)
+		defer ticker.Stop()
+		for done := false; !done; {
+			select {
+			case now := <-ticker.C:
+				t.evictIdle(now)
+			case <-ctx.Done():
+				done = true
+			}
+		}
+		// Event streams only end with their session, so close those first
+		t.closeAll()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := srv.Shutdown(shutdownCtx); err != nil {
+			srv.Close()
+		}
+	}()
+
+	log.Printf("MCP server listening on %s%s", ln.Addr(), mcpEndpoint)
+	err = srv.Serve(ln)
+	if errors.Is(err, http.ErrServerClosed) {
+		<-stopped
+		err = nil
+	}
+	s.wg.Wait()
+	s.closeManagers()
+	return err
+}
+
+func newHTTPTransport(s *Server, token string) *httpTransport {
+	return &httpTransport{
+		server:      s,
+		token:       token,
+		idleTimeout: sessionIdleTimeout,
+		maxSessions: maxSessions,
+		hosts:       map[string]bool{"localhost": true, "127.0.0.1": true, "::1": true},
+		sessions:    make(map[string]*httpSession),
+	}
+}
+
+// addListenHosts allows the Host header to name the address the server
+// listens on, or any address of this machine when that is a wildcard
+func (t *httpTransport) addListenHosts(addr net.Addr) {
+	tcp, ok := addr.(*net.TCPAddr)
+	if !ok {
+		return
+	}
+	if !tcp.IP.IsUnspecified() {
+		t.hosts[tcp.IP.String()] = true
+		return
+	}
+	addrs, err := net.InterfaceAddrs()
+	if err != nil {
+		return
+	}
+	for _, a := range addrs {
+		if ipnet, ok := a.(*net.IPNet); ok {
+			t.hosts[ipnet.IP.String()] = true
+		}
+	}
+}
+
+func listen(addr string) (net.Listener, error) {
+	path, ok := strings.CutPrefix(addr, "unix:")
+	if !ok {
+		ln, err := net.Listen("tcp", addr)
+		if err != nil {
+			return nil, fmt.Errorf("failed to listen on %s: %w", addr, err)
+		}
+		return ln, nil
+	}
+
+	// Replace a socket left behind by an earlier run
+	if fi, err := os.Stat(path); err == nil && fi.Mode()&os.ModeSocket != 0 {
+		os.Remove(path)
+	}
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on %s: %w", path, err)
+	}
+	if err := os.Chmod(path, 0o600); err != nil {
+		ln.Close()
+		return nil, fmt.Errorf("failed to restrict %s: %w", path, err)
+	}
+	return ln, nil
+}
+
+func isLoopback(addr net.Addr) bool {
+	tcp, ok := addr.(*net.TCPAddr)
+	return ok && tcp.IP.IsLoopback()
+}
+
+func (t *httpTransport) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != mcpEndpoint {
+		http.NotFound(w, r)
+		return
+	}
+	if !t.allowedHost(r) {
+		http.Error(w, "Forbidden host", http.StatusForbidden)
+		return
+	}
+	if !allowedOrigin(r) {
+		http.Error(w, "Forbidden origin", http.StatusForbidden)
+		return
+	}
+	if !t.authorized(r) {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="svcm"`)
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if v := r.Header.Get(protocolHeader); v != "" && negotiateVersion(v) != v {
+		http.Error(w, "Unsupported protocol version: "+v, http.StatusBadRequest)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPost:
+		t.handlePost(w, r)
+	case http.MethodGet:
+		t.handleGet(w, r)
+	case http.MethodDelete:
+		t.handleDelete(w, r)
+	default:
+		w.Header().Set("Allow", "GET, POST, DELETE")
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// allowedHost rejects requests for names other than the addresses the
+// server listens on. A site that points its own name at 127.0.0.1 (DNS
+// rebinding) can make a browser send it requests, but not with a Host
+// header naming one of those addresses.
+func (t *httpTransport) allowedHost(r *http.Request) bool {
+	if t.hosts == nil {
+		return true
+	}
+	host, _, err := net.SplitHostPort(r.Host)
+	if err != nil {
+		host = strings.Trim(r.Host, "[]")
+	}
+	return t.hosts[strings.ToLower(host)]
+}
+
+// allowedOrigin rejects browser requests from pages not served from this
+// machine. Other sites have no business calling the server, whatever the
+// Host header says.
+func allowedOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	switch strings.ToLower(u.Hostname()) {
+	case "localhost", "127.0.0.1", "::1":
+		return true
+	}
+	return false
+}
+
+func (t *httpTransport) authorized(r *http.Request) bool {
+	if t.token == "" {
+		return true
+	}
+	got, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return ok && subtle.ConstantTimeCompare([]byte(got), []byte(t.token)) == 1
+}
+
+func (t *httpTransport) handlePost(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxMessageSize+1))
+	if err != nil {
+		http.Error(w, "Failed to read request", http.StatusBadRequest)
+		return
+	}
+	if len(body) > maxMessageSize {
+		http.Error(w, "Message too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+	data := bytes.TrimSpace(body)
+	if len(data) == 0 {
+		http.Error(w, "Empty request", http.StatusBadRequest)
+		return
+	}
+
+	// initialize opens a session; every other message must name one
+	var probe struct {
+		Method string `json:"method"`
+	}
+	json.Unmarshal(data, &probe)
+	var sess *httpSession
+	if probe.Method == "initialize" {
+		if sess, err = t.newSession(); err != nil {
+			status := http.StatusInternalServerError
+			if errors.Is(err, errTooManySessions) {
+				status = http.StatusServiceUnavailable
+			}
+			http.Error(w, err.Error(), status)
+			return
+		}
+		w.Header().Set(sessionHeader, sess.id)
+	} else if sess = t.lookup(w, r); sess == nil {
+		return
+	}
+	defer t.release(sess)
+
+	stream := acceptsEventStream(r)
+	var (
+		mu        sync.Mutex
+		finished  bool
+		streaming bool
+		response  interface{}
+	)
+	reply := func(msg interface{}) {
+		mu.Lock()
+		defer mu.Unlock()
+		if finished {
+			return
+		}
+		if !stream {
+			// Plain JSON can only carry the response itself, so our own
+			// requests go out on the session's GET stream
+			switch msg.(type) {
+			case JSONRPCResponse:
+				response = msg
+			case JSONRPCRequest:
+				sess.notify(msg)
+			}
+			return
+		}
+		if !streaming {
+			startEventStream(w)
+			streaming = true
+		}
+		writeEvent(w, msg)
+	}
+
+	if done := sess.handleMessage(r.Context(), data, reply); done != nil {
+		<-done
+	}
+	mu.Lock()
+	finished = true
+	mu.Unlock()
+
+	if probe.Method == "initialize" && sess.version() == "" {
+		// initialize failed, so the client never got a usable session
+		t.remove(sess.id)
+	}
+	if streaming {
+		return
+	}
+	if response == nil {
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+func (t *httpTransport) handleGet(w http.ResponseWriter, r *http.Request) {
+	if !acceptsEventStream(r) {
+		http.Error(w, "GET requires Accept: text/event-stream", http.StatusNotAcceptable)
+		return
+	}
+	sess := t.lookup(w, r)
+	if sess == nil {
+		return
+	}
+	defer t.release(sess)
+
+	sess.streamMu.Lock()
+	busy := sess.streaming
+	sess.streaming = true
+	sess.streamMu.Unlock()
+	if busy {
+		http.Error(w, "Session already has an event stream", http.StatusConflict)
+		return
+	}
+	defer func() {
+		sess.streamMu.Lock()
+		sess.streaming = false
+		sess.streamMu.Unlock()
+	}()
+
+	startEventStream(w)
+	ticker := time.NewTicker(keepAliveInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-sess.done:
+			return
+		case msg := <-sess.events:
+			writeEvent(w, msg)
+		case <-ticker.C:
+			fmt.Fprint(w, ": keep-alive\n\n")
+			flush(w)
+		}
+	}
+}
+
+func (t *httpTransport) handleDelete(w http.ResponseWriter, r *http.Request) {
+	sess := t.lookup(w, r)
+	if sess == nil {
+		return
+	}
+	t.release(sess)
+	t.remove(sess.id)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (t *httpTransport) newSession() (*httpSession, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return nil, fmt.Errorf("failed to create session id: %w", err)
+	}
+	sess := &httpSession{
+		events:   make(chan interface{}, eventBacklog),
+		done:     make(chan struct{}),
+		requests: 1,
+	}
+	sess.session = newSession(t.server, hex.EncodeToString(buf), func(msg interface{}) {
+		select {
+		case sess.events <- msg:
+		default:
+			log.Printf("MCP session %s: dropping notification, no event stream is reading", sess.id)
+		}
+	})
+
+	// Make room by ending idle sessions before turning the client away
+	if t.count() >= t.maxSessions {
+		t.evictIdle(time.Now())
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if len(t.sessions) >= t.maxSessions {
+		return nil, fmt.Errorf("%w: %d are open", errTooManySessions, len(t.sessions))
+	}
+	t.sessions[sess.id] = sess
+	return sess, nil
+}
+
+func (t *httpTransport) count() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return len(t.sessions)
+}
+
+// lookup finds the session named by the request and holds it until
+// release, answering with an error and returning nil if there is none.
+func (t *httpTransport) lookup(w http.ResponseWriter, r *http.Request) *httpSession {
+	id := r.Header.Get(sessionHeader)
+	if id == "" {
+		http.Error(w, "Missing "+sessionHeader+" header", http.StatusBadRequest)
+		return nil
+	}
+	t.mu.Lock()
+	sess, ok := t.sessions[id]
+	if ok {
+		sess.requests++
+	}
+	t.mu.Unlock()
+	if !ok {
+		http.Error(w, "Unknown session", http.StatusNotFound)
+		return nil
+	}
+	return sess
+}
+
+// release ends a request's hold on a session, starting its idle time
+func (t *httpTransport) release(sess *httpSession) {
+	t.mu.Lock()
+	sess.requests--
+	sess.lastUsed = time.Now()
+	t.mu.Unlock()
+}
+
+// evictIdle ends the sessions that no request has held for idleTimeout,
+// with their subscriptions and requests still running
+func (t *httpTransport) evictIdle(now time.Time) {
+	t.mu.Lock()
+	var idle []*httpSession
+	for id, sess := range t.sessions {
+		if sess.requests == 0 && now.Sub(sess.lastUsed) >= t.idleTimeout {
+			idle = append(idle, sess)
+			delete(t.sessions, id)
+		}
+	}
+	t.mu.Unlock()
+	for _, sess := range idle {
+		log.Printf("MCP session %s: closing after %s without requests", sess.id, t.idleTimeout)
+		sess.end()
+	}
+}
+
+func (t *httpTransport) remove(id string) {
+	t.mu.Lock()
+	sess, ok := t.sessions[id]
+	delete(t.sessions, id)
+	t.mu.Unlock()
+	if ok {
+		sess.end()
+	}
+}
+
+// end closes a session removed from the transport and its event stream
+func (s *httpSession) end() {
+	s.close()
+	close(s.done)
+}
+
+func (t *httpTransport) closeAll() {
+	t.mu.Lock()
+	ids := make([]string, 0, len(t.sessions))
+	for id := range t.sessions {
+		ids = append(ids, id)
+	}
+	t.mu.Unlock()
+	for _, id := range ids {
+		t.remove(id)
+	}
+}
+
+func acceptsEventStream(r *http.Request) bool {
+	return strings.Contains(r.Header.Get("Accept"), "text/event-stream")
+}
+
+func startEventStream(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flush(w)
+}
+
+func writeEvent(w http.ResponseWriter, msg interface{}) {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		log.Printf("failed to encode MCP message: %v", err)
+		return
+	}
+	fmt.Fprintf(w, "event: message\ndata: %s\n\n", data)
+	flush(w)
+}
+
+func flush(w http.ResponseWriter) {
+	if f, ok := w.(http.Flusher); ok {
+		f.Flush()
+	}
+}
@@ -0,0 +1,235 @@
+package mcp
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func startHTTP(t *testing.T, cfg Config) (*httpTransport, *httptest.Server) {
+	t.Helper()
+	if cfg.Backend == nil {
+		cfg.Backend = fakeBackend(t)
+	}
+	tr := newHTTPTransport(NewServer(cfg), "")
+	srv := httptest.NewServer(tr)
+	t.Cleanup(func() {
+		tr.closeAll()
+		srv.Close()
+	})
+	return tr, srv
+}
+
+// post sends one message, within session unless it is empty
+func post(t *testing.T, srv *httptest.Server, session, body string) *http.Response {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodPost, srv.URL+mcpEndpoint, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	if session != "" {
+		req.Header.Set(sessionHeader, session)
+	}
+	resp, err := srv.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	return resp
+}
+
+// initializeHTTP opens a session and returns its id, or "" with the status
+// when the server refused
+func initializeHTTP(t *testing.T, srv *httptest.Server) (string, int) {
+	t.Helper()
+	resp := post(t, srv, "", `{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"2025-06-18"}}`)
+	return resp.Header.Get(sessionHeader), resp.StatusCode
+}
+
+func ping(t *testing.T, srv *httptest.Server, session string) int {
+	t.Helper()
+	return post(t, srv, session, `{"jsonrpc":"2.0","id":2,"method":"ping"}`).StatusCode
+}
+
+func TestHTTPSessionLimit(t *testing.T) {
+	tr, srv := startHTTP(t, Config{})
+	tr.maxSessions = 2
+
+	first, _ := initializeHTTP(t, srv)
+	if second, status := initializeHTTP(t, srv); second == "" {
+		t.Fatalf("second session refused with %d", status)
+	}
+	if id, status := initializeHTTP(t, srv); id != "" || status != http.StatusServiceUnavailable {
+		t.Fatalf("third session: got %q with %d, want %d", id, status, http.StatusServiceUnavailable)
+	}
+
+	req, _ := http.NewRequest(http.MethodDelete, srv.URL+mcpEndpoint, nil)
+	req.Header.Set(sessionHeader, first)
+	if resp, err := srv.Client().Do(req); err != nil || resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("delete failed: %v %v", resp, err)
+	}
+	if id, status := initializeHTTP(t, srv); id == "" {
+		t.Fatalf("session after a delete refused with %d", status)
+	}
+
+	// Idle sessions make room for new ones
+	tr.idleTimeout = 0
+	if id, status := initializeHTTP(t, srv); id == "" {
+		t.Fatalf("session with idle ones open refused with %d", status)
+	}
+}
+
+func TestHTTPIdleSessionsEvicted(t *testing.T) {
+	tr, srv := startHTTP(t, Config{})
+
+	idle, _ := initializeHTTP(t, srv)
+	resp := post(t, srv, idle, `{"jsonrpc":"2.0","id":3,"method":"resources/subscribe","params":{"uri":"svcm://unit/web.service"}}`)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("subscribe failed with %d", resp.StatusCode)
+	}
+	tr.mu.Lock()
+	sess := tr.sessions[idle]
+	tr.mu.Unlock()
+
+	// A session with an open event stream is in use however long it is quiet
+	streaming, _ := initializeHTTP(t, srv)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL+mcpEndpoint, nil)
+	req.Header.Set("Accept", "text/event-stream")
+	req.Header.Set(sessionHeader, streaming)
+	stream, err := srv.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stream.Body.Close()
+
+	tr.evictIdle(time.Now())
+	if ping(t, srv, idle) != http.StatusOK {
+		t.Fatal("session evicted before its idle timeout")
+	}
+
+	tr.evictIdle(time.Now().Add(tr.idleTimeout))
+	if status := ping(t, srv, idle); status != http.StatusNotFound {
+		t.Errorf("idle session answered with %d, want %d", status, http.StatusNotFound)
+	}
+	select {
+	case <-sess.done:
+	default:
+		t.Error("evicted session was not closed")
+	}
+	sess.subMu.Lock()
+	watching := sess.watch != nil
+	sess.subMu.Unlock()
+	if watching {
+		t.Error("evicted session still watches its subscriptions")
+	}
+
+	if status := ping(t, srv, streaming); status != http.StatusOK {
+		t.Errorf("session with an event stream answered with %d", status)
+	}
+}
+
+func TestHTTPRejectsForeignHosts(t *testing.T) {
+	_, srv := startHTTP(t, Config{})
+	port := srv.Listener.Addr().(*net.TCPAddr).Port
+
+	tests := []struct {
+		name, host, origin string
+		want               int
+	}{
+		{"rebound name", fmt.Sprintf("evil.example:%d", port), fmt.Sprintf("http://evil.example:%d", port), http.StatusForbidden},
+		{"foreign host", "evil.example", "", http.StatusForbidden},
+		{"foreign origin", fmt.Sprintf("127.0.0.1:%d", port), "http://evil.example", http.StatusForbidden},
+		{"localhost", fmt.Sprintf("localhost:%d", port), fmt.Sprintf("http://localhost:%d", port), http.StatusOK},
+		{"loopback", fmt.Sprintf("[::1]:%d", port), "", http.StatusOK},
+	}
+	for _, tt := range tests {
+		req, _ := http.NewRequest(http.MethodPost, srv.URL+mcpEndpoint, strings.NewReader(`{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"2025-06-18"}}`))
+		req.Host = tt.host
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Accept", "application/json")
+		if tt.origin != "" {
+			req.Header.Set("Origin", tt.origin)
+		}
+		resp, err := srv.Client().Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != tt.want {
+			t.Errorf("%s: got %d, want %d", tt.name, resp.StatusCode, tt.want)
+		}
+	}
+}
+
+func TestHTTPConfirmationOnEventStream(t *testing.T) {
+	_, srv := startHTTP(t, Config{Policy: &Policy{Confirm: []string{"stop"}}})
+	resp := post(t, srv, "", `{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"2025-06-18","capabilities":{"elicitation":{}}}}`)
+	session := resp.Header.Get(sessionHeader)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL+mcpEndpoint, nil)
+	req.Header.Set("Accept", "text/event-stream")
+	req.Header.Set(sessionHeader, session)
+	stream, err := srv.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stream.Body.Close()
+
+	// The call is answered with plain JSON, so the question goes out on
+	// the GET stream
+	answered := make(chan string, 1)
+	go func() {
+		req, _ := http.NewRequest(http.MethodPost, srv.URL+mcpEndpoint, strings.NewReader(`{"jsonrpc":"2.0","id":2,"method":"tools/call","params":{"name":"stop_service","arguments":{"name":"web"}}}`))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Accept", "application/json")
+		req.Header.Set(sessionHeader, session)
+		resp, err := srv.Client().Do(req)
+		if err != nil {
+			answered <- err.Error()
+			return
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		answered <- string(body)
+	}()
+
+	scanner := bufio.NewScanner(stream.Body)
+	var question message
+	for scanner.Scan() {
+		if data, ok := strings.CutPrefix(scanner.Text(), "data: "); ok {
+			if err := json.Unmarshal([]byte(data), &question); err != nil {
+				t.Fatal(err)
+			}
+			break
+		}
+	}
+	if question.Method != "elicitation/create" {
+		t.Fatalf("got %+v on the event stream, want an elicitation request", question)
+	}
+	if status := post(t, srv, session, fmt.Sprintf(`{"jsonrpc":"2.0","id":%s,"result":{"action":"accept"}}`, question.ID)).StatusCode; status != http.StatusAccepted {
+		t.Fatalf("answer got %d, want %d", status, http.StatusAccepted)
+	}
+
+	select {
+	case body := <-answered:
+		if !strings.Contains(body, `"id":2`) || strings.Contains(body, `"isError":true`) {
+			t.Errorf("confirmed stop answered %s", body)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("the call was not answered after the user confirmed")
+	}
+}
@@ -0,0 +1,291 @@
+package mcp
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"svcm/src/internal/core"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Policy restricts what mutating tools an assistant may run. It is loaded
+// from YAML, e.g.:
+//
+//	read_only: false
+//	allow: ["dev-*.service"]
+//	deny: ["sshd.service"]
+//	confirm: ["stop", "disable"]
+//	rate_limits:
+//	  "*": {count: 20, per: 1m}
+//	audit_log: /var/log/svcm-mcp-audit.log
+type Policy struct {
+	// ReadOnly rejects every mutating tool
+	ReadOnly bool `yaml:"read_only"`
+	// Allow lists unit globs that may be changed; empty allows all units
+	Allow []string `yaml:"allow"`
+	// Deny lists unit globs that may never be changed, overriding Allow
+	Deny []string `yaml:"deny"`
+	// Confirm lists actions ("stop", "*", ...) the user must approve. The
+	// server asks through the client (MCP elicitation), never the model, so
+	// clients that cannot ask their user cannot run these actions.
+	Confirm []string `yaml:"confirm"`
+	// RateLimits caps calls per action; "*" applies to all actions combined
+	RateLimits map[string]RateLimit `yaml:"rate_limits"`
+	// AuditLog is the file decisions are appended to as JSON lines
+	AuditLog string `yaml:"audit_log"`
+
+	mu    sync.Mutex
+	calls map[string][]time.Time
+	audit io.WriteCloser
+}
+
+type RateLimit struct {
+	Count int           `yaml:"count"`
+	Per   time.Duration `yaml:"per"`
+}
+
+// actionRequest describes a mutating tool call awaiting a policy decision
+type actionRequest struct {
+	Tool   string
+	Action string
+	Unit   string
+	Scope  string
+}
+
+type auditRecord struct {
+	Time     time.Time `json:"time"`
+	Tool     string    `json:"tool"`
+	Action   string    `json:"action"`
+	Unit     string    `json:"unit"`
+	Scope    string    `json:"scope"`
+	Decision string    `json:"decision"`
+	Reason   string    `json:"reason,omitempty"`
+}
+
+// DefaultPolicyPath is where the policy is looked up when none is given
+func DefaultPolicyPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "svcm", "mcp-policy.yaml")
+}
+
+func defaultAuditLogPath() string {
+	dir := os.Getenv("XDG_STATE_HOME")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return ""
+		}
+		dir = filepath.Join(home, ".local", "state")
+	}
+	return filepath.Join(dir, "svcm", "mcp-audit.log")
+}
+
+// LoadPolicy reads the policy at file. An empty file uses the default
+// location, and a missing default file yields a permissive policy that
+// still writes the audit log. A non-empty auditLog overrides the policy's
+// audit_log; "-" writes the audit records to stderr.
+func LoadPolicy(file, auditLog string) (*Policy, error) {
+	p := &Policy{}
+	explicit := file != ""
+	if !explicit {
+		file = DefaultPolicyPath()
+	}
+
+	if file != "" {
+		data, err := os.ReadFile(file)
+		switch {
+		case err == nil:
+			if err := yaml.Unmarshal(data, p); err != nil {
+				return nil, fmt.Errorf("invalid policy %s: %w", file, err)
+			}
+		case explicit || !errors.Is(err, os.ErrNotExist):
+			return nil, fmt.Errorf("failed to read policy: %w", err)
+		}
+	}
+
+	if err := p.validate(); err != nil {
+		return nil, fmt.Errorf("invalid policy %s: %w", file, err)
+	}
+
+	if auditLog != "" {
+		p.AuditLog = auditLog
+	}
+	if p.AuditLog == "" {
+		p.AuditLog = defaultAuditLogPath()
+	}
+	if p.AuditLog != "" && p.AuditLog != "-" {
+		if err := os.MkdirAll(filepath.Dir(p.AuditLog), 0o700); err != nil {
+			return nil, fmt.Errorf("failed to create audit log directory: %w", err)
+		}
+		f, err := os.OpenFile(p.AuditLog, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o600)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open audit log: %w", err)
+		}
+		p.audit = f
+	}
+	return p, nil
+}
+
+func (p *Policy) validate() error {
+	for _, pattern := range append(append([]string{}, p.Allow...), p.Deny...) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("bad glob %q: %w", pattern, err)
+		}
+	}
+	for action, limit := range p.RateLimits {
+		if limit.Count <= 0 || limit.Per <= 0 {
+			return fmt.Errorf("rate limit for %q needs a positive count and period", action)
+		}
+	}
+	return nil
+}
+
+// Close releases the audit log.
+func (p *Policy) Close() {
+	if p != nil && p.audit != nil {
+		p.audit.Close()
+	}
+}
+
+// Authorize decides on a mutating call and records the decision. confirm
+// asks the user to approve actions listed in Confirm; when it is nil they
+// are denied. A non-nil error explains why the call was denied.
+func (p *Policy) Authorize(req actionRequest, confirm func(actionRequest) (bool, error)) error {
+	// Match the unit name systemd will actually be asked about
+	req.Unit = core.UnitName(req.Unit)
+	err := p.decide(req, confirm)
+
+	rec := auditRecord{
+		Time:     time.Now(),
+		Tool:     req.Tool,
+		Action:   req.Action,
+		Unit:     req.Unit,
+		Scope:    req.Scope,
+		Decision: "allowed",
+	}
+	if err != nil {
+		rec.Decision = "denied"
+		rec.Reason = err.Error()
+	}
+	p.record(rec)
+	return err
+}
+
+func (p *Policy) decide(req actionRequest, confirm func(actionRequest) (bool, error)) error {
+	if p.ReadOnly {
+		return fmt.Errorf("the MCP policy is read-only")
+	}
+	if matchAny(p.Deny, req.Unit) {
+		return fmt.Errorf("%s is on the deny list", req.Unit)
+	}
+	if len(p.Allow) > 0 && !matchAny(p.Allow, req.Unit) {
+		return fmt.Errorf("%s is not on the allow list", req.Unit)
+	}
+	if p.needsConfirmation(req.Action) {
+		if confirm == nil {
+			return fmt.Errorf("%s requires confirmation by the user, which this MCP client cannot ask for", req.Action)
+		}
+		ok, err := confirm(req)
+		if err != nil {
+			return fmt.Errorf("failed to ask the user to confirm %s: %w", req.Action, err)
+		}
+		if !ok {
+			return fmt.Errorf("the user did not confirm %s of %s", req.Action, req.Unit)
+		}
+	}
+	return p.consumeRate(req.Action)
+}
+
+func (p *Policy) needsConfirmation(action string) bool {
+	for _, a := range p.Confirm {
+		if a == action || a == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+// consumeRate checks the per-action and global windows and, if both have
+// room, counts the call against them.
+func (p *Policy) consumeRate(action string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.calls == nil {
+		p.calls = make(map[string][]time.Time)
+	}
+
+	now := time.Now()
+	keys := []string{action, "*"}
+	for _, key := range keys {
+		limit, ok := p.RateLimits[key]
+		if !ok {
+			continue
+		}
+		recent := p.calls[key][:0]
+		for _, t := range p.calls[key] {
+			if now.Sub(t) < limit.Per {
+				recent = append(recent, t)
+			}
+		}
+		p.calls[key] = recent
+		if len(recent) >= limit.Count {
+			scope := action
+			if key == "*" {
+				scope = "mutating"
+			}
+			return fmt.Errorf("rate limit exceeded: at most %d %s calls per %s", limit.Count, scope, limit.Per)
+		}
+	}
+	for _, key := range keys {
+		if _, ok := p.RateLimits[key]; ok {
+			p.calls[key] = append(p.calls[key], now)
+		}
+	}
+	return nil
+}
+
+func (p *Policy) record(rec auditRecord) {
+	line, err := json.Marshal(rec)
+	if err != nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.audit == nil {
+		if p.AuditLog == "-" {
+			fmt.Fprintf(os.Stderr, "%s\n", line)
+		}
+		return
+	}
+	if _, err := fmt.Fprintf(p.audit, "%s\n", line); err != nil {
+		log.Printf("failed to write MCP audit log: %v", err)
+	}
+}
+
+func matchAny(patterns []string, unit string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, unit); ok {
+			return true
+		}
+		// Let "foo" match "foo.service" like the rest of svcm does
+		if !strings.Contains(pattern, ".") {
+			if ok, _ := path.Match(pattern+".service", unit); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,183 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"svcm/src/internal/core"
+)
+
+// promptBuilder gathers unit data into the text of a prompt
+type promptBuilder func(ctx context.Context, m core.Manager, args map[string]string) (string, error)
+
+type promptDef struct {
+	Prompt
+	build promptBuilder
+}
+
+var scopeArgument = PromptArgument{
+	Name:        "scope",
+	Description: "Service manager to use, user or system; defaults to the bus svcm was started with",
+}
+
+var prompts = []promptDef{
+	{
+		Prompt: Prompt{
+			Name:        "diagnose_failed_service",
+			Description: "Explain why a service failed using its status, unit file and recent logs",
+			Arguments: []PromptArgument{
+				{Name: "name", Description: "Service name", Required: true},
+				scopeArgument,
+			},
+		},
+		build: diagnoseService,
+	},
+	{
+		Prompt: Prompt{
+			Name:        "review_unit_file",
+			Description: "Review a unit file and its drop-ins for mistakes and missing hardening",
+			Arguments: []PromptArgument{
+				{Name: "name", Description: "Service name", Required: true},
+				scopeArgument,
+			},
+		},
+		build: reviewUnitFile,
+	},
+	{
+		Prompt: Prompt{
+			Name:        "summarize_failed_services",
+			Description: "Summarize every failed service with its last log lines",
+			Arguments:   []PromptArgument{scopeArgument},
+		},
+		build: summarizeFailed,
+	},
+}
+
+func findPrompt(name string) *promptDef {
+	for i := range prompts {
+		if prompts[i].Name == name {
+			return &prompts[i]
+		}
+	}
+	return nil
+}
+
+func (s *Server) getPrompt(ctx context.Context, raw json.RawMessage) (interface{}, error) {
+	var params struct {
+		Name      string            `json:"name"`
+		Arguments map[string]string `json:"arguments"`
+	}
+	if err := decodeParams(raw, &params); err != nil {
+		return nil, err
+	}
+	p := findPrompt(params.Name)
+	if p == nil {
+		return nil, invalidParams("Unknown prompt: " + params.Name)
+	}
+	for _, a := range p.Arguments {
+		if a.Required && params.Arguments[a.Name] == "" {
+			return nil, invalidParams(fmt.Sprintf("missing required argument %q", a.Name))
+		}
+	}
+
+	system, err := s.resolveScope(map[string]interface{}{"scope": params.Arguments["scope"]})
+	if err != nil {
+		return nil, err
+	}
+	m, err := s.manager(system)
+	if err != nil {
+		return nil, toRPCError(fmt.Errorf("failed to connect to systemd: %w", err))
+	}
+	text, err := p.build(ctx, m, params.Arguments)
+	if err != nil {
+		return nil, toRPCError(err)
+	}
+	return map[string]interface{}{
+		"description": p.Description,
+		"messages": []PromptMessage{
+			{Role: "user", Content: Content{Type: "text", Text: text}},
+		},
+	}, nil
+}
+
+// section appends a fenced block, or the error that kept it empty
+func section(b *strings.Builder, title, body string, err error) {
+	fmt.Fprintf(b, "\n## %s\n", title)
+	if err != nil {
+		fmt.Fprintf(b, "Unavailable: %v\n", err)
+		return
+	}
+	fmt.Fprintf(b, "```\n%s```\n", strings.TrimRight(body, "\n")+"\n")
+}
+
+func diagnoseService(ctx context.Context, m core.Manager, args map[string]string) (string, error) {
+	name := args["name"]
+	d, err := m.GetServiceDetails(ctx, name)
+	if err != nil {
+		return "", err
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "The systemd service %s is not working as expected. Using the status, unit file and journal below, "+
+		"explain the most likely cause and suggest a fix. Ask before running anything that changes services.\n", d.Name)
+	section(&b, "Status", formatStatus(d), nil)
+
+	var file string
+	f, err := m.GetUnitFile(ctx, name)
+	if err == nil {
+		file = formatUnitFile(f)
+	}
+	section(&b, "Unit file", file, err)
+
+	var logs string
+	entries, err := m.GetLogs(ctx, core.LogQuery{Unit: name, Lines: logResourceLines})
+	if err == nil {
+		logs = formatLogs(entries)
+	}
+	section(&b, "Recent logs", logs, err)
+	return b.String(), nil
+}
+
+func reviewUnitFile(ctx context.Context, m core.Manager, args map[string]string) (string, error) {
+	name := args["name"]
+	f, err := m.GetUnitFile(ctx, name)
+	if err != nil {
+		return "", err
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Review the unit file of %s below. Point out mistakes, options that have no effect, "+
+		"and sandboxing or hardening settings worth adding. Show suggested changes as a drop-in.\n", f.Name)
+	section(&b, "Unit file", formatUnitFile(f), nil)
+	return b.String(), nil
+}
+
+func summarizeFailed(ctx context.Context, m core.Manager, args map[string]string) (string, error) {
+	services, err := m.ListServices(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	var b strings.Builder
+	var failed int
+	for _, svc := range services {
+		if svc.ActiveState != "failed" {
+			continue
+		}
+		failed++
+		var logs string
+		entries, err := m.GetLogs(ctx, core.LogQuery{Unit: svc.Name, Lines: 10})
+		if err == nil {
+			logs = formatLogs(entries)
+		}
+		section(&b, svc.Name+" - "+svc.Description, logs, err)
+	}
+
+	if failed == 0 {
+		return "No services are in the failed state. Say so briefly.\n", nil
+	}
+	return fmt.Sprintf("%d services are in the failed state. For each, summarize the likely cause from its last log lines, "+
+		"then suggest which to look at first.\n", failed) + b.String(), nil
+}
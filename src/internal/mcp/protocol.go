@@ -0,0 +1,161 @@
+package mcp
+
+import (
+	"encoding/json"
+
+	"svcm/src/internal/core"
+)
+
+// Protocol revisions this server understands, newest first. The first entry
+// is offered when a client asks for a version we do not know.
+var supportedProtocolVersions = []string{"2025-06-18", "2025-03-26", "2024-11-05"}
+
+const (
+	serverName    = "svcm"
+	serverVersion = "0.1.0"
+)
+
+// JSON-RPC 2.0 error codes
+const (
+	codeParseError     = -32700
+	codeInvalidRequest = -32600
+	codeMethodNotFound = -32601
+	codeInvalidParams  = -32602
+	codeInternalError  = -32603
+
+	// MCP specific
+	codeResourceNotFound = -32002
+
+	// svcm specific, for failures of the service manager
+	codePermissionDenied = -32010
+	codeJobFailed        = -32011
+	codeDependencyFailed = -32012
+	codeTimeout          = -32013
+	codeCanceled         = -32014
+)
+
+// Minimal JSON-RPC 2.0 types for MCP. A request without an id is a
+// notification and never gets a response.
+type JSONRPCRequest struct {
+	JSONRPC string          `json:"jsonrpc"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+	ID      json.RawMessage `json:"id,omitempty"`
+}
+
+func (r *JSONRPCRequest) isNotification() bool {
+	return len(r.ID) == 0
+}
+
+type JSONRPCResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	Result  interface{}     `json:"result,omitempty"`
+	Error   *JSONRPCError   `json:"error,omitempty"`
+	ID      json.RawMessage `json:"id"`
+}
+
+type JSONRPCNotification struct {
+	JSONRPC string      `json:"jsonrpc"`
+	Method  string      `json:"method"`
+	Params  interface{} `json:"params,omitempty"`
+}
+
+type JSONRPCError struct {
+	Code    int         `json:"code"`
+	Message string      `json:"message"`
+	Data    interface{} `json:"data,omitempty"`
+}
+
+func (e *JSONRPCError) Error() string {
+	return e.Message
+}
+
+func invalidParams(format string) *JSONRPCError {
+	return &JSONRPCError{Code: codeInvalidParams, Message: format}
+}
+
+type Tool struct {
+	Name              string      `json:"name"`
+	Description       string      `json:"description"`
+	InputSchemaSchema interface{} `json:"inputSchema"`
+}
+
+// Content is a single item of a tool result
+type Content struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+type ToolResult struct {
+	Content           []Content   `json:"content"`
+	StructuredContent interface{} `json:"structuredContent,omitempty"`
+	IsError           bool        `json:"isError,omitempty"`
+}
+
+// structuredProtocolVersion is the first revision with structuredContent
+const structuredProtocolVersion = "2025-06-18"
+
+func textResult(text string) *ToolResult {
+	return &ToolResult{Content: []Content{{Type: "text", Text: text}}}
+}
+
+// structuredResult pairs human readable text with the same data as JSON
+func structuredResult(text string, data interface{}) *ToolResult {
+	return &ToolResult{Content: []Content{{Type: "text", Text: text}}, StructuredContent: data}
+}
+
+// errorResult reports a failed operation to the model, with the class and
+// code of the error as structured content when it has one
+func errorResult(err error) *ToolResult {
+	result := &ToolResult{Content: []Content{{Type: "text", Text: err.Error()}}, IsError: true}
+	if class := core.ErrorClass(err); class != "" {
+		result.StructuredContent = map[string]interface{}{
+			"error": class,
+			"code":  errorCode(err),
+		}
+	}
+	return result
+}
+
+type Resource struct {
+	URI         string `json:"uri"`
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	MimeType    string `json:"mimeType,omitempty"`
+}
+
+type ResourceTemplate struct {
+	URITemplate string `json:"uriTemplate"`
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	MimeType    string `json:"mimeType,omitempty"`
+}
+
+// ResourceContents is the text of a resource returned by resources/read
+type ResourceContents struct {
+	URI      string `json:"uri"`
+	MimeType string `json:"mimeType,omitempty"`
+	Text     string `json:"text"`
+}
+
+type Prompt struct {
+	Name        string           `json:"name"`
+	Description string           `json:"description,omitempty"`
+	Arguments   []PromptArgument `json:"arguments,omitempty"`
+}
+
+type PromptArgument struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Required    bool   `json:"required,omitempty"`
+}
+
+type PromptMessage struct {
+	Role    string  `json:"role"`
+	Content Content `json:"content"`
+}
+
+// requestMeta carries the optional _meta object of a request
+type requestMeta struct {
+	ProgressToken interface{} `json:"progressToken,omitempty"`
+}
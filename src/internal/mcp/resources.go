@@ -0,0 +1,224 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+
+	"svcm/src/internal/core"
+)
+
+// Resources describe units on the bus svcm was started with:
+//
+//	svcm://unit/<name>        status and properties as JSON
+//	svcm://unit/<name>/file   unit file and drop-ins
+//	svcm://unit/<name>/logs   recent journal entries
+const unitURIPrefix = "svcm://unit/"
+
+// logResourceLines is how many journal entries a logs resource holds
+const logResourceLines = 100
+
+var resourceTemplates = []ResourceTemplate{
+	{
+		URITemplate: unitURIPrefix + "{name}",
+		Name:        "unit",
+		Description: "State, main PID, unit file state and timestamps of a unit",
+		MimeType:    "application/json",
+	},
+	{
+		URITemplate: unitURIPrefix + "{name}/file",
+		Name:        "unit-file",
+		Description: "Unit file of a unit together with its drop-ins",
+		MimeType:    "text/plain",
+	},
+	{
+		URITemplate: unitURIPrefix + "{name}/logs",
+		Name:        "unit-logs",
+		Description: fmt.Sprintf("Last %d journal entries of a unit", logResourceLines),
+		MimeType:    "text/plain",
+	},
+}
+
+// parseUnitURI splits a resource URI into the unit name and the resource
+// kind: "" for status, "file" or "logs".
+func parseUnitURI(uri string) (name, kind string, err error) {
+	rest, ok := strings.CutPrefix(uri, unitURIPrefix)
+	if !ok {
+		return "", "", resourceNotFound(uri)
+	}
+	name, kind, _ = strings.Cut(rest, "/")
+	if name, err = url.PathUnescape(name); err != nil || name == "" {
+		return "", "", resourceNotFound(uri)
+	}
+	switch kind {
+	case "", "file", "logs":
+	default:
+		return "", "", resourceNotFound(uri)
+	}
+	return core.UnitName(name), kind, nil
+}
+
+func resourceNotFound(uri string) *JSONRPCError {
+	return &JSONRPCError{Code: codeResourceNotFound, Message: "Resource not found", Data: map[string]string{"uri": uri}}
+}
+
+func unitURI(name string) string {
+	return unitURIPrefix + url.PathEscape(name)
+}
+
+func (s *Server) listResources(ctx context.Context) (interface{}, error) {
+	m, err := s.manager(s.systemMode)
+	if err != nil {
+		return nil, toRPCError(fmt.Errorf("failed to connect to systemd: %w", err))
+	}
+	services, err := m.ListServices(ctx)
+	if err != nil {
+		return nil, toRPCError(err)
+	}
+	list := make([]Resource, 0, len(services))
+	for _, svc := range services {
+		list = append(list, Resource{
+			URI:         unitURI(svc.Name),
+			Name:        svc.Name,
+			Description: svc.Description,
+			MimeType:    "application/json",
+		})
+	}
+	return map[string]interface{}{"resources": list}, nil
+}
+
+func (s *Server) readResource(ctx context.Context, raw json.RawMessage) (interface{}, error) {
+	var params struct {
+		URI string `json:"uri"`
+	}
+	if err := decodeParams(raw, &params); err != nil {
+		return nil, err
+	}
+	name, kind, err := parseUnitURI(params.URI)
+	if err != nil {
+		return nil, err
+	}
+	m, err := s.manager(s.systemMode)
+	if err != nil {
+		return nil, toRPCError(fmt.Errorf("failed to connect to systemd: %w", err))
+	}
+
+	contents := ResourceContents{URI: params.URI, MimeType: "text/plain"}
+	switch kind {
+	case "":
+		d, err := m.GetServiceDetails(ctx, name)
+		if err != nil {
+			return nil, toRPCError(err)
+		}
+		data, err := json.MarshalIndent(d, "", "  ")
+		if err != nil {
+			return nil, toRPCError(err)
+		}
+		contents.MimeType = "application/json"
+		contents.Text = string(data)
+	case "file":
+		f, err := m.GetUnitFile(ctx, name)
+		if err != nil {
+			return nil, toRPCError(err)
+		}
+		contents.Text = formatUnitFile(f)
+	case "logs":
+		entries, err := m.GetLogs(ctx, core.LogQuery{Unit: name, Lines: logResourceLines})
+		if err != nil {
+			return nil, toRPCError(err)
+		}
+		contents.Text = formatLogs(entries)
+	}
+	return map[string]interface{}{"contents": []ResourceContents{contents}}, nil
+}
+
+// subscribeResource registers interest in a resource. The first
+// subscription starts listening for unit events; each event for a
+// subscribed unit is forwarded as notifications/resources/updated.
+func (c *session) subscribeResource(ctx context.Context, raw json.RawMessage) (interface{}, error) {
+	var params struct {
+		URI string `json:"uri"`
+	}
+	if err := decodeParams(raw, &params); err != nil {
+		return nil, err
+	}
+	name, _, err := parseUnitURI(params.URI)
+	if err != nil {
+		return nil, err
+	}
+	m, err := c.server.manager(c.server.systemMode)
+	if err != nil {
+		return nil, toRPCError(fmt.Errorf("failed to connect to systemd: %w", err))
+	}
+
+	c.subMu.Lock()
+	defer c.subMu.Unlock()
+	if c.watch == nil {
+		sub, err := m.Subscribe(ctx)
+		if err != nil {
+			return nil, toRPCError(err)
+		}
+		c.watch = sub
+		go c.forwardEvents(sub)
+	}
+	c.subscriptions[params.URI] = name
+	return struct{}{}, nil
+}
+
+func (c *session) unsubscribeResource(raw json.RawMessage) (interface{}, error) {
+	var params struct {
+		URI string `json:"uri"`
+	}
+	if err := decodeParams(raw, &params); err != nil {
+		return nil, err
+	}
+
+	c.subMu.Lock()
+	delete(c.subscriptions, params.URI)
+	empty := len(c.subscriptions) == 0
+	c.subMu.Unlock()
+	if empty {
+		c.stopWatch()
+	}
+	return struct{}{}, nil
+}
+
+func (c *session) forwardEvents(sub *core.Subscription) {
+	for ev := range sub.Events {
+		c.subMu.Lock()
+		var uris []string
+		for uri, unit := range c.subscriptions {
+			if unit == ev.Unit.Name {
+				uris = append(uris, uri)
+			}
+		}
+		c.subMu.Unlock()
+
+		for _, uri := range uris {
+			c.notify(notification("notifications/resources/updated", map[string]string{"uri": uri}))
+		}
+	}
+
+	if err := sub.Err(); err != nil {
+		log.Printf("MCP resource subscription ended: %v", err)
+	}
+	// Let the next subscribe call start a fresh watch
+	c.subMu.Lock()
+	if c.watch == sub {
+		c.watch = nil
+	}
+	c.subMu.Unlock()
+}
+
+func (c *session) stopWatch() {
+	c.subMu.Lock()
+	sub := c.watch
+	c.watch = nil
+	c.subMu.Unlock()
+	if sub != nil {
+		sub.Close()
+	}
+}
@@ -2,173 +2,270 @@ package mcp
 
 import (
 	"bufio"
+	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
+	"io"
+	"log"
 	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+
 	"svcm/src/internal/core"
 )
 
-// Minimal JSON-RPC 2.0 types for MCP
-type JSONRPCRequest struct {
-	JSONRPC string          `json:"jsonrpc"`
-	Method  string          `json:"method"`
-	Params  json.RawMessage `json:"params,omitempty"`
-	ID      interface{}     `json:"id"`
-}
+// maxMessageSize bounds a single newline-delimited message on stdio
+const maxMessageSize = 4 * 1024 * 1024
+
+var nullID = json.RawMessage("null")
+
+// Server holds what all MCP sessions share: the bus connections and the
+// policy. The protocol state of each client lives in a session, so stdio
+// and HTTP run the same dispatcher.
+type Server struct {
+	systemMode bool
+	policy     *Policy
+	backend    core.Backend
 
-type JSONRPCResponse struct {
-	JSONRPC string        `json:"jsonrpc"`
-	Result  interface{}   `json:"result,omitempty"`
-	Error   *JSONRPCError `json:"error,omitempty"`
-	ID      interface{}   `json:"id"`
+	mu       sync.Mutex
+	managers map[bool]core.Manager
+
+	wg sync.WaitGroup
 }
 
-type JSONRPCError struct {
-	Code    int    `json:"code"`
-	Message string `json:"message"`
+// Config holds the server settings chosen on the command line
+type Config struct {
+	// SystemMode selects the system bus unless a call asks for another scope
+	SystemMode bool
+	// Policy guards mutating tools; nil allows everything without auditing
+	Policy *Policy
+	// Listen serves HTTP on host:port or unix:/path instead of stdio
+	Listen string
+	// Token, when set, is required as a bearer token on HTTP requests
+	Token string
+	// Backend opens the managers; nil talks to systemd
+	Backend core.Backend
 }
 
-type Tool struct {
-	Name              string      `json:"name"`
-	Description       string      `json:"description"`
-	InputSchemaSchema interface{} `json:"inputSchema"`
+func NewServer(cfg Config) *Server {
+	backend := cfg.Backend
+	if backend == nil {
+		backend = core.SystemdBackend
+	}
+	return &Server{
+		systemMode: cfg.SystemMode,
+		policy:     cfg.Policy,
+		backend:    backend,
+		managers:   make(map[bool]core.Manager),
+	}
 }
 
 // MCP Server
-func Run() {
-	scanner := bufio.NewScanner(os.Stdin)
+func Run(cfg Config) {
+	s := NewServer(cfg)
+	var err error
+	if cfg.Listen != "" {
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		err = s.ListenAndServe(ctx, cfg.Listen, cfg.Token)
+	} else {
+		err = s.ServeStdio(context.Background(), os.Stdin, os.Stdout)
+	}
+	if err != nil {
+		log.Printf("MCP server stopped: %v", err)
+	}
+}
+
+// ServeStdio reads newline-delimited messages until in is closed, then
+// waits for in-flight requests to finish.
+func (s *Server) ServeStdio(ctx context.Context, in io.Reader, out io.Writer) error {
+	var writeMu sync.Mutex
+	write := func(msg interface{}) {
+		bytes, err := json.Marshal(msg)
+		if err != nil {
+			log.Printf("failed to encode MCP message: %v", err)
+			return
+		}
+		writeMu.Lock()
+		defer writeMu.Unlock()
+		fmt.Fprintf(out, "%s\n", bytes)
+	}
+	sess := newSession(s, "", write)
+
+	scanner := bufio.NewScanner(in)
+	scanner.Buffer(make([]byte, 64*1024), maxMessageSize)
 	for scanner.Scan() {
-		line := scanner.Bytes()
-		handleRequest(line)
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		sess.handleMessage(ctx, append([]byte(nil), line...), write)
 	}
+	// Nothing can answer our requests once the input ends
+	sess.endClientRequests()
+	s.wg.Wait()
+	sess.close()
+	s.closeManagers()
+	return scanner.Err()
 }
 
-func handleRequest(data []byte) {
-	var req JSONRPCRequest
-	if err := json.Unmarshal(data, &req); err != nil {
-		return // Ignore malformed
+// manager returns the long-lived connection for a scope, dialing it on
+// first use.
+func (s *Server) manager(system bool) (core.Manager, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if m, ok := s.managers[system]; ok {
+		return m, nil
 	}
+	m, err := s.backend(system)
+	if err != nil {
+		return nil, err
+	}
+	s.managers[system] = m
+	return m, nil
+}
 
-	var res interface{}
-	var err *JSONRPCError
+func (s *Server) closeManagers() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for system, m := range s.managers {
+		m.Close()
+		delete(s.managers, system)
+	}
+}
 
-	switch req.Method {
-	case "initialize":
-		res = map[string]interface{}{
-			"protocolVersion": "0.1.0",
-			"serverInfo": map[string]string{
-				"name":    "lsysctl-mcp",
-				"version": "0.1.0",
-			},
-			"capabilities": map[string]interface{}{
-				"tools": map[string]interface{}{},
-			},
-		}
-	case "notifications/initialized":
-		// No response needed
-		return
-	case "tools/list":
-		res = map[string]interface{}{
-			"tools": []Tool{
-				{
-					Name:        "list_services",
-					Description: "List all systemd services for the user",
-					InputSchemaSchema: map[string]interface{}{
-						"type":       "object",
-						"properties": map[string]interface{}{},
-					},
-				},
-				{
-					Name:        "start_service",
-					Description: "Start a systemd service",
-					InputSchemaSchema: map[string]interface{}{
-						"type": "object",
-						"properties": map[string]interface{}{
-							"name": map[string]string{"type": "string"},
-						},
-						"required": []string{"name"},
-					},
-				},
-				{
-					Name:        "stop_service",
-					Description: "Stop a systemd service",
-					InputSchemaSchema: map[string]interface{}{
-						"type": "object",
-						"properties": map[string]interface{}{
-							"name": map[string]string{"type": "string"},
-						},
-						"required": []string{"name"},
-					},
-				},
-			},
-		}
-	case "tools/call":
-		var params struct {
-			Name      string                 `json:"name"`
-			Arguments map[string]interface{} `json:"arguments"`
+// resolveScope maps the optional scope argument onto a bus
+func (s *Server) resolveScope(args map[string]interface{}) (bool, error) {
+	scope, err := optionalString(args, "scope")
+	if err != nil {
+		return false, err
+	}
+	switch scope {
+	case "":
+		return s.systemMode, nil
+	case "user":
+		return false, nil
+	case "system":
+		return true, nil
+	}
+	return false, invalidParams(fmt.Sprintf("invalid scope %q (expected user or system)", scope))
+}
+
+// authorize asks the policy whether a mutating tool may run, and with
+// now=true whether the start or stop it implies may run too. confirm asks
+// the user about actions the policy wants confirmed; nil denies them. A
+// denial is returned as a tool error so the model can explain it to the
+// user.
+func (s *Server) authorize(tool *toolDef, system bool, args map[string]interface{}, confirm func(actionRequest) (bool, error)) error {
+	if s.policy == nil {
+		return nil
+	}
+	name, err := stringArg(args, "name")
+	if err != nil {
+		return err
+	}
+	now := false
+	if tool.nowAction != "" {
+		if now, err = optionalBool(args, "now"); err != nil {
+			return err
 		}
-		json.Unmarshal(req.Params, &params)
+	}
+	scope := "user"
+	if system {
+		scope = "system"
+	}
+	req := actionRequest{
+		Tool:   tool.Name,
+		Action: tool.action,
+		Unit:   name,
+		Scope:  scope,
+	}
+	if err := s.policy.Authorize(req, confirm); err != nil {
+		return err
+	}
+	if now {
+		req.Action = tool.nowAction
+		return s.policy.Authorize(req, confirm)
+	}
+	return nil
+}
 
-		manager, e := core.NewSystemdManager(false)
-		if e != nil {
-			err = &JSONRPCError{Code: -32000, Message: e.Error()}
-			break
+type progressKey struct{}
+
+type progressFunc func(progress, total float64, message string)
+
+// progressNotifier sends progress for a request through the same channel
+// as its response.
+func progressNotifier(token interface{}, reply sender) progressFunc {
+	return func(progress, total float64, message string) {
+		params := map[string]interface{}{
+			"progressToken": token,
+			"progress":      progress,
+		}
+		if total > 0 {
+			params["total"] = total
 		}
-		defer manager.Close()
-
-		switch params.Name {
-		case "list_services":
-			list, e := manager.ListServices()
-			if e != nil {
-				err = &JSONRPCError{Code: -32000, Message: e.Error()}
-			} else {
-				// MCP expects text or artifacts
-				txt := ""
-				for _, s := range list {
-					txt += fmt.Sprintf("%-30s %s %s\n", s.Name, s.ActiveState, s.Description)
-				}
-				res = map[string]interface{}{
-					"content": []map[string]string{
-						{"type": "text", "text": txt},
-					},
-				}
-			}
-		case "start_service":
-			name := params.Arguments["name"].(string)
-			if e := manager.StartService(name); e != nil {
-				err = &JSONRPCError{Code: -32000, Message: e.Error()}
-			} else {
-				res = map[string]interface{}{
-					"content": []map[string]string{
-						{"type": "text", "text": "Service started"},
-					},
-				}
-			}
-		case "stop_service":
-			name := params.Arguments["name"].(string)
-			if e := manager.StopService(name); e != nil {
-				err = &JSONRPCError{Code: -32000, Message: e.Error()}
-			} else {
-				res = map[string]interface{}{
-					"content": []map[string]string{
-						{"type": "text", "text": "Service stopped"},
-					},
-				}
-			}
-		default:
-			err = &JSONRPCError{Code: -32601, Message: "Method not found"}
+		if message != "" {
+			params["message"] = message
 		}
-	default:
-		// Just ignore unknowns or pings
-		return
+		reply(notification("notifications/progress", params))
+	}
+}
+
+// reportProgress sends a progress notification if the caller asked for them
+func reportProgress(ctx context.Context, progress, total float64, message string) {
+	if f, ok := ctx.Value(progressKey{}).(progressFunc); ok {
+		f(progress, total, message)
 	}
+}
 
-	response := JSONRPCResponse{
-		JSONRPC: "2.0",
-		Result:  res,
-		Error:   err,
-		ID:      req.ID,
+func decodeParams(raw json.RawMessage, v interface{}) error {
+	if len(raw) == 0 || string(raw) == "null" {
+		return nil
+	}
+	if err := json.Unmarshal(raw, v); err != nil {
+		return invalidParams("Invalid params: " + err.Error())
 	}
-	bytes, _ := json.Marshal(response)
-	fmt.Printf("%s\n", bytes)
+	return nil
+}
+
+func toRPCError(err error) *JSONRPCError {
+	if rpcErr, ok := err.(*JSONRPCError); ok {
+		return rpcErr
+	}
+	rpcErr := &JSONRPCError{Code: errorCode(err), Message: err.Error()}
+	if class := core.ErrorClass(err); class != "" {
+		rpcErr.Data = map[string]interface{}{"error": class}
+	}
+	return rpcErr
+}
+
+// errorCode maps the class of a service manager error onto a JSON-RPC
+// error code
+func errorCode(err error) int {
+	switch core.ErrorClass(err) {
+	case "not_found":
+		return codeResourceNotFound
+	case "permission_denied":
+		return codePermissionDenied
+	case "timeout":
+		return codeTimeout
+	case "canceled":
+		return codeCanceled
+	case "dependency_failed":
+		return codeDependencyFailed
+	case "job_failed":
+		return codeJobFailed
+	}
+	return codeInternalError
+}
+
+func notification(method string, params interface{}) JSONRPCNotification {
+	return JSONRPCNotification{JSONRPC: "2.0", Method: method, Params: params}
+}
+
+func errorResponse(id json.RawMessage, err *JSONRPCError) JSONRPCResponse {
+	return JSONRPCResponse{JSONRPC: "2.0", Error: err, ID: id}
 }
@@ -0,0 +1,427 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+
+	"svcm/src/internal/core"
+)
+
+// sender delivers a message to the client
+type sender func(msg interface{})
+
+// errSessionClosed fails requests to a client that went away before it
+// answered them
+var errSessionClosed = errors.New("the MCP session ended")
+
+// session is the protocol state of one client: the negotiated revision,
+// requests in flight and resource subscriptions. stdio has a single
+// session; HTTP has one per Mcp-Session-Id.
+type session struct {
+	id     string
+	server *Server
+	// notify delivers messages that answer no request, such as resource updates
+	notify sender
+
+	mu              sync.Mutex
+	inFlight        map[string]context.CancelFunc
+	protocolVersion string
+	// elicitation is whether the client can ask its user for input
+	elicitation bool
+
+	// pending holds the requests we sent the client, by id, until it
+	// answers; it is nil once no answer can arrive
+	lastRequest int
+	pending     map[string]chan clientResponse
+
+	// subscriptions maps subscribed resource URIs to their unit
+	subMu         sync.Mutex
+	subscriptions map[string]string
+	watch         *core.Subscription
+}
+
+func newSession(s *Server, id string, notify sender) *session {
+	return &session{
+		id:            id,
+		server:        s,
+		notify:        notify,
+		inFlight:      make(map[string]context.CancelFunc),
+		pending:       make(map[string]chan clientResponse),
+		subscriptions: make(map[string]string),
+	}
+}
+
+// close cancels requests still running and drops resource subscriptions
+func (c *session) close() {
+	c.mu.Lock()
+	for _, cancel := range c.inFlight {
+		cancel()
+	}
+	c.mu.Unlock()
+	c.endClientRequests()
+	c.stopWatch()
+}
+
+// clientResponse is the answer to a request we sent the client
+type clientResponse struct {
+	Result json.RawMessage
+	Error  *JSONRPCError
+}
+
+// request sends the client a request through send and waits for its
+// answer
+func (c *session) request(ctx context.Context, method string, params interface{}, send sender) (json.RawMessage, error) {
+	raw, err := json.Marshal(params)
+	if err != nil {
+		return nil, err
+	}
+	ch := make(chan clientResponse, 1)
+	c.mu.Lock()
+	if c.pending == nil {
+		c.mu.Unlock()
+		return nil, errSessionClosed
+	}
+	c.lastRequest++
+	id, _ := json.Marshal(fmt.Sprintf("svcm-%d", c.lastRequest))
+	c.pending[string(id)] = ch
+	c.mu.Unlock()
+
+	send(JSONRPCRequest{JSONRPC: "2.0", Method: method, Params: raw, ID: id})
+	select {
+	case <-ctx.Done():
+		c.mu.Lock()
+		delete(c.pending, string(id))
+		c.mu.Unlock()
+		return nil, ctx.Err()
+	case r, ok := <-ch:
+		if !ok {
+			return nil, errSessionClosed
+		}
+		if r.Error != nil {
+			return nil, r.Error
+		}
+		return r.Result, nil
+	}
+}
+
+// answer hands a response of the client to the request waiting for it
+func (c *session) answer(id json.RawMessage, r clientResponse) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if ch, ok := c.pending[string(id)]; ok {
+		delete(c.pending, string(id))
+		ch <- r
+	}
+}
+
+// endClientRequests fails the requests the client has not answered, and
+// any sent later, once it can no longer answer
+func (c *session) endClientRequests() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, ch := range c.pending {
+		close(ch)
+	}
+	c.pending = nil
+}
+
+func (c *session) version() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.protocolVersion
+}
+
+// handleMessage processes one message. Requests are dispatched in the
+// background; their response and progress go to reply, and the returned
+// channel is closed once the request is finished. It is nil when the
+// message was not a request.
+func (c *session) handleMessage(ctx context.Context, data []byte, reply sender) <-chan struct{} {
+	if data[0] == '[' {
+		reply(errorResponse(nullID, &JSONRPCError{Code: codeInvalidRequest, Message: "Batch requests are not supported"}))
+		return nil
+	}
+
+	var msg struct {
+		JSONRPCRequest
+		Result json.RawMessage `json:"result,omitempty"`
+		Error  *JSONRPCError   `json:"error,omitempty"`
+	}
+	if err := json.Unmarshal(data, &msg); err != nil {
+		reply(errorResponse(nullID, &JSONRPCError{Code: codeParseError, Message: "Parse error: " + err.Error()}))
+		return nil
+	}
+	req := msg.JSONRPCRequest
+
+	if req.Method == "" && (len(msg.Result) > 0 || msg.Error != nil) {
+		c.answer(req.ID, clientResponse{Result: msg.Result, Error: msg.Error})
+		return nil
+	}
+	if req.JSONRPC != "2.0" || req.Method == "" {
+		id := req.ID
+		if len(id) == 0 {
+			id = nullID
+		}
+		reply(errorResponse(id, &JSONRPCError{Code: codeInvalidRequest, Message: "Invalid request: jsonrpc must be \"2.0\" and method is required"}))
+		return nil
+	}
+
+	if req.isNotification() {
+		c.handleNotification(&req)
+		return nil
+	}
+
+	reqCtx, cancel := context.WithCancel(ctx)
+	key := string(req.ID)
+	c.mu.Lock()
+	c.inFlight[key] = cancel
+	c.mu.Unlock()
+
+	done := make(chan struct{})
+	c.server.wg.Add(1)
+	go func() {
+		defer c.server.wg.Done()
+		defer close(done)
+		defer func() {
+			c.mu.Lock()
+			delete(c.inFlight, key)
+			c.mu.Unlock()
+			cancel()
+		}()
+
+		result, err := c.dispatch(reqCtx, &req, reply)
+		if reqCtx.Err() != nil {
+			// Cancelled by the client, which no longer expects a response
+			return
+		}
+		if err != nil {
+			reply(errorResponse(req.ID, toRPCError(err)))
+			return
+		}
+		reply(JSONRPCResponse{JSONRPC: "2.0", Result: result, ID: req.ID})
+	}()
+	return done
+}
+
+func (c *session) handleNotification(req *JSONRPCRequest) {
+	switch req.Method {
+	case "notifications/cancelled":
+		var params struct {
+			RequestID json.RawMessage `json:"requestId"`
+			Reason    string          `json:"reason,omitempty"`
+		}
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			return
+		}
+		c.mu.Lock()
+		cancel, ok := c.inFlight[string(params.RequestID)]
+		c.mu.Unlock()
+		if ok {
+			cancel()
+		}
+	}
+	// Other notifications need no action
+}
+
+func (c *session) dispatch(ctx context.Context, req *JSONRPCRequest, reply sender) (result interface{}, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = &JSONRPCError{Code: codeInternalError, Message: fmt.Sprintf("Internal error: %v", r)}
+		}
+	}()
+
+	switch req.Method {
+	case "initialize":
+		return c.initialize(req.Params)
+	case "ping":
+		return struct{}{}, nil
+	case "tools/list":
+		list := make([]Tool, 0, len(tools))
+		for _, t := range tools {
+			list = append(list, t.Tool)
+		}
+		return map[string]interface{}{"tools": list}, nil
+	case "tools/call":
+		return c.callTool(ctx, req.Params, reply)
+	case "resources/list":
+		return c.server.listResources(ctx)
+	case "resources/templates/list":
+		return map[string]interface{}{"resourceTemplates": resourceTemplates}, nil
+	case "resources/read":
+		return c.server.readResource(ctx, req.Params)
+	case "resources/subscribe":
+		return c.subscribeResource(ctx, req.Params)
+	case "resources/unsubscribe":
+		return c.unsubscribeResource(req.Params)
+	case "prompts/list":
+		list := make([]Prompt, 0, len(prompts))
+		for _, p := range prompts {
+			list = append(list, p.Prompt)
+		}
+		return map[string]interface{}{"prompts": list}, nil
+	case "prompts/get":
+		return c.server.getPrompt(ctx, req.Params)
+	}
+	return nil, &JSONRPCError{Code: codeMethodNotFound, Message: "Method not found: " + req.Method}
+}
+
+// negotiateVersion answers with the requested revision if we speak it,
+// else our latest
+func negotiateVersion(requested string) string {
+	for _, v := range supportedProtocolVersions {
+		if v == requested {
+			return v
+		}
+	}
+	return supportedProtocolVersions[0]
+}
+
+func (c *session) initialize(raw json.RawMessage) (interface{}, error) {
+	var params struct {
+		ProtocolVersion string `json:"protocolVersion"`
+		Capabilities    struct {
+			Elicitation json.RawMessage `json:"elicitation"`
+		} `json:"capabilities"`
+	}
+	if err := decodeParams(raw, &params); err != nil {
+		return nil, err
+	}
+	if params.ProtocolVersion == "" {
+		return nil, invalidParams("missing protocolVersion")
+	}
+
+	version := negotiateVersion(params.ProtocolVersion)
+	c.mu.Lock()
+	c.protocolVersion = version
+	c.elicitation = len(params.Capabilities.Elicitation) > 0 && string(params.Capabilities.Elicitation) != "null"
+	c.mu.Unlock()
+
+	return map[string]interface{}{
+		"protocolVersion": version,
+		"serverInfo": map[string]string{
+			"name":    serverName,
+			"version": serverVersion,
+		},
+		"capabilities": map[string]interface{}{
+			"tools":     map[string]interface{}{"listChanged": false},
+			"resources": map[string]interface{}{"subscribe": true, "listChanged": false},
+			"prompts":   map[string]interface{}{"listChanged": false},
+		},
+	}, nil
+}
+
+func (c *session) callTool(ctx context.Context, raw json.RawMessage, reply sender) (interface{}, error) {
+	var params struct {
+		Name      string                 `json:"name"`
+		Arguments map[string]interface{} `json:"arguments"`
+		Meta      *requestMeta           `json:"_meta,omitempty"`
+	}
+	if err := decodeParams(raw, &params); err != nil {
+		return nil, err
+	}
+	if params.Name == "" {
+		return nil, invalidParams("missing tool name")
+	}
+	tool := findTool(params.Name)
+	if tool == nil {
+		return nil, invalidParams("Unknown tool: " + params.Name)
+	}
+	if params.Arguments == nil {
+		params.Arguments = map[string]interface{}{}
+	}
+	if params.Meta != nil && params.Meta.ProgressToken != nil {
+		ctx = context.WithValue(ctx, progressKey{}, progressNotifier(params.Meta.ProgressToken, reply))
+	}
+
+	s := c.server
+	system, err := s.resolveScope(params.Arguments)
+	if err != nil {
+		return nil, err
+	}
+	if tool.action != "" {
+		if err := s.authorize(tool, system, params.Arguments, c.confirmer(ctx, reply)); err != nil {
+			if rpcErr, ok := err.(*JSONRPCError); ok {
+				return nil, rpcErr
+			}
+			return errorResult(fmt.Errorf("denied by policy: %w", err)), nil
+		}
+	}
+	manager, err := s.manager(system)
+	if err != nil {
+		return errorResult(fmt.Errorf("failed to connect to systemd: %w", err)), nil
+	}
+
+	// The handler may block on systemd; stop waiting for it once cancelled
+	type outcome struct {
+		result *ToolResult
+		err    error
+	}
+	done := make(chan outcome, 1)
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				done <- outcome{err: fmt.Errorf("tool %s failed: %v", params.Name, r)}
+			}
+		}()
+		result, err := tool.handler(ctx, manager, params.Arguments)
+		done <- outcome{result, err}
+	}()
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case o := <-done:
+		if rpcErr, ok := o.err.(*JSONRPCError); ok {
+			return nil, rpcErr
+		}
+		if o.err != nil {
+			// Failures of the operation itself are reported to the model, not as protocol errors
+			return c.compatResult(errorResult(o.err)), nil
+		}
+		return c.compatResult(o.result), nil
+	}
+}
+
+// compatResult serializes structured content into a text block for clients
+// that negotiated a revision without structuredContent.
+func (c *session) compatResult(result *ToolResult) *ToolResult {
+	if result.StructuredContent == nil || c.version() >= structuredProtocolVersion {
+		return result
+	}
+	data, err := json.MarshalIndent(result.StructuredContent, "", "  ")
+	if err == nil {
+		result.Content = append(result.Content, Content{Type: "text", Text: string(data)})
+	}
+	result.StructuredContent = nil
+	return result
+}
+
+// confirmer asks the user of the client, through elicitation, to approve
+// an action the policy wants confirmed. It is nil when the client cannot
+// ask its user.
+func (c *session) confirmer(ctx context.Context, send sender) func(actionRequest) (bool, error) {
+	c.mu.Lock()
+	supported := c.elicitation
+	c.mu.Unlock()
+	if !supported {
+		return nil
+	}
+	return func(req actionRequest) (bool, error) {
+		raw, err := c.request(ctx, "elicitation/create", map[string]interface{}{
+			"message":         fmt.Sprintf("Allow the assistant to %s %s (%s)?", req.Action, req.Unit, req.Scope),
+			"requestedSchema": map[string]interface{}{"type": "object", "properties": map[string]interface{}{}},
+		}, send)
+		if err != nil {
+			return false, err
+		}
+		var result struct {
+			Action string `json:"action"`
+		}
+		if err := json.Unmarshal(raw, &result); err != nil {
+			return false, fmt.Errorf("invalid elicitation result: %w", err)
+		}
+		return result.Action == "accept", nil
+	}
+}
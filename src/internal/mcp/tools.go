@@ -0,0 +1,462 @@
+package mcp
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"svcm/src/internal/core"
+)
+
+// toolHandler runs a tool against the manager selected by the call's scope.
+// A *JSONRPCError return is a protocol error such as bad arguments; any
+// other error becomes an isError result for the model.
+type toolHandler func(ctx context.Context, m core.Manager, args map[string]interface{}) (*ToolResult, error)
+
+type toolDef struct {
+	Tool
+	handler toolHandler
+	// action names the change a mutating tool makes; the policy is
+	// consulted for these before the handler runs
+	action string
+	// nowAction is the job now=true also runs, which the policy must
+	// allow as well
+	nowAction string
+}
+
+var scopeProperty = map[string]interface{}{
+	"type":        "string",
+	"enum":        []string{"user", "system"},
+	"description": "Service manager to use; defaults to the bus svcm was started with",
+}
+
+// schema builds an object input schema that always accepts a scope
+func schema(properties map[string]interface{}, required ...string) map[string]interface{} {
+	props := map[string]interface{}{"scope": scopeProperty}
+	for k, v := range properties {
+		props[k] = v
+	}
+	s := map[string]interface{}{
+		"type":       "object",
+		"properties": props,
+	}
+	if len(required) > 0 {
+		s["required"] = required
+	}
+	return s
+}
+
+var nameProperty = map[string]interface{}{
+	"name": map[string]string{"type": "string", "description": "Unit name; .service is appended if no suffix is given"},
+}
+
+// actionSchema builds the input schema of a mutating tool on a named unit
+func actionSchema(properties map[string]interface{}) map[string]interface{} {
+	props := map[string]interface{}{"name": nameProperty["name"]}
+	for k, v := range properties {
+		props[k] = v
+	}
+	return schema(props, "name")
+}
+
+var tools = []toolDef{
+	{
+		Tool: Tool{
+			Name:        "list_services",
+			Description: "List loaded systemd units; services unless another type is asked for",
+			InputSchemaSchema: schema(map[string]interface{}{
+				"type": map[string]interface{}{
+					"type":        "string",
+					"enum":        append(append([]string{}, core.UnitTypes...), "all"),
+					"description": "Unit type to list (default service)",
+				},
+			}),
+		},
+		handler: listServices,
+	},
+	{
+		Tool: Tool{
+			Name:              "get_service_status",
+			Description:       "Show the state, main PID, unit file and timestamps of a service",
+			InputSchemaSchema: schema(nameProperty, "name"),
+		},
+		handler: serviceStatus,
+	},
+	{
+		Tool: Tool{
+			Name:        "get_service_logs",
+			Description: "Read recent journal entries of a service",
+			InputSchemaSchema: schema(map[string]interface{}{
+				"name":     nameProperty["name"],
+				"lines":    map[string]interface{}{"type": "integer", "description": "Number of newest entries (default 50)"},
+				"since":    map[string]interface{}{"type": "string", "description": "Oldest entry time, e.g. \"1h ago\" or \"2024-01-02 10:00\""},
+				"priority": map[string]interface{}{"type": "string", "description": "Maximum priority, e.g. \"err\" or \"warning\""},
+				"grep":     map[string]interface{}{"type": "string", "description": "Regular expression matched against messages"},
+			}, "name"),
+		},
+		handler: serviceLogs,
+	},
+	{
+		Tool: Tool{
+			Name:              "get_unit_file",
+			Description:       "Show the unit file of a service together with its drop-ins",
+			InputSchemaSchema: schema(nameProperty, "name"),
+		},
+		handler: unitFile,
+	},
+	{
+		Tool: Tool{
+			Name:              "start_service",
+			Description:       "Start a systemd service",
+			InputSchemaSchema: actionSchema(nil),
+		},
+		action:  "start",
+		handler: unitAction("Starting", "started", core.Manager.StartService),
+	},
+	{
+		Tool: Tool{
+			Name:              "stop_service",
+			Description:       "Stop a systemd service",
+			InputSchemaSchema: actionSchema(nil),
+		},
+		action:  "stop",
+		handler: unitAction("Stopping", "stopped", core.Manager.StopService),
+	},
+	{
+		Tool: Tool{
+			Name:              "restart_service",
+			Description:       "Restart a systemd service",
+			InputSchemaSchema: actionSchema(nil),
+		},
+		action:  "restart",
+		handler: unitAction("Restarting", "restarted", core.Manager.RestartService),
+	},
+	{
+		Tool: Tool{
+			Name:        "enable_service",
+			Description: "Enable a service so it starts automatically; with now=true also start it",
+			InputSchemaSchema: actionSchema(map[string]interface{}{
+				"now": map[string]string{"type": "boolean"},
+			}),
+		},
+		action:    "enable",
+		nowAction: "start",
+		handler:   unitFileAction("enabled", core.Manager.EnableService),
+	},
+	{
+		Tool: Tool{
+			Name:        "disable_service",
+			Description: "Disable a service so it no longer starts automatically; with now=true also stop it",
+			InputSchemaSchema: actionSchema(map[string]interface{}{
+				"now": map[string]string{"type": "boolean"},
+			}),
+		},
+		action:    "disable",
+		nowAction: "stop",
+		handler:   unitFileAction("disabled", core.Manager.DisableService),
+	},
+	{
+		Tool: Tool{
+			Name:        "kill_service",
+			Description: "Send a signal to the processes of a unit without stopping it, e.g. SIGHUP to make a daemon reload its configuration",
+			InputSchemaSchema: actionSchema(map[string]interface{}{
+				"signal": map[string]string{"type": "string", "description": "Signal name (SIGHUP, TERM, ...) or number; defaults to SIGTERM"},
+				"who": map[string]interface{}{
+					"type":        "string",
+					"enum":        core.KillWho,
+					"description": "Processes to signal: all (default), main or control",
+				},
+			}),
+		},
+		action:  "kill",
+		handler: killService,
+	},
+}
+
+func findTool(name string) *toolDef {
+	for i := range tools {
+		if tools[i].Name == name {
+			return &tools[i]
+		}
+	}
+	return nil
+}
+
+// stringArg returns a required string argument or an invalid-params error
+func stringArg(args map[string]interface{}, key string) (string, error) {
+	v, ok := args[key]
+	if !ok {
+		return "", invalidParams(fmt.Sprintf("missing required argument %q", key))
+	}
+	s, ok := v.(string)
+	if !ok || s == "" {
+		return "", invalidParams(fmt.Sprintf("argument %q must be a non-empty string", key))
+	}
+	return s, nil
+}
+
+func optionalString(args map[string]interface{}, key string) (string, error) {
+	v, ok := args[key]
+	if !ok || v == nil {
+		return "", nil
+	}
+	s, ok := v.(string)
+	if !ok {
+		return "", invalidParams(fmt.Sprintf("argument %q must be a string", key))
+	}
+	return s, nil
+}
+
+func optionalBool(args map[string]interface{}, key string) (bool, error) {
+	v, ok := args[key]
+	if !ok || v == nil {
+		return false, nil
+	}
+	b, ok := v.(bool)
+	if !ok {
+		return false, invalidParams(fmt.Sprintf("argument %q must be a boolean", key))
+	}
+	return b, nil
+}
+
+func optionalInt(args map[string]interface{}, key string, def int) (int, error) {
+	v, ok := args[key]
+	if !ok || v == nil {
+		return def, nil
+	}
+	// JSON numbers decode as float64
+	f, ok := v.(float64)
+	if !ok || f != float64(int(f)) || f < 0 {
+		return 0, invalidParams(fmt.Sprintf("argument %q must be a non-negative integer", key))
+	}
+	return int(f), nil
+}
+
+func listServices(ctx context.Context, m core.Manager, args map[string]interface{}) (*ToolResult, error) {
+	unitType, err := optionalString(args, "type")
+	if err != nil {
+		return nil, err
+	}
+	var types []string
+	switch unitType {
+	case "":
+		types = []string{"service"}
+	case "all":
+	default:
+		types = []string{unitType}
+	}
+	list, err := m.ListUnits(ctx, types...)
+	if err != nil {
+		return nil, err
+	}
+	var txt strings.Builder
+	for _, s := range list {
+		fmt.Fprintf(&txt, "%-30s %s %s\n", s.Name, s.ActiveState, s.Description)
+	}
+	return structuredResult(txt.String(), map[string]interface{}{"services": list}), nil
+}
+
+func serviceStatus(ctx context.Context, m core.Manager, args map[string]interface{}) (*ToolResult, error) {
+	name, err := stringArg(args, "name")
+	if err != nil {
+		return nil, err
+	}
+	d, err := m.GetServiceDetails(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	return structuredResult(formatStatus(d), d), nil
+}
+
+func formatStatus(d *core.ServiceDetails) string {
+	var txt strings.Builder
+	fmt.Fprintf(&txt, "%s - %s\n", d.Name, d.Description)
+	fmt.Fprintf(&txt, "Loaded: %s (%s; %s)\n", d.LoadState, d.FragmentPath, d.UnitFileState)
+	fmt.Fprintf(&txt, "Active: %s (%s)\n", d.ActiveState, d.SubState)
+	if d.MainPID != 0 {
+		fmt.Fprintf(&txt, "Main PID: %d\n", d.MainPID)
+	}
+	if d.TasksCurrent > 0 {
+		fmt.Fprintf(&txt, "Tasks: %d; Memory: %s; CPU: %s\n", d.TasksCurrent, core.FormatBytes(d.MemoryCurrent), core.FormatDuration(time.Duration(d.CPUUsageNSec)))
+	}
+	if d.ActiveEnterTimestamp > 0 {
+		fmt.Fprintf(&txt, "Active since: %s\n", time.UnixMicro(int64(d.ActiveEnterTimestamp)).Format(time.RFC3339))
+	}
+	switch {
+	case d.Socket != nil:
+		for _, l := range d.Socket.Listen {
+			fmt.Fprintf(&txt, "Listen: %s (%s)\n", l.Value, l.Type)
+		}
+		fmt.Fprintf(&txt, "Connections: %d accepted, %d active\n", d.Socket.Accepted, d.Socket.Connections)
+		fmt.Fprintf(&txt, "Triggers: %s\n", strings.Join(d.Socket.Triggers, " "))
+	case d.Path != nil:
+		for _, c := range d.Path.Conditions {
+			fmt.Fprintf(&txt, "%s: %s\n", c.Type, c.Value)
+		}
+		fmt.Fprintf(&txt, "Triggers: %s\n", strings.Join(d.Path.Triggers, " "))
+	case d.Mount != nil:
+		fmt.Fprintf(&txt, "Where: %s\nWhat: %s (%s)\nOptions: %s\n", d.Mount.Where, d.Mount.What, d.Mount.FSType, d.Mount.Options)
+	case d.Target != nil:
+		fmt.Fprintf(&txt, "Wants: %s\nRequires: %s\nWanted by: %s\n",
+			strings.Join(d.Target.Wants, " "), strings.Join(d.Target.Requires, " "), strings.Join(d.Target.WantedBy, " "))
+	}
+	return txt.String()
+}
+
+func serviceLogs(ctx context.Context, m core.Manager, args map[string]interface{}) (*ToolResult, error) {
+	name, err := stringArg(args, "name")
+	if err != nil {
+		return nil, err
+	}
+	q := core.LogQuery{Unit: name}
+	if q.Lines, err = optionalInt(args, "lines", 50); err != nil {
+		return nil, err
+	}
+	if q.Priority, err = optionalString(args, "priority"); err != nil {
+		return nil, err
+	}
+	if q.Grep, err = optionalString(args, "grep"); err != nil {
+		return nil, err
+	}
+	since, err := optionalString(args, "since")
+	if err != nil {
+		return nil, err
+	}
+	if since != "" {
+		if q.Since, err = core.ParseLogTime(since, time.Now()); err != nil {
+			return nil, invalidParams(err.Error())
+		}
+	}
+
+	entries, err := m.GetLogs(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+	txt := formatLogs(entries)
+	// Drop the raw field maps, they are large and rarely useful to a model
+	for i := range entries {
+		entries[i].Fields = nil
+	}
+	return structuredResult(txt, map[string]interface{}{"entries": entries}), nil
+}
+
+func formatLogs(entries []core.LogEntry) string {
+	var txt strings.Builder
+	for _, e := range entries {
+		txt.WriteString(core.FormatLogEntry(e))
+		txt.WriteByte('\n')
+	}
+	if len(entries) == 0 {
+		txt.WriteString("No journal entries found\n")
+	}
+	return txt.String()
+}
+
+func unitFile(ctx context.Context, m core.Manager, args map[string]interface{}) (*ToolResult, error) {
+	name, err := stringArg(args, "name")
+	if err != nil {
+		return nil, err
+	}
+	f, err := m.GetUnitFile(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	return structuredResult(formatUnitFile(f), f), nil
+}
+
+func formatUnitFile(f *core.UnitFile) string {
+	var txt strings.Builder
+	if f.Fragment.Path != "" {
+		fmt.Fprintf(&txt, "# %s\n%s\n", f.Fragment.Path, f.Fragment.Content)
+	}
+	for _, d := range f.DropIns {
+		fmt.Fprintf(&txt, "# %s\n%s\n", d.Path, d.Content)
+	}
+	return txt.String()
+}
+
+func unitAction(verb, past string, action func(core.Manager, context.Context, string) error) toolHandler {
+	return func(ctx context.Context, m core.Manager, args map[string]interface{}) (*ToolResult, error) {
+		name, err := stringArg(args, "name")
+		if err != nil {
+			return nil, err
+		}
+
+		reportProgress(ctx, 0, 1, fmt.Sprintf("%s %s", verb, name))
+		if err := action(m, ctx, name); err != nil {
+			return nil, err
+		}
+		reportProgress(ctx, 1, 1, fmt.Sprintf("Service %s %s", name, past))
+		return structuredResult(fmt.Sprintf("Service %s %s", name, past), map[string]interface{}{
+			"unit":   name,
+			"result": past,
+		}), nil
+	}
+}
+
+func unitFileAction(past string, action func(core.Manager, context.Context, string, bool) ([]core.UnitFileChange, error)) toolHandler {
+	return func(ctx context.Context, m core.Manager, args map[string]interface{}) (*ToolResult, error) {
+		name, err := stringArg(args, "name")
+		if err != nil {
+			return nil, err
+		}
+		now, err := optionalBool(args, "now")
+		if err != nil {
+			return nil, err
+		}
+
+		changes, err := action(m, ctx, name, now)
+		if err != nil {
+			return nil, err
+		}
+		var txt strings.Builder
+		fmt.Fprintf(&txt, "Service %s %s\n", name, past)
+		for _, c := range changes {
+			fmt.Fprintf(&txt, "%s %s -> %s\n", c.Type, c.Filename, c.Destination)
+		}
+		return structuredResult(txt.String(), map[string]interface{}{
+			"unit":    name,
+			"result":  past,
+			"changes": changes,
+		}), nil
+	}
+}
+
+func killService(ctx context.Context, m core.Manager, args map[string]interface{}) (*ToolResult, error) {
+	name, err := stringArg(args, "name")
+	if err != nil {
+		return nil, err
+	}
+	signal, err := optionalString(args, "signal")
+	if err != nil {
+		return nil, err
+	}
+	who, err := optionalString(args, "who")
+	if err != nil {
+		return nil, err
+	}
+	if signal == "" {
+		signal = "SIGTERM"
+	}
+	if who == "" {
+		who = "all"
+	}
+	sig, err := core.ParseSignal(signal)
+	if err != nil {
+		return nil, invalidParams(err.Error())
+	}
+	if err := core.CheckKillWho(who); err != nil {
+		return nil, invalidParams(err.Error())
+	}
+
+	if err := m.KillService(ctx, name, who, sig); err != nil {
+		return nil, err
+	}
+	txt := fmt.Sprintf("Sent %s to %s processes of %s", core.SignalName(sig), who, core.UnitName(name))
+	return structuredResult(txt, map[string]interface{}{
+		"unit":   core.UnitName(name),
+		"signal": core.SignalName(sig),
+		"who":    who,
+	}), nil
+}
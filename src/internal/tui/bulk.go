@@ -0,0 +1,141 @@
+package tui
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"svcm/src/internal/core"
+
+	"github.com/rivo/tview"
+)
+
+// bulkWorkers bounds the jobs a bulk action runs at once
+const bulkWorkers = 4
+
+// toggleMark marks or unmarks the unit on row and moves to the next row
+func (a *App) toggleMark(row int) {
+	if row <= 0 || row >= a.table.GetRowCount() {
+		return
+	}
+	name := a.table.GetCell(row, 0).Text
+	if a.marked[name] {
+		delete(a.marked, name)
+	} else {
+		a.marked[name] = true
+	}
+	a.tviewApp.SetRoot(a.layout(), true)
+	a.renderTable()
+	if row+1 < a.table.GetRowCount() {
+		a.table.Select(row+1, 0)
+	}
+}
+
+// markAll marks every shown unit, or clears the marks if they all are
+func (a *App) markAll() {
+	var shown []string
+	all := true
+	for row := 1; row < a.table.GetRowCount(); row++ {
+		name := a.table.GetCell(row, 0).Text
+		shown = append(shown, name)
+		all = all && a.marked[name]
+	}
+	for _, name := range shown {
+		if all {
+			delete(a.marked, name)
+		} else {
+			a.marked[name] = true
+		}
+	}
+	a.tviewApp.SetRoot(a.layout(), true)
+	a.renderTable()
+}
+
+// clearMarks unmarks all units
+func (a *App) clearMarks() {
+	if len(a.marked) == 0 {
+		return
+	}
+	a.marked = map[string]bool{}
+	a.tviewApp.SetRoot(a.layout(), true)
+	a.renderTable()
+}
+
+// act runs action on the marked units, or on name when none are marked.
+// Marks on units the table no longer shows, such as ones hidden by the
+// filter, are dropped rather than acted on unseen.
+func (a *App) act(verb, name string, action func(context.Context, string) error) {
+	if len(a.marked) == 0 {
+		if name != "" {
+			a.performAction(verb, name, action)
+		}
+		return
+	}
+	var names []string
+	for row := 1; row < a.table.GetRowCount(); row++ {
+		if n := a.table.GetCell(row, 0).Text; a.marked[n] {
+			names = append(names, n)
+		}
+	}
+	if len(names) != len(a.marked) {
+		a.marked = map[string]bool{}
+		for _, n := range names {
+			a.marked[n] = true
+		}
+		a.tviewApp.SetRoot(a.layout(), true)
+		a.renderTable()
+	}
+	if len(names) > 0 {
+		a.performBulk(verb, names, action)
+	}
+}
+
+// performBulk runs action for every unit in the background, showing
+// progress and then a summary. Cancel stops waiting and skips the jobs not
+// started yet.
+func (a *App) performBulk(actionVerb string, names []string, action func(context.Context, string) error) {
+	ctx, cancel := context.WithCancel(a.jobContext())
+	modal := tview.NewModal().
+		SetText(fmt.Sprintf("%s %d units...", actionVerb, len(names))).
+		AddButtons([]string{"Cancel"}).
+		SetDoneFunc(func(buttonIndex int, buttonLabel string) {
+			cancel()
+			a.tviewApp.SetRoot(a.layout(), true)
+		})
+
+	go func() {
+		results := core.RunBulk(ctx, names, bulkWorkers, action, func(finished int, r core.BulkResult) {
+			a.tviewApp.QueueUpdateDraw(func() {
+				if ctx.Err() == nil {
+					modal.SetText(fmt.Sprintf("%s %d units... %d/%d done\n%s: %s", actionVerb, len(names), finished, len(names), r.Unit, r.Result))
+				}
+			})
+		})
+		a.tviewApp.QueueUpdateDraw(func() {
+			a.refreshServices()
+			if ctx.Err() != nil {
+				// Canceled, the modal is already gone
+				return
+			}
+			cancel()
+
+			var summary strings.Builder
+			failed := 0
+			for _, r := range results {
+				if r.Err != nil {
+					failed++
+					fmt.Fprintf(&summary, "\n%s: %s", r.Unit, r.Error)
+				}
+			}
+			if failed == 0 {
+				a.tviewApp.SetRoot(a.layout(), true)
+				return
+			}
+			modal.SetText(fmt.Sprintf("%d of %d units failed:%s", failed, len(names), summary.String())).
+				ClearButtons().
+				AddButtons([]string{"OK"})
+		})
+	}()
+
+	a.tviewApp.SetRoot(modal, false)
+}
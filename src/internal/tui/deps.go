@@ -0,0 +1,100 @@
+package tui
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"svcm/src/internal/core"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+// depEntry is the reference kept on each tree node; children are fetched
+// the first time a node is expanded
+type depEntry struct {
+	name   string
+	loaded bool
+}
+
+// showDependencies opens a collapsible dependency tree of a unit. Enter
+// expands or collapses a node, 'r' flips to reverse dependencies and Esc
+// closes the view.
+func (a *App) showDependencies(name string, reverse bool) {
+	tree := tview.NewTreeView()
+	title := " Dependencies of %s (Enter expand, r reverse, Esc close) "
+	if reverse {
+		title = " Units depending on %s (Enter expand, r forward, Esc close) "
+	}
+	tree.SetBorder(true).SetTitle(fmt.Sprintf(title, name))
+
+	status := tview.NewTextView().SetDynamicColors(true)
+	status.SetBackgroundColor(tcell.ColorDarkGray)
+
+	// load adds the direct dependencies of a node as collapsed children
+	load := func(node *tview.TreeNode) {
+		entry := node.GetReference().(*depEntry)
+		if entry.loaded {
+			return
+		}
+		entry.loaded = true
+		dep, err := a.manager.Dependencies(context.Background(), entry.name, core.DependencyQuery{Reverse: reverse, Depth: 1})
+		if err != nil {
+			status.SetText(fmt.Sprintf("[red]%s", tview.Escape(err.Error())))
+			return
+		}
+		for _, c := range dep.Children {
+			child := tview.NewTreeNode(dependencyLabel(c)).
+				SetReference(&depEntry{name: c.Name}).
+				SetColor(stateColor(c.ActiveState)).
+				SetExpanded(false)
+			node.AddChild(child)
+		}
+		if len(dep.Children) == 0 {
+			status.SetText(entry.name + " has no dependencies of these kinds")
+		}
+	}
+
+	root := tview.NewTreeNode(core.UnitName(name)).
+		SetReference(&depEntry{name: name}).
+		SetColor(tcell.ColorYellow)
+	tree.SetRoot(root).SetCurrentNode(root)
+	load(root)
+
+	tree.SetSelectedFunc(func(node *tview.TreeNode) {
+		status.SetText("")
+		load(node)
+		node.SetExpanded(!node.IsExpanded())
+	})
+	tree.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		switch {
+		case event.Key() == tcell.KeyEscape || event.Rune() == 'q':
+			a.tviewApp.SetRoot(a.layout(), true)
+			return nil
+		case event.Rune() == 'r':
+			a.showDependencies(name, !reverse)
+			return nil
+		}
+		return event
+	})
+
+	flex := tview.NewFlex().SetDirection(tview.FlexRow).
+		AddItem(tree, 0, 1, true).
+		AddItem(status, 1, 1, false)
+	a.tviewApp.SetRoot(flex, true)
+}
+
+func dependencyLabel(n *core.DependencyNode) string {
+	return tview.Escape(fmt.Sprintf("%s (%s) [%s]", n.Name, n.ActiveState, strings.Join(n.Kinds, ",")))
+}
+
+func stateColor(state string) tcell.Color {
+	switch state {
+	case "active":
+		return tcell.ColorGreen
+	case "failed":
+		return tcell.ColorRed
+	}
+	return tcell.ColorGray
+}
@@ -0,0 +1,187 @@
+package tui
+
+import (
+	"context"
+	"fmt"
+	"syscall"
+	"time"
+
+	"svcm/src/internal/core"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+// showProcesses lists the processes in a unit's control group as a tree,
+// refreshed every two seconds. Enter or 's' picks a signal to send to the
+// selected process, Esc closes.
+func (a *App) showProcesses(name string) {
+	table := tview.NewTable().
+		SetBorders(false).
+		SetSelectable(true, false).
+		SetFixed(1, 1)
+	table.SetBorder(true).SetTitle(fmt.Sprintf(" Processes of %s (Enter/s signal, Esc close) ", name))
+
+	status := tview.NewTextView().SetDynamicColors(true)
+	status.SetBackgroundColor(tcell.ColorDarkGray)
+
+	// pids maps table rows to processes
+	var pids []uint32
+
+	reload := func() {
+		procs, err := a.manager.GetProcesses(context.Background(), name)
+		if err != nil {
+			status.SetText(fmt.Sprintf("[red]Failed to list processes: %s", tview.Escape(err.Error())))
+			return
+		}
+
+		selected := uint32(0)
+		if row, _ := table.GetSelection(); row > 0 && row <= len(pids) {
+			selected = pids[row-1]
+		}
+		table.Clear()
+		pids = pids[:0]
+
+		headers := []string{"PID", "USER", "RSS", "CPU", "COMMAND"}
+		for c, h := range headers {
+			table.SetCell(0, c, tview.NewTableCell(h).
+				SetTextColor(tcell.ColorYellow).
+				SetSelectable(false).
+				SetAttributes(tcell.AttrBold))
+		}
+		var add func(nodes []*core.ProcessNode, indent string)
+		add = func(nodes []*core.ProcessNode, indent string) {
+			for i, n := range nodes {
+				branch, next := "├─", "│ "
+				if i == len(nodes)-1 {
+					branch, next = "└─", "  "
+				}
+				row := len(pids) + 1
+				pids = append(pids, n.PID)
+				table.SetCell(row, 0, tview.NewTableCell(fmt.Sprint(n.PID)).SetAlign(tview.AlignRight))
+				table.SetCell(row, 1, tview.NewTableCell(n.User))
+				table.SetCell(row, 2, tview.NewTableCell(core.FormatBytes(n.RSSBytes)).SetAlign(tview.AlignRight))
+				table.SetCell(row, 3, tview.NewTableCell(core.FormatDuration(time.Duration(n.CPUTimeNSec))).SetAlign(tview.AlignRight))
+				table.SetCell(row, 4, tview.NewTableCell(indent+branch+n.Command))
+				if n.PID == selected {
+					table.Select(row, 0)
+				}
+				add(n.Children, indent+next)
+			}
+		}
+		add(core.BuildProcessTree(procs), "")
+		if len(pids) == 0 {
+			status.SetText("No processes running")
+		} else if selected == 0 {
+			table.Select(1, 0)
+		}
+	}
+
+	stop := make(chan struct{})
+	closeView := func() {
+		close(stop)
+		a.tviewApp.SetRoot(a.layout(), true)
+	}
+	go func() {
+		ticker := time.NewTicker(2 * time.Second)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				a.tviewApp.QueueUpdateDraw(reload)
+			}
+		}
+	}()
+
+	flex := tview.NewFlex().SetDirection(tview.FlexRow).
+		AddItem(table, 0, 1, true).
+		AddItem(status, 1, 1, false)
+
+	signalSelected := func() {
+		row, _ := table.GetSelection()
+		if row < 1 || row > len(pids) {
+			return
+		}
+		pid := pids[row-1]
+		a.pickSignal(fmt.Sprintf("Signal for %d", pid), flex, nil, func(sig syscall.Signal) {
+			if err := a.manager.SignalProcess(context.Background(), name, pid, sig); err != nil {
+				status.SetText(fmt.Sprintf("[red]Error: %s", tview.Escape(err.Error())))
+				return
+			}
+			status.SetText(fmt.Sprintf("[green]Sent %s to %d", core.SignalName(sig), pid))
+			reload()
+		})
+	}
+
+	table.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		switch {
+		case event.Key() == tcell.KeyEscape || event.Rune() == 'q':
+			closeView()
+			return nil
+		case event.Key() == tcell.KeyEnter || event.Rune() == 's':
+			signalSelected()
+			return nil
+		}
+		return event
+	})
+
+	reload()
+	a.tviewApp.SetRoot(flex, true)
+}
+
+// pickSignal shows a centered list of signals over back and calls send
+// with the chosen one; Esc returns without sending. With who set, Tab
+// cycles it through core.KillWho.
+func (a *App) pickSignal(title string, back tview.Primitive, who *string, send func(sig syscall.Signal)) {
+	list := tview.NewList().ShowSecondaryText(false)
+	list.SetBorder(true)
+	setTitle := func() {
+		if who == nil {
+			list.SetTitle(" " + title + " ")
+		} else {
+			list.SetTitle(fmt.Sprintf(" %s: %s (Tab) ", title, *who))
+		}
+	}
+	setTitle()
+	if who != nil {
+		list.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+			if event.Key() != tcell.KeyTab {
+				return event
+			}
+			for i, w := range core.KillWho {
+				if w == *who {
+					*who = core.KillWho[(i+1)%len(core.KillWho)]
+					break
+				}
+			}
+			setTitle()
+			return nil
+		})
+	}
+	for i, s := range core.Signals {
+		sig := s.Signal
+		list.AddItem(s.Name, "", rune('1'+i), func() {
+			a.tviewApp.SetRoot(back, true)
+			send(sig)
+		})
+	}
+	list.SetDoneFunc(func() {
+		a.tviewApp.SetRoot(back, true)
+	})
+
+	width := len(title) + 20
+	if width < 24 {
+		width = 24
+	}
+	column := tview.NewFlex().SetDirection(tview.FlexRow).
+		AddItem(nil, 0, 1, false).
+		AddItem(list, len(core.Signals)+2, 0, true).
+		AddItem(nil, 0, 1, false)
+	centered := tview.NewFlex().
+		AddItem(nil, 0, 1, false).
+		AddItem(column, width, 0, true).
+		AddItem(nil, 0, 1, false)
+	a.tviewApp.SetRoot(centered, true)
+}
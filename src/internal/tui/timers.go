@@ -0,0 +1,159 @@
+package tui
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"svcm/src/internal/core"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+// timerReloadInterval is how often the timer list is fetched again; the
+// countdowns themselves tick every second from the cached list
+const timerReloadInterval = 30 * time.Second
+
+// showTimers opens the timer view with live countdowns to the next run.
+// 't' or Enter triggers the selected timer's unit now, Esc closes.
+func (a *App) showTimers() {
+	table := tview.NewTable().
+		SetBorders(false).
+		SetSelectable(true, false).
+		SetFixed(1, 1)
+	table.SetBorder(true).SetTitle(" Timers (t/Enter run now, Esc close) ")
+
+	status := tview.NewTextView().SetDynamicColors(true)
+	status.SetBackgroundColor(tcell.ColorDarkGray)
+
+	var timers []core.TimerUnit
+	var loaded time.Time
+
+	render := func() {
+		now := time.Now()
+		headers := []string{"TIMER", "NEXT", "LEFT", "LAST", "PASSED", "ACTIVATES"}
+		for c, h := range headers {
+			table.SetCell(0, c, tview.NewTableCell(h).
+				SetTextColor(tcell.ColorYellow).
+				SetSelectable(false).
+				SetAttributes(tcell.AttrBold))
+		}
+		for i, t := range timers {
+			row := i + 1
+			next, left := timerCells(t.NextElapse(), now)
+			last, passed := timerCells(t.LastTrigger(), now)
+			color := tcell.ColorGreen
+			if t.ActiveState != "active" {
+				color = tcell.ColorGray
+			}
+			table.SetCell(row, 0, tview.NewTableCell(t.Name).SetTextColor(color))
+			table.SetCell(row, 1, tview.NewTableCell(next))
+			table.SetCell(row, 2, tview.NewTableCell(left).SetTextColor(tcell.ColorAqua))
+			table.SetCell(row, 3, tview.NewTableCell(last))
+			table.SetCell(row, 4, tview.NewTableCell(passed))
+			table.SetCell(row, 5, tview.NewTableCell(t.Unit))
+		}
+		for row := table.GetRowCount() - 1; row > len(timers); row-- {
+			table.RemoveRow(row)
+		}
+	}
+
+	reload := func() {
+		list, err := a.manager.ListTimers(context.Background())
+		if err != nil {
+			status.SetText(fmt.Sprintf("[red]Failed to list timers: %s", tview.Escape(err.Error())))
+			return
+		}
+		timers = list
+		loaded = time.Now()
+		render()
+	}
+
+	// due reports whether a countdown ran out, so the new schedule is fetched
+	due := func() bool {
+		now := time.Now()
+		for _, t := range timers {
+			if next := t.NextElapse(); !next.IsZero() && next.Before(now) && next.After(loaded) {
+				return true
+			}
+		}
+		return now.Sub(loaded) >= timerReloadInterval
+	}
+
+	stop := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(time.Second)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				a.tviewApp.QueueUpdateDraw(func() {
+					if due() {
+						reload()
+					} else {
+						render()
+					}
+				})
+			}
+		}
+	}()
+
+	trigger := func() {
+		row, _ := table.GetSelection()
+		if row < 1 || row > len(timers) {
+			return
+		}
+		name := timers[row-1].Name
+		status.SetText(fmt.Sprintf("Triggering %s...", name))
+		go func() {
+			unit, err := a.manager.TriggerTimer(a.jobContext(), name)
+			a.tviewApp.QueueUpdateDraw(func() {
+				if err != nil {
+					status.SetText(fmt.Sprintf("[red]Error: %s", tview.Escape(err.Error())))
+					return
+				}
+				status.SetText(fmt.Sprintf("[green]Started %s", unit))
+				reload()
+			})
+		}()
+	}
+
+	table.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		switch {
+		case event.Key() == tcell.KeyEscape || event.Rune() == 'q':
+			close(stop)
+			a.tviewApp.SetRoot(a.layout(), true)
+			return nil
+		case event.Key() == tcell.KeyEnter || event.Rune() == 't':
+			trigger()
+			return nil
+		}
+		return event
+	})
+
+	reload()
+	if len(timers) > 0 {
+		table.Select(1, 0)
+	}
+
+	flex := tview.NewFlex().SetDirection(tview.FlexRow).
+		AddItem(table, 0, 1, true).
+		AddItem(status, 1, 1, false)
+	a.tviewApp.SetRoot(flex, true)
+}
+
+// timerCells formats a timer timestamp and the countdown to it, or "-"
+// when the time is unset
+func timerCells(t, now time.Time) (string, string) {
+	if t.IsZero() {
+		return "-", "-"
+	}
+	d := t.Sub(now).Truncate(time.Second)
+	if d < 0 {
+		return t.Format("Mon 15:04:05"), core.FormatDuration(d) + " ago"
+	}
+	return t.Format("Mon 15:04:05"), core.FormatDuration(d) + " left"
+}
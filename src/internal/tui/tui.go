@@ -1,9 +1,13 @@
 package tui
 
 import (
+	"context"
 	"fmt"
+	"os"
 	"os/exec"
+	"sort"
 	"strings"
+	"syscall"
 	"time"
 
 	"svcm/src/internal/core"
@@ -12,62 +16,261 @@ import (
 	"github.com/rivo/tview"
 )
 
+// refreshDelay is how long events that need the unit list fetched again
+// are gathered before fetching it
+const refreshDelay = time.Second
+
 type App struct {
 	tviewApp    *tview.Application
 	table       *tview.Table
 	infoBox     *tview.TextView
 	searchField *tview.InputField
-	manager     *core.SystemdManager
-	services    []core.ServiceUnit
-	filter      string
-	searchMode  bool
-	privileged  bool
+	manager     core.Manager
+	// backend opens the manager of the other scope when toggling
+	// privileged mode; nil disables the toggle
+	backend  core.Backend
+	services []core.ServiceUnit
+	unitType string
+	filter   string
+	// transientOnly hides units not created at runtime
+	transientOnly bool
+	// usage holds the latest resource samples, sortBy orders the table by
+	// "name", "cpu" or "mem"
+	usage      map[string]core.UsageSample
+	sortBy     string
+	searchMode bool
+	privileged bool
+	jobMode    string
+	stopWatch  chan struct{}
+	// refreshQueued is set while a refresh asked for by events is pending
+	refreshQueued bool
+	// marked units are acted on together by the job keys
+	marked map[string]bool
+	// group, when set, shows only the units of that group of config
+	config *core.Config
+	group  string
 }
 
-func Run(systemMode bool) error {
-	manager, err := core.NewSystemdManager(systemMode)
+// Run starts the TUI on the manager backend opens; jobMode is the mode
+// start, stop and the other job keys queue their jobs with, and the groups
+// of config can be shown on their own
+func Run(backend core.Backend, systemMode bool, jobMode string, config *core.Config) error {
+	manager, err := backend(systemMode)
+	if err != nil {
+		return err
+	}
+	app, err := New(manager, backend, systemMode, jobMode, config)
 	if err != nil {
+		manager.Close()
 		return err
 	}
-	// We don't defer manager.Close() here because tview takes over the UI loop.
-	// We should close it on exit.
+	return app.Run()
+}
 
-	app := &App{
+// New builds the TUI around manager, which Run closes on exit. systemMode
+// tells which scope manager belongs to.
+func New(manager core.Manager, backend core.Backend, systemMode bool, jobMode string, config *core.Config) (*App, error) {
+	if err := core.CheckJobMode(jobMode); err != nil {
+		return nil, err
+	}
+	return &App{
 		tviewApp:    tview.NewApplication(),
 		table:       tview.NewTable(),
 		infoBox:     tview.NewTextView(),
 		searchField: tview.NewInputField(),
 		manager:     manager,
+		backend:     backend,
+		unitType:    "service",
 		privileged:  systemMode,
+		jobMode:     jobMode,
+		sortBy:      "name",
+		marked:      map[string]bool{},
+		config:      config,
+	}, nil
+}
+
+// Run shows the TUI until the user quits
+func (a *App) Run() error {
+	a.refreshServices()
+	a.watch()
+
+	if err := a.tviewApp.SetRoot(a.layout(), true).EnableMouse(true).Run(); err != nil {
+		return err
 	}
 
-	return app.run()
+	// Cleanup
+	close(a.stopWatch)
+	a.manager.Close()
+	return nil
 }
 
-func (a *App) run() error {
-	a.refreshServices()
+// watch keeps the table in sync with systemd. Rows are patched from the
+// D-Bus signal stream; polling is only used when subscribing fails or the
+// stream dies.
+func (a *App) watch() {
+	stop := make(chan struct{})
+	a.stopWatch = stop
+	go a.sampleUsage(stop, a.manager)
+
+	sub, err := a.manager.Subscribe(context.Background())
+	if err != nil {
+		go a.poll(stop)
+		return
+	}
 
-	// Auto refresh every 2 seconds
 	go func() {
-		ticker := time.NewTicker(2 * time.Second)
-		defer ticker.Stop()
+		defer sub.Close()
 		for {
 			select {
-			case <-ticker.C:
+			case <-stop:
+				return
+			case ev, ok := <-sub.Events:
+				if !ok {
+					a.poll(stop)
+					return
+				}
 				a.tviewApp.QueueUpdateDraw(func() {
-					a.refreshServices()
+					a.applyEvent(ev)
 				})
 			}
 		}
 	}()
+}
 
-	if err := a.tviewApp.SetRoot(a.layout(), true).EnableMouse(true).Run(); err != nil {
-		return err
+// sampleUsage keeps the CPU% and MEM columns current. CPU% is computed
+// from two samples of the cumulative CPU time, so it shows up after the
+// first interval.
+func (a *App) sampleUsage(stop <-chan struct{}, manager core.Manager) {
+	tracker := core.NewUsageTracker()
+	ticker := time.NewTicker(2 * time.Second)
+	defer ticker.Stop()
+	for {
+		// The unit list belongs to the UI goroutine
+		names := make(chan []string, 1)
+		a.tviewApp.QueueUpdate(func() {
+			names <- a.activeUnits()
+		})
+		var active []string
+		select {
+		case <-stop:
+			return
+		case active = <-names:
+		}
+
+		if usage, err := manager.GetUsage(context.Background(), active); err == nil {
+			samples := tracker.Update(usage, time.Now())
+			a.tviewApp.QueueUpdateDraw(func() {
+				select {
+				case <-stop:
+					// Samples of a manager that was switched away from
+					return
+				default:
+				}
+				a.usage = samples
+				a.renderTable()
+			})
+		}
+
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
 	}
+}
 
-	// Cleanup
-	a.manager.Close()
-	return nil
+// activeUnits lists the running units of the current tab, the only ones
+// with usage worth sampling
+func (a *App) activeUnits() []string {
+	var names []string
+	for _, s := range a.services {
+		if s.ActiveState == "active" || s.ActiveState == "reloading" || s.ActiveState == "deactivating" {
+			names = append(names, s.Name)
+		}
+	}
+	return names
+}
+
+func (a *App) poll(stop <-chan struct{}) {
+	ticker := time.NewTicker(2 * time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			a.tviewApp.QueueUpdateDraw(func() {
+				a.refreshServices()
+			})
+		}
+	}
+}
+
+func (a *App) applyEvent(ev core.UnitEvent) {
+	if ev.Unit.Type != a.unitType {
+		return
+	}
+
+	idx := -1
+	for i, s := range a.services {
+		if s.Name == ev.Unit.Name {
+			idx = i
+			break
+		}
+	}
+
+	switch ev.Kind {
+	case core.UnitAdded:
+		// The signal only carries the name, fetch the full state
+		if idx < 0 {
+			a.queueRefresh()
+		}
+	case core.UnitRemoved:
+		if idx >= 0 {
+			a.services = append(a.services[:idx], a.services[idx+1:]...)
+			a.renderTable()
+		}
+	case core.UnitChanged:
+		if idx < 0 {
+			a.queueRefresh()
+			return
+		}
+		s := &a.services[idx]
+		if ev.Unit.Description != "" {
+			s.Description = ev.Unit.Description
+		}
+		if ev.Unit.LoadState != "" {
+			s.LoadState = ev.Unit.LoadState
+		}
+		if ev.Unit.ActiveState != "" {
+			s.ActiveState = ev.Unit.ActiveState
+		}
+		if ev.Unit.SubState != "" {
+			s.SubState = ev.Unit.SubState
+		}
+		for row := 1; row < a.table.GetRowCount(); row++ {
+			if a.table.GetCell(row, 0).Text == s.Name {
+				a.setRow(row, *s)
+				break
+			}
+		}
+	}
+}
+
+// queueRefresh lists the units again refreshDelay after the first event
+// asking for it, so a burst of them, as during a daemon-reload, costs a
+// single listing
+func (a *App) queueRefresh() {
+	if a.refreshQueued {
+		return
+	}
+	a.refreshQueued = true
+	time.AfterFunc(refreshDelay, func() {
+		a.tviewApp.QueueUpdateDraw(func() {
+			a.refreshQueued = false
+			a.refreshServices()
+		})
+	})
 }
 
 func (a *App) layout() tview.Primitive {
@@ -82,9 +285,35 @@ func (a *App) layout() tview.Primitive {
 	header := tview.NewTextView().
 		SetTextAlign(tview.AlignCenter).
 		SetText(fmt.Sprintf("svcm - %s", modeStatus))
+	if a.jobMode != "replace" {
+		header.SetText(fmt.Sprintf("svcm - %s - job mode %s", modeStatus, a.jobMode))
+	}
 	header.SetBackgroundColor(headerColor)
 	header.SetTextColor(tcell.ColorWhite)
 
+	// One tab per unit type, switched with Tab/Shift-Tab
+	var tabText strings.Builder
+	for _, t := range core.UnitTypes {
+		label := strings.ToUpper(t[:1]) + t[1:] + "s"
+		if t == a.unitType {
+			fmt.Fprintf(&tabText, "[black:yellow] %s [-:-] ", label)
+		} else {
+			fmt.Fprintf(&tabText, " %s  ", label)
+		}
+	}
+	if a.transientOnly {
+		tabText.WriteString(" [fuchsia](transient only)[-]")
+	}
+	if a.group != "" {
+		fmt.Fprintf(&tabText, " [aqua](group %s)[-]", a.group)
+	}
+	if len(a.marked) > 0 {
+		fmt.Fprintf(&tabText, " [aqua](%d marked, actions apply to them, Esc clears)[-]", len(a.marked))
+	}
+	tabs := tview.NewTextView().
+		SetDynamicColors(true).
+		SetText(tabText.String())
+
 	// Table styling
 	a.table.SetBorders(false).
 		SetSelectable(true, false).
@@ -93,7 +322,7 @@ func (a *App) layout() tview.Primitive {
 	// Footer/Help
 	footer := tview.NewTextView().
 		SetDynamicColors(true).
-		SetText("[yellow]s[white]tart [yellow]x[white]stop [yellow]r[white]estart [yellow]l[white]ogs [yellow]/[white]filter [yellow]P[white]riv-toggle [yellow]q[white]uit")
+		SetText("[yellow]s[white]tart [yellow]x[white]stop [yellow]r[white]estart [yellow]R[white]eload reload-[yellow]O[white]r-restart tr[yellow]y[white]-restart reset-[yellow]F[white]ailed [yellow]e[white]nable [yellow]d[white]isable [yellow]m[white]ask [yellow]u[white]nmask [yellow]l[white]ogs [yellow]p[white]rocs [yellow]K[white]ill [yellow]t[white]imers [yellow]D[white]eps [yellow]E[white]dit [yellow]T[white]ransient [yellow]o[white]rder [yellow]g[white]roup [yellow]Space[white] mark [yellow]A[white]ll [yellow]/[white]filter [yellow]Tab[white] type [yellow]P[white]riv-toggle [yellow]q[white]uit")
 	footer.SetBackgroundColor(tcell.ColorDarkGray)
 
 	// Search Field Configuration
@@ -103,7 +332,7 @@ func (a *App) layout() tview.Primitive {
 
 	a.searchField.SetChangedFunc(func(text string) {
 		a.filter = text
-		a.refreshServices()
+		a.renderTable()
 	})
 
 	a.searchField.SetDoneFunc(func(key tcell.Key) {
@@ -124,6 +353,7 @@ func (a *App) layout() tview.Primitive {
 	// Main Flex Layout
 	flex := tview.NewFlex().SetDirection(tview.FlexRow).
 		AddItem(header, 1, 1, false).
+		AddItem(tabs, 1, 1, false).
 		AddItem(a.table, 0, 1, true)
 
 	if a.searchMode {
@@ -148,35 +378,118 @@ func (a *App) layout() tview.Primitive {
 
 		switch event.Rune() {
 		case 's':
+			a.act("Starting", serviceName, a.manager.StartService)
+		case 'x':
+			a.act("Stopping", serviceName, a.manager.StopService)
+		case 'r':
+			a.act("Restarting", serviceName, a.manager.RestartService)
+		case 'R':
+			a.act("Reloading", serviceName, a.manager.ReloadService)
+		case 'O':
+			a.act("Reloading or restarting", serviceName, a.manager.ReloadOrRestartService)
+		case 'y':
+			a.act("Restarting (if running)", serviceName, a.manager.TryRestartService)
+		case 'F':
+			a.act("Resetting failed state of", serviceName, a.manager.ResetFailedService)
+		case 'e':
+			a.act("Enabling", serviceName, func(ctx context.Context, name string) error {
+				_, err := a.manager.EnableService(ctx, name, false)
+				return err
+			})
+		case 'd':
+			a.act("Disabling", serviceName, func(ctx context.Context, name string) error {
+				_, err := a.manager.DisableService(ctx, name, false)
+				return err
+			})
+		case 'm':
+			a.act("Masking", serviceName, func(ctx context.Context, name string) error {
+				_, err := a.manager.MaskService(ctx, name, false)
+				return err
+			})
+		case 'u':
+			a.act("Unmasking", serviceName, func(ctx context.Context, name string) error {
+				_, err := a.manager.UnmaskService(ctx, name)
+				return err
+			})
+		case 'l':
 			if serviceName != "" {
-				a.performAction("Starting", serviceName, a.manager.StartService)
+				a.showLogs(serviceName)
 			}
-		case 'x':
+		case 't':
+			a.showTimers()
+			return nil
+		case 'K':
 			if serviceName != "" {
-				a.performAction("Stopping", serviceName, a.manager.StopService)
+				a.killService(serviceName)
 			}
-		case 'r':
+			return nil
+		case 'p':
 			if serviceName != "" {
-				a.performAction("Restarting", serviceName, a.manager.RestartService)
+				a.showProcesses(serviceName)
 			}
-		case 'l':
+			return nil
+		case 'D':
 			if serviceName != "" {
-				a.showLogs(serviceName)
+				a.showDependencies(serviceName, false)
+			}
+			return nil
+		case 'E':
+			if serviceName != "" {
+				a.editDropIn(serviceName)
 			}
+			return nil
+		case 'o':
+			// Cycle the sort order
+			switch a.sortBy {
+			case "name":
+				a.sortBy = "cpu"
+			case "cpu":
+				a.sortBy = "mem"
+			default:
+				a.sortBy = "name"
+			}
+			a.renderTable()
+			return nil
+		case 'g':
+			a.nextGroup()
+			return nil
+		case 'T':
+			a.transientOnly = !a.transientOnly
+			a.tviewApp.SetRoot(a.layout(), true)
+			a.renderTable()
+			return nil
 		case '/':
 			a.searchMode = true
 			a.tviewApp.SetRoot(a.layout(), true)
 			a.tviewApp.SetFocus(a.searchField)
 			return nil // Consume key
+		case ' ':
+			a.toggleMark(row)
+			return nil
+		case 'A':
+			a.markAll()
+			return nil
 		case 'P':
 			a.togglePrivileged()
 		case 'q':
 			a.tviewApp.Stop()
 		}
 
-		// Also handle Enter for logs
-		if event.Key() == tcell.KeyEnter && serviceName != "" {
-			a.showLogs(serviceName)
+		switch event.Key() {
+		case tcell.KeyEnter:
+			// Also handle Enter for logs
+			if serviceName != "" {
+				a.showLogs(serviceName)
+			}
+		case tcell.KeyEscape:
+			a.clearMarks()
+			return nil
+		case tcell.KeyTab:
+			a.switchType(1)
+			return nil
+		case tcell.KeyBacktab:
+			a.switchType(-1)
+			return nil
 		}
 
 		return event
@@ -185,9 +498,52 @@ func (a *App) layout() tview.Primitive {
 	return flex
 }
 
+// nextGroup cycles the group filter through the configured groups and back
+// to showing every unit
+func (a *App) nextGroup() {
+	if a.config == nil || len(a.config.Groups) == 0 {
+		return
+	}
+	names := a.config.GroupNames()
+	next := names[0]
+	for i, name := range names {
+		if name == a.group {
+			next = ""
+			if i+1 < len(names) {
+				next = names[i+1]
+			}
+			break
+		}
+	}
+	a.group = next
+	a.tviewApp.SetRoot(a.layout(), true)
+	a.renderTable()
+}
+
+// switchType moves to the next (1) or previous (-1) unit type tab
+func (a *App) switchType(step int) {
+	idx := 0
+	for i, t := range core.UnitTypes {
+		if t == a.unitType {
+			idx = i
+			break
+		}
+	}
+	n := len(core.UnitTypes)
+	a.unitType = core.UnitTypes[(idx+step+n)%n]
+	// Marks name units of the tab they were made on
+	a.marked = map[string]bool{}
+	a.tviewApp.SetRoot(a.layout(), true)
+	a.refreshServices()
+	a.table.Select(1, 0)
+}
+
 func (a *App) togglePrivileged() {
+	if a.backend == nil {
+		return
+	}
 	newPriv := !a.privileged
-	newManager, err := core.NewSystemdManager(newPriv)
+	newManager, err := a.backend(newPriv)
 
 	if err != nil {
 		modal := tview.NewModal().
@@ -200,22 +556,30 @@ func (a *App) togglePrivileged() {
 		return
 	}
 
+	close(a.stopWatch)
 	a.manager.Close()
 	a.manager = newManager
 	a.privileged = newPriv
+	// Marks name units of the old scope, which may share names with others
+	a.marked = map[string]bool{}
 	a.filter = "" // Clear filter on switch to avoid confusion? Or keep it? Let's clear to be safe/fresh.
 
 	a.tviewApp.SetRoot(a.layout(), true)
 	a.refreshServices()
+	a.watch()
 }
 
 func (a *App) refreshServices() {
-	services, err := a.manager.ListServices()
+	services, err := a.manager.ListUnits(context.Background(), a.unitType)
 	if err != nil {
 		return
 	}
 	a.services = services
+	a.renderTable()
+}
 
+// renderTable redraws the table from the cached service list
+func (a *App) renderTable() {
 	// Save selection
 	row, _ := a.table.GetSelection()
 	selectedName := ""
@@ -226,8 +590,12 @@ func (a *App) refreshServices() {
 	a.table.Clear()
 
 	// Header Row
-	headers := []string{"NAME", "ACTIVE", "SUB", "LOAD", "DESCRIPTION"}
+	headers := []string{"NAME", "ACTIVE", "SUB", "LOAD", "CPU%", "MEM", "DESCRIPTION"}
+	sortColumn := map[string]int{"name": 0, "cpu": 4, "mem": 5}[a.sortBy]
 	for c, h := range headers {
+		if c == sortColumn {
+			h += " ▼"
+		}
 		cell := tview.NewTableCell(h).
 			SetTextColor(tcell.ColorYellow).
 			SetSelectable(false).
@@ -239,25 +607,19 @@ func (a *App) refreshServices() {
 	currentRow := 1
 	newSelectionRow := 0
 
-	for _, s := range services {
+	for _, s := range a.sortedServices() {
 		// Apply Filter if needed
 		if a.filter != "" && !strings.Contains(s.Name, a.filter) {
 			continue
 		}
-
-		color := tcell.ColorGreen
-		if s.ActiveState != "active" {
-			color = tcell.ColorGray
+		if a.transientOnly && !s.Transient {
+			continue
 		}
-		if s.ActiveState == "failed" {
-			color = tcell.ColorRed
+		if a.group != "" && !a.config.Groups[a.group].Contains(s.Name) {
+			continue
 		}
 
-		a.table.SetCell(currentRow, 0, tview.NewTableCell(s.Name).SetTextColor(color))
-		a.table.SetCell(currentRow, 1, tview.NewTableCell(s.ActiveState).SetTextColor(color))
-		a.table.SetCell(currentRow, 2, tview.NewTableCell(s.SubState).SetTextColor(color))
-		a.table.SetCell(currentRow, 3, tview.NewTableCell(s.LoadState))
-		a.table.SetCell(currentRow, 4, tview.NewTableCell(s.Description))
+		a.setRow(currentRow, s)
 
 		if s.Name == selectedName {
 			newSelectionRow = currentRow
@@ -279,17 +641,85 @@ func (a *App) refreshServices() {
 	}
 }
 
-func (a *App) performAction(actionVerb string, name string, actionFunc func(string) error) {
+// sortedServices returns the cached list in the order chosen with 'o';
+// busiest first for cpu and mem
+func (a *App) sortedServices() []core.ServiceUnit {
+	services := append([]core.ServiceUnit(nil), a.services...)
+	sort.SliceStable(services, func(i, j int) bool {
+		ui, uj := a.usage[services[i].Name], a.usage[services[j].Name]
+		switch {
+		case a.sortBy == "cpu" && ui.CPUPercent != uj.CPUPercent:
+			return ui.CPUPercent > uj.CPUPercent
+		case a.sortBy == "mem" && ui.MemoryCurrent != uj.MemoryCurrent:
+			return ui.MemoryCurrent > uj.MemoryCurrent
+		}
+		return services[i].Name < services[j].Name
+	})
+	return services
+}
+
+func (a *App) setRow(row int, s core.ServiceUnit) {
+	color := tcell.ColorGreen
+	if s.ActiveState != "active" {
+		color = tcell.ColorGray
+	}
+	if s.ActiveState == "failed" {
+		color = tcell.ColorRed
+	}
+
+	// Transient units (svcm run, systemd-run) stand out by name
+	name := tview.NewTableCell(s.Name).SetTextColor(color)
+	if s.Transient {
+		name.SetTextColor(tcell.ColorFuchsia).SetAttributes(tcell.AttrItalic)
+	}
+	if a.marked[s.Name] {
+		name.SetBackgroundColor(tcell.ColorDarkCyan)
+	}
+	a.table.SetCell(row, 0, name)
+	a.table.SetCell(row, 1, tview.NewTableCell(s.ActiveState).SetTextColor(color))
+	a.table.SetCell(row, 2, tview.NewTableCell(s.SubState).SetTextColor(color))
+	a.table.SetCell(row, 3, tview.NewTableCell(s.LoadState))
+
+	cpu, mem := "", ""
+	if u, ok := a.usage[s.Name]; ok && s.ActiveState != "inactive" {
+		if u.CPUPercent >= 0 {
+			cpu = fmt.Sprintf("%.1f", u.CPUPercent)
+		}
+		if u.MemoryCurrent > 0 {
+			mem = core.FormatBytes(u.MemoryCurrent)
+		}
+	}
+	a.table.SetCell(row, 4, tview.NewTableCell(cpu).SetAlign(tview.AlignRight))
+	a.table.SetCell(row, 5, tview.NewTableCell(mem).SetAlign(tview.AlignRight))
+	a.table.SetCell(row, 6, tview.NewTableCell(s.Description))
+}
+
+// jobContext queues jobs in the job mode the TUI was started with
+func (a *App) jobContext() context.Context {
+	return core.WithJobMode(context.Background(), a.jobMode)
+}
+
+// performAction runs actionFunc in the background behind a modal. Cancel
+// stops waiting for the job; a job systemd already queued still runs.
+func (a *App) performAction(actionVerb string, name string, actionFunc func(context.Context, string) error) {
+	ctx, cancel := context.WithCancel(a.jobContext())
 	modal := tview.NewModal().
 		SetText(fmt.Sprintf("%s %s...", actionVerb, name)).
 		AddButtons([]string{"Cancel"}).
 		SetDoneFunc(func(buttonIndex int, buttonLabel string) {
+			cancel()
 			a.tviewApp.SetRoot(a.layout(), true)
 		})
 
 	go func() {
-		err := actionFunc(name)
+		err := actionFunc(ctx, name)
 		a.tviewApp.QueueUpdateDraw(func() {
+			if ctx.Err() != nil {
+				// Canceled, the modal is already gone
+				a.refreshServices()
+				return
+			}
+			cancel()
 			if err != nil {
 				modal.SetText(fmt.Sprintf("Error: %v", err)).
 					AddButtons([]string{"OK"})
@@ -304,52 +734,192 @@ func (a *App) performAction(actionVerb string, name string, actionFunc func(stri
 	a.tviewApp.SetRoot(modal, false)
 }
 
-func (a *App) showLogs(name string) {
-	// Show a modal with logs
-	// We will cheat and just run journalctl and capture output for now
-	// Ideally this should stream data into the TextView
+// killService picks a signal and the processes to send it to
+func (a *App) killService(name string) {
+	who := "all"
+	a.pickSignal("Kill "+name, a.layout(), &who, func(sig syscall.Signal) {
+		verb := fmt.Sprintf("Sending %s to %s processes of", core.SignalName(sig), who)
+		a.performAction(verb, name, func(ctx context.Context, n string) error {
+			return a.manager.KillService(ctx, n, who, sig)
+		})
+	})
+}
 
+// editDropIn suspends the UI while the user edits the unit's override in
+// their editor, then reports what was written.
+func (a *App) editDropIn(name string) {
+	var path string
+	var changed bool
+	var err error
+	a.tviewApp.Suspend(func() {
+		path, changed, err = a.manager.EditDropIn(context.Background(), name, func(file string) error {
+			editor := core.Editor()
+			cmd := exec.Command(editor[0], append(editor[1:], file)...)
+			cmd.Stdin = os.Stdin
+			cmd.Stdout = os.Stdout
+			cmd.Stderr = os.Stderr
+			if err := cmd.Run(); err != nil {
+				return fmt.Errorf("editor %s failed: %w", editor[0], err)
+			}
+			return nil
+		})
+	})
+
+	text := "No changes made."
+	switch {
+	case err != nil:
+		text = fmt.Sprintf("Error: %v", err)
+	case changed:
+		text = fmt.Sprintf("Saved %s and reloaded systemd.", path)
+		if _, statErr := os.Stat(path); statErr != nil {
+			text = fmt.Sprintf("Removed %s and reloaded systemd.", path)
+		}
+	}
+	modal := tview.NewModal().
+		SetText(text).
+		AddButtons([]string{"OK"}).
+		SetDoneFunc(func(buttonIndex int, buttonLabel string) {
+			a.tviewApp.SetRoot(a.layout(), true)
+		})
+	a.tviewApp.SetRoot(modal, false)
+}
+
+// showLogs opens a live log pane. New entries are appended as they arrive
+// and the view sticks to the bottom unless the user scrolled up; 'p' pauses
+// the stream (entries are buffered) and Esc closes it.
+func (a *App) showLogs(name string) {
 	textView := tview.NewTextView().
 		SetDynamicColors(true).
 		SetRegions(true).
-		SetWordWrap(true).
-		SetChangedFunc(func() {
-			// Scroll to end
-		})
+		SetWordWrap(true)
+
+	following := true
+	paused := false
+	var pending []core.LogEntry
+	var stream *core.LogStream
+
+	updateTitle := func() {
+		state := "following"
+		if paused {
+			state = fmt.Sprintf("paused, %d new", len(pending))
+		} else if !following {
+			state = "scrolled, End to follow"
+		}
+		textView.SetTitle(fmt.Sprintf(" Logs: %s [%s] (p pause/resume, Esc close) ", name, state))
+	}
+	textView.SetBorder(true)
+	updateTitle()
 
-	textView.SetBorder(true).SetTitle(" Logs: " + name + " (Press Esc to close) ")
+	appendEntries := func(entries ...core.LogEntry) {
+		for _, e := range entries {
+			fmt.Fprint(textView, formatLogLine(e))
+		}
+		if following {
+			textView.ScrollToEnd()
+		}
+	}
+
+	closeLogs := func() {
+		if stream != nil {
+			stream.Close()
+		}
+		a.tviewApp.SetRoot(a.layout(), true)
+	}
 
-	// Capture Key to close
 	textView.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
-		if event.Key() == tcell.KeyEscape {
-			a.tviewApp.SetRoot(a.layout(), true)
+		switch event.Key() {
+		case tcell.KeyEscape:
+			closeLogs()
+			return nil
+		case tcell.KeyUp, tcell.KeyPgUp, tcell.KeyHome:
+			following = false
+			updateTitle()
+		case tcell.KeyEnd:
+			following = true
+			updateTitle()
+		}
+		switch event.Rune() {
+		case 'k', 'g':
+			following = false
+			updateTitle()
+		case 'G':
+			following = true
+			updateTitle()
+		case 'p':
+			paused = !paused
+			if !paused {
+				appendEntries(pending...)
+				pending = nil
+			}
+			updateTitle()
 			return nil
 		}
 		return event
 	})
-
-	// Initial Load
-	go func() {
-		// Run journalctl -n 200
-		var cmdArgs []string
-		if a.privileged {
-			cmdArgs = []string{"-u", name, "-n", "200", "--no-pager"}
-		} else {
-			cmdArgs = []string{"--user", "-u", name, "-n", "200", "--no-pager"}
+	textView.SetMouseCapture(func(action tview.MouseAction, event *tcell.EventMouse) (tview.MouseAction, *tcell.EventMouse) {
+		if action == tview.MouseScrollUp {
+			following = false
+			updateTitle()
 		}
+		return action, event
+	})
 
-		cmd := exec.Command("journalctl", cmdArgs...)
-		out, err := cmd.CombinedOutput()
+	var err error
+	stream, err = a.manager.FollowLogs(context.Background(), core.LogQuery{Unit: name, Lines: 200})
+	if err != nil {
+		textView.SetText(fmt.Sprintf("Error fetching logs: %v", err))
+		a.tviewApp.SetRoot(textView, true)
+		return
+	}
 
-		a.tviewApp.QueueUpdateDraw(func() {
-			if err != nil {
-				textView.SetText(fmt.Sprintf("Error fetching logs: %v", err))
-			} else {
-				textView.SetText(string(out))
-				textView.ScrollToEnd()
+	go func() {
+		for e := range stream.Entries {
+			// Batch whatever is already waiting, so the backlog is one redraw
+			batch := []core.LogEntry{e}
+		drain:
+			for {
+				select {
+				case next, ok := <-stream.Entries:
+					if !ok {
+						break drain
+					}
+					batch = append(batch, next)
+				default:
+					break drain
+				}
 			}
-		})
+
+			a.tviewApp.QueueUpdateDraw(func() {
+				if paused {
+					pending = append(pending, batch...)
+					updateTitle()
+					return
+				}
+				appendEntries(batch...)
+			})
+		}
+		if err := stream.Err(); err != nil {
+			a.tviewApp.QueueUpdateDraw(func() {
+				fmt.Fprintf(textView, "[red]Log stream ended: %s[-]\n", tview.Escape(err.Error()))
+			})
+		}
 	}()
 
 	a.tviewApp.SetRoot(textView, true)
 }
+
+// formatLogLine colors a journal entry by priority, like journalctl does
+func formatLogLine(e core.LogEntry) string {
+	line := tview.Escape(core.FormatLogEntry(e))
+	switch {
+	case e.Priority <= 3:
+		return "[red::b]" + line + "[-::-]\n"
+	case e.Priority == 4:
+		return "[yellow]" + line + "[-]\n"
+	case e.Priority == 5:
+		return "[white::b]" + line + "[-::-]\n"
+	case e.Priority >= 7:
+		return "[gray]" + line + "[-]\n"
+	}
+	return line + "\n"
+}